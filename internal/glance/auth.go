@@ -252,37 +252,56 @@ func (a *application) isAuthorized(w http.ResponseWriter, r *http.Request) bool
 		return true
 	}
 
+	_, authorized := a.authorizedUsername(w, r)
+	return authorized
+}
+
+// Returns the name of the user that made the request if they're logged in
+func (a *application) authorizedUsername(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !a.RequiresAuth {
+		return "", false
+	}
+
 	token, err := r.Cookie(AUTH_SESSION_COOKIE_NAME)
 	if err != nil || token.Value == "" {
-		return false
+		return "", false
 	}
 
 	usernameHash, shouldRegenerate, err := verifySessionToken(token.Value, a.authSecretKey, time.Now())
 	if err != nil {
-		return false
+		return "", false
 	}
 
 	username, exists := a.usernameHashToUsername[string(usernameHash)]
 	if !exists {
-		return false
+		return "", false
 	}
 
 	_, exists = a.Config.Auth.Users[username]
 	if !exists {
-		return false
+		return "", false
 	}
 
 	if shouldRegenerate {
 		newToken, err := generateSessionToken(username, a.authSecretKey, time.Now())
 		if err != nil {
 			log.Printf("Could not compute session token during regeneration: %v", err)
-			return false
+			return "", false
 		}
 
 		a.setAuthSessionCookie(w, r, newToken, time.Now().Add(AUTH_TOKEN_VALID_PERIOD))
 	}
 
-	return true
+	return username, true
+}
+
+func (a *application) isAdmin(w http.ResponseWriter, r *http.Request) bool {
+	username, authorized := a.authorizedUsername(w, r)
+	if !authorized {
+		return false
+	}
+
+	return a.Config.Auth.Users[username].Admin
 }
 
 // Handles sending the appropriate response for an unauthorized request and returns true if the request was unauthorized
@@ -17,6 +17,7 @@ const (
 	cliIntentServe
 	cliIntentConfigValidate
 	cliIntentConfigPrint
+	cliIntentConfigMigrate
 	cliIntentDiagnose
 	cliIntentSensorsPrint
 	cliIntentMountpointInfo
@@ -27,6 +28,8 @@ const (
 type cliOptions struct {
 	intent     cliIntent
 	configPath string
+	dryRun     bool
+	redact     bool
 	args       []string
 }
 
@@ -50,6 +53,9 @@ func parseCliOptions() (*cliOptions, error) {
 		fmt.Println("\nCommands:")
 		fmt.Println("  config:validate       Validate the config file")
 		fmt.Println("  config:print          Print the parsed config file with embedded includes")
+		fmt.Println("    --redact            Hide passwords, tokens and other secrets")
+		fmt.Println("  config:migrate        Update deprecated options in the config file and its includes")
+		fmt.Println("    --dry-run           Print a diff of the changes without writing them")
 		fmt.Println("  password:hash <pwd>   Hash a password")
 		fmt.Println("  secret:make           Generate a random secret key")
 		fmt.Println("  sensors:print         List all sensors")
@@ -64,10 +70,23 @@ func parseCliOptions() (*cliOptions, error) {
 	}
 
 	var intent cliIntent
+	var dryRun bool
+	var redact bool
 	args = flags.Args()
 	unknownCommandErr := fmt.Errorf("unknown command: %s", strings.Join(args, " "))
 
-	if len(args) == 0 {
+	if len(args) > 0 && args[0] == "config:migrate" {
+		intent = cliIntentConfigMigrate
+
+		if len(args) == 2 && args[1] == "--dry-run" {
+			dryRun = true
+		} else if len(args) != 1 {
+			return nil, unknownCommandErr
+		}
+	} else if len(args) == 2 && args[0] == "config:print" && args[1] == "--redact" {
+		intent = cliIntentConfigPrint
+		redact = true
+	} else if len(args) == 0 {
 		intent = cliIntentServe
 	} else if len(args) == 1 {
 		if args[0] == "config:validate" {
@@ -102,6 +121,8 @@ func parseCliOptions() (*cliOptions, error) {
 	return &cliOptions{
 		intent:     intent,
 		configPath: *configPath,
+		dryRun:     dryRun,
+		redact:     redact,
 		args:       args,
 	}, nil
 }
@@ -153,3 +174,56 @@ func cliMountpointInfo(requestedPath string) int {
 
 	return 0
 }
+
+func cliConfigMigrate(configPath string, dryRun bool) int {
+	if notice := configLocationMigrationNotice(configPath); notice != "" {
+		fmt.Println(notice)
+		return 1
+	}
+
+	result, err := migrateConfig(configPath)
+	if err != nil {
+		fmt.Printf("Could not migrate config: %v\n", err)
+		return 1
+	}
+
+	if result.changeCount() == 0 {
+		fmt.Println("Config is already up to date, nothing to migrate")
+		return 0
+	}
+
+	if dryRun {
+		for _, file := range result.files {
+			if len(file.changes) == 0 {
+				continue
+			}
+
+			fmt.Print(unifiedDiff(
+				file.path,
+				strings.Split(strings.TrimSuffix(string(file.original), "\n"), "\n"),
+				strings.Split(strings.TrimSuffix(string(file.migrated), "\n"), "\n"),
+			))
+		}
+		fmt.Println()
+	}
+
+	for _, file := range result.files {
+		for _, change := range file.changes {
+			fmt.Printf("%s:%d: %s\n", change.filePath, change.line, change.description)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("\n%d change(s) would be made, run without --dry-run to apply them\n", result.changeCount())
+		return 0
+	}
+
+	if err := result.write(); err != nil {
+		fmt.Printf("Could not write migrated config: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n%d change(s) made\n", result.changeCount())
+
+	return 0
+}
@@ -0,0 +1,399 @@
+package glance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var configEditorPageTemplate = mustParseTemplate("config-editor.html", "document.html", "footer.html")
+
+const configEditorMaxFileSize = 1 << 20 // 1MB
+
+type configEditorFile struct {
+	// Shown to the user and used to identify the file in requests,
+	// relative to the config directory when possible
+	Name    string
+	absPath string
+}
+
+type configEditorPageData struct {
+	templateData
+	Files []configEditorFile
+}
+
+type configEditorError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Lists the main config file along with all of the files it includes, read from disk
+// on every call so that newly added includes show up without having to reload the page
+func (a *application) configEditorFiles() ([]configEditorFile, error) {
+	_, includes, err := parseYAMLIncludes(a.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	configAbsPath, err := filepath.Abs(a.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := filepath.Dir(configAbsPath)
+	var mainFilePath string
+
+	if stat, err := os.Stat(configAbsPath); err == nil && stat.IsDir() {
+		baseDir = configAbsPath
+		mainFilePath = filepath.Join(configAbsPath, configDirectoryMainFile)
+	} else {
+		mainFilePath = configAbsPath
+		includes[mainFilePath] = struct{}{}
+	}
+
+	files := make([]configEditorFile, 0, len(includes))
+
+	for filePath := range includes {
+		if stat, err := os.Stat(filePath); err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+
+		name := filePath
+		if rel, err := filepath.Rel(baseDir, filePath); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+
+		files = append(files, configEditorFile{Name: name, absPath: filePath})
+	}
+
+	slices.SortFunc(files, func(a, b configEditorFile) int {
+		if a.absPath == mainFilePath || b.absPath == mainFilePath {
+			return ternary(a.absPath == mainFilePath, -1, 1)
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return files, nil
+}
+
+func (a *application) findConfigEditorFile(name string) (*configEditorFile, error) {
+	files, err := a.configEditorFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		if files[i].Name == name {
+			return &files[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Handles sending the appropriate response for a request by a non-admin and returns true if the request was made by one
+func (a *application) handleNonAdminResponse(w http.ResponseWriter, r *http.Request, fallback doWhenUnauthorized) bool {
+	if a.isAdmin(w, r) {
+		return false
+	}
+
+	if a.isAuthorized(w, r) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Forbidden"))
+		return true
+	}
+
+	return a.handleUnauthorizedResponse(w, r, fallback)
+}
+
+func (a *application) handleConfigEditorPageRequest(w http.ResponseWriter, r *http.Request) {
+	if a.handleNonAdminResponse(w, r, redirectToLogin) {
+		return
+	}
+
+	files, err := a.configEditorFiles()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := configEditorPageData{
+		templateData: templateData{App: a},
+		Files:        files,
+	}
+	a.populateTemplateRequestData(&data.Request, r)
+
+	var responseBytes bytes.Buffer
+	if err := configEditorPageTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+func (a *application) handleConfigEditorFileRequest(w http.ResponseWriter, r *http.Request) {
+	if a.handleNonAdminResponse(w, r, showUnauthorizedJSON) {
+		return
+	}
+
+	file, err := a.findConfigEditorFile(r.URL.Query().Get("name"))
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if file == nil {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "file not found"})
+		return
+	}
+
+	contents, err := os.ReadFile(file.absPath)
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{
+		"contents": string(contents),
+		"hash":     configEditorContentsHash(contents),
+	})
+}
+
+func (a *application) handleConfigEditorSaveRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if a.handleNonAdminResponse(w, r, showUnauthorizedJSON) {
+		return
+	}
+
+	var request struct {
+		Name         string `json:"name"`
+		Contents     string `json:"contents"`
+		Hash         string `json:"hash"`
+		ValidateOnly bool   `json:"validate-only"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(r.Body, configEditorMaxFileSize*2)).Decode(&request); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	file, err := a.findConfigEditorFile(request.Name)
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if file == nil {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "file not found"})
+		return
+	}
+
+	newContents := []byte(request.Contents)
+	if len(newContents) > configEditorMaxFileSize {
+		writeJSONResponse(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+		return
+	}
+
+	if errs := validateConfigWithFileContents(a.configPath, file.absPath, newContents, a.configEditorFileName); len(errs) > 0 {
+		writeJSONResponse(w, http.StatusUnprocessableEntity, map[string]any{"errors": errs})
+		return
+	}
+
+	if request.ValidateOnly {
+		writeJSONResponse(w, http.StatusOK, map[string]string{})
+		return
+	}
+
+	currentContents, err := os.ReadFile(file.absPath)
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if configEditorContentsHash(currentContents) != request.Hash {
+		writeJSONResponse(w, http.StatusConflict, map[string]string{
+			"error": "the file has been changed since it was loaded, reload it before saving",
+		})
+		return
+	}
+
+	if err := writeFileAtomicallyWithBackup(file.absPath, newContents); err != nil {
+		log.Printf("Config editor failed to save %s: %v", file.absPath, err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Config file %s was changed through the config editor", file.absPath)
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{
+		"hash": configEditorContentsHash(newContents),
+	})
+}
+
+func (a *application) configEditorFileName(absPath string) string {
+	files, err := a.configEditorFiles()
+	if err == nil {
+		for i := range files {
+			if files[i].absPath == absPath {
+				return files[i].Name
+			}
+		}
+	}
+
+	return absPath
+}
+
+var configErrorLinePattern = regexp.MustCompile(`\bline (\d+)\b`)
+
+// Validates the config the same way it gets validated when it's loaded from disk, except
+// that the contents of the file at filePath are replaced with the provided contents
+func validateConfigWithFileContents(
+	configPath string,
+	filePath string,
+	contents []byte,
+	displayName func(string) string,
+) []configEditorError {
+	readFile := func(path string) ([]byte, error) {
+		if absPath, err := filepath.Abs(path); err == nil && absPath == filePath {
+			return contents, nil
+		}
+
+		return os.ReadFile(path)
+	}
+
+	configContents, _, origins, err := parseYAMLIncludesWithReader(configPath, readFile)
+	if err == nil {
+		var config *config
+		config, err = newConfigFromYAML(configContents)
+		if err == nil {
+			var app *application
+			// the application only gets created to validate the config, so it never gets started or stopped
+			if app, err = newApplication(config); err == nil {
+				app.notifier.stop()
+			}
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var errs []configEditorError
+
+	// yaml errors can span multiple lines, each one referring to a different line in the config
+	for message := range strings.SplitSeq(err.Error(), "\n") {
+		message = strings.TrimSpace(message)
+		if message == "" || message == "yaml: unmarshal errors:" {
+			continue
+		}
+
+		configError := configEditorError{Message: message}
+
+		if match := configErrorLinePattern.FindStringSubmatch(message); match != nil {
+			line, _ := strconv.Atoi(match[1])
+
+			if line > 0 && line <= len(origins) && origins[line-1].line > 0 {
+				origin := origins[line-1]
+				configError.File = displayName(origin.filePath)
+				configError.Line = origin.line
+				configError.Message = strings.Replace(message, match[0], "line "+strconv.Itoa(origin.line), 1)
+			}
+		}
+
+		errs = append(errs, configError)
+	}
+
+	if len(errs) == 0 {
+		errs = append(errs, configEditorError{Message: err.Error()})
+	}
+
+	return errs
+}
+
+func configEditorContentsHash(contents []byte) string {
+	hash := sha256.Sum256(contents)
+	return hex.EncodeToString(hash[:])
+}
+
+// Writes to a temporary file that then gets renamed to the destination so that the file watcher
+// never sees a partially written file, the previous contents are kept in a .bak file alongside it
+func writeFileAtomicallyWithBackup(filePath string, contents []byte) error {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("reading file info: %w", err)
+	}
+
+	previousContents, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("reading current contents: %w", err)
+	}
+
+	if err := os.WriteFile(filePath+".bak", previousContents, stat.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tempFilePath, stat.Mode().Perm()); err != nil {
+		return fmt.Errorf("setting permissions of temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		// Renaming over a file that is bind mounted on its own, as is common when running
+		// in a container, isn't possible, so fall back to writing to it directly. Any other
+		// error is returned as is since writing directly could leave the file truncated.
+		if !errors.Is(err, syscall.EBUSY) && !errors.Is(err, syscall.EXDEV) {
+			return fmt.Errorf("replacing file: %w", err)
+		}
+
+		if err := os.WriteFile(filePath, contents, stat.Mode().Perm()); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
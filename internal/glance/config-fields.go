@@ -93,7 +93,7 @@ func (c *hslColorField) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
-var durationFieldPattern = regexp.MustCompile(`^(\d+)(s|m|h|d)$`)
+var durationFieldPattern = regexp.MustCompile(`^(\d+)(ms|s|m|h|d)$`)
 
 type durationField time.Duration
 
@@ -116,6 +116,8 @@ func (d *durationField) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	switch matches[2] {
+	case "ms":
+		*d = durationField(time.Duration(duration) * time.Millisecond)
 	case "s":
 		*d = durationField(time.Duration(duration) * time.Second)
 	case "m":
@@ -0,0 +1,532 @@
+package glance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type configMigrationChange struct {
+	filePath    string
+	line        int
+	description string
+}
+
+type configMigrationFile struct {
+	path     string
+	original []byte
+	migrated []byte
+	changes  []configMigrationChange
+}
+
+type configMigrationResult struct {
+	files []*configMigrationFile
+}
+
+func (r *configMigrationResult) changeCount() int {
+	count := 0
+
+	for _, f := range r.files {
+		count += len(f.changes)
+	}
+
+	return count
+}
+
+// Each migration receives every mapping node in a file and returns the edits needed to migrate it, if any.
+// When a deprecated option gets removed from the code, a migration for it should be added here.
+var configMigrations = []func(mapping *yaml.Node) []configMigrationEdit{
+	migrateStocksWidget,
+	migrateLegacyCalendarWidget,
+}
+
+// Edits are applied to the original text of the file rather than re-encoding the
+// parsed document, so that its formatting and comments are left untouched
+type configMigrationEdit struct {
+	// Edits without a description are part of the change described by the one before them
+	description string
+	node        *yaml.Node
+	// The new value of node, unless the entry it's the key of is being removed
+	value  string
+	remove *yaml.Node
+}
+
+func replaceYAMLScalar(node *yaml.Node, value string, description string) configMigrationEdit {
+	return configMigrationEdit{description: description, node: node, value: value}
+}
+
+func migrateConfig(mainFilePath string) (*configMigrationResult, error) {
+	filePaths, err := collectConfigFilePaths(mainFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &configMigrationResult{}
+
+	for _, filePath := range filePaths {
+		file, err := migrateConfigFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		result.files = append(result.files, file)
+	}
+
+	return result, nil
+}
+
+func (r *configMigrationResult) write() error {
+	for _, file := range r.files {
+		if len(file.changes) == 0 {
+			continue
+		}
+
+		stat, err := os.Stat(file.path)
+		if err != nil {
+			return fmt.Errorf("reading permissions of %s: %w", file.path, err)
+		}
+
+		if err := os.WriteFile(file.path, file.migrated, stat.Mode().Perm()); err != nil {
+			return fmt.Errorf("writing %s: %w", file.path, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the main file along with every file it includes, without expanding the includes
+func collectConfigFilePaths(mainFilePath string) ([]string, error) {
+	var filePaths []string
+	seen := make(map[string]struct{})
+
+	var collect func(filePath string, depth int) error
+	collect = func(filePath string, depth int) error {
+		if depth > CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT {
+			return fmt.Errorf("recursion depth limit of %d reached", CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT)
+		}
+
+		absPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return fmt.Errorf("getting absolute path of %s: %w", filePath, err)
+		}
+
+		if _, ok := seen[absPath]; ok {
+			return nil
+		}
+		seen[absPath] = struct{}{}
+		filePaths = append(filePaths, absPath)
+
+		contents, err := os.ReadFile(absPath)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", absPath, err)
+		}
+
+		for _, match := range configIncludePattern.FindAllSubmatch(contents, -1) {
+			includeFilePath := strings.TrimSpace(string(match[2]))
+			// remote includes can't be written to
+			if strings.HasPrefix(includeFilePath, "{") || isRemoteConfigIncludeSource(includeFilePath) {
+				continue
+			}
+
+			if !filepath.IsAbs(includeFilePath) {
+				includeFilePath = filepath.Join(filepath.Dir(absPath), includeFilePath)
+			}
+
+			if err := collect(includeFilePath, depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	rootFilePaths := []string{mainFilePath}
+
+	if stat, err := os.Stat(mainFilePath); err == nil && stat.IsDir() {
+		dirMainFilePath, pageFilePaths, err := configDirectoryFilePaths(mainFilePath, os.ReadFile)
+		if err != nil {
+			return nil, err
+		}
+
+		rootFilePaths = append([]string{dirMainFilePath}, pageFilePaths...)
+	}
+
+	for _, filePath := range rootFilePaths {
+		if err := collect(filePath, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return filePaths, nil
+}
+
+var legacyConfigIncludePattern = regexp.MustCompile(`(?m)^([ \t]*(?:-[ \t]*)?)!include:`)
+
+func migrateConfigFile(filePath string) (*configMigrationFile, error) {
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+
+	file := &configMigrationFile{
+		path:     filePath,
+		original: original,
+		migrated: original,
+	}
+
+	// line endings are normalized while migrating and restored afterwards
+	usesCRLF := bytes.Contains(original, []byte("\r\n"))
+	contents := bytes.ReplaceAll(original, []byte("\r\n"), []byte("\n"))
+
+	for _, loc := range legacyConfigIncludePattern.FindAllIndex(contents, -1) {
+		file.changes = append(file.changes, configMigrationChange{
+			filePath:    filePath,
+			line:        bytes.Count(contents[:loc[0]], []byte("\n")) + 1,
+			description: "replaced !include with $include",
+		})
+	}
+	contents = legacyConfigIncludePattern.ReplaceAll(contents, []byte("${1}$$include:"))
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(contents, &root); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filePath, err)
+	}
+
+	var edits []configMigrationEdit
+	walkYAMLMappingNodes(&root, func(mapping *yaml.Node) {
+		for _, migration := range configMigrations {
+			for _, edit := range migration(mapping) {
+				edits = append(edits, edit)
+
+				if edit.description != "" {
+					file.changes = append(file.changes, configMigrationChange{
+						filePath:    filePath,
+						line:        edit.node.Line,
+						description: edit.description,
+					})
+				}
+			}
+		}
+	})
+
+	if len(file.changes) == 0 {
+		return file, nil
+	}
+
+	slices.SortStableFunc(file.changes, func(a, b configMigrationChange) int {
+		return a.line - b.line
+	})
+
+	lines, err := applyConfigMigrationEdits(strings.Split(string(contents), "\n"), edits)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filePath, err)
+	}
+
+	file.migrated = []byte(strings.Join(lines, "\n"))
+	if usesCRLF {
+		file.migrated = bytes.ReplaceAll(file.migrated, []byte("\n"), []byte("\r\n"))
+	}
+
+	return file, nil
+}
+
+// Edits are applied starting from the end of the file so that the
+// positions of the nodes the rest of them refer to remain valid
+func applyConfigMigrationEdits(lines []string, edits []configMigrationEdit) ([]string, error) {
+	slices.SortStableFunc(edits, func(a, b configMigrationEdit) int {
+		if a.node.Line != b.node.Line {
+			return b.node.Line - a.node.Line
+		}
+
+		return b.node.Column - a.node.Column
+	})
+
+	for _, edit := range edits {
+		index := edit.node.Line - 1
+		if index < 0 || index >= len(lines) {
+			return nil, fmt.Errorf("%d: line out of range", edit.node.Line)
+		}
+
+		line := []rune(lines[index])
+		column := edit.node.Column - 1
+
+		if edit.remove != nil {
+			// removing the lines of entries that share them with something else,
+			// such as ones in flow mappings, would also remove the rest of it
+			if column > len(line) || strings.TrimSpace(string(line[:column])) != "" {
+				return nil, fmt.Errorf("%d: can't be migrated automatically, please %s manually", edit.node.Line, edit.description)
+			}
+
+			lines = slices.Delete(lines, index, min(len(lines), lastYAMLNodeLine(edit.remove)))
+			continue
+		}
+
+		source := []rune(quoteYAMLScalar(edit.node, edit.node.Value))
+		if column+len(source) > len(line) || string(line[column:column+len(source)]) != string(source) {
+			return nil, fmt.Errorf("%d: can't be migrated automatically, please change %q to %q manually", edit.node.Line, edit.node.Value, edit.value)
+		}
+
+		lines[index] = string(line[:column]) + quoteYAMLScalar(edit.node, edit.value) + string(line[column+len(source):])
+	}
+
+	return lines, nil
+}
+
+// Values are only ever replaced with ones that don't need escaping, so keeping the quotes is enough
+func quoteYAMLScalar(node *yaml.Node, value string) string {
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		return `"` + value + `"`
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		return "'" + value + "'"
+	}
+
+	return value
+}
+
+func lastYAMLNodeLine(node *yaml.Node) int {
+	line := node.Line
+
+	for _, child := range node.Content {
+		line = max(line, lastYAMLNodeLine(child))
+	}
+
+	return line
+}
+
+func walkYAMLMappingNodes(node *yaml.Node, fn func(*yaml.Node)) {
+	if node.Kind == yaml.MappingNode {
+		fn(node)
+	}
+
+	// Aliases are intentionally not followed, their anchors get visited on their own
+	for _, child := range node.Content {
+		walkYAMLMappingNodes(child, fn)
+	}
+}
+
+func yamlMappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
+
+func removeYAMLMappingEntry(mapping *yaml.Node, key string, description string) configMigrationEdit {
+	keyNode, valueNode := yamlMappingEntry(mapping, key)
+	return configMigrationEdit{description: description, node: keyNode, remove: valueNode}
+}
+
+func migrateStocksWidget(mapping *yaml.Node) []configMigrationEdit {
+	_, typeNode := yamlMappingEntry(mapping, "type")
+	if typeNode == nil || (typeNode.Value != "stocks" && typeNode.Value != "markets") {
+		return nil
+	}
+
+	var edits []configMigrationEdit
+
+	if typeNode.Value == "stocks" {
+		edits = append(edits, replaceYAMLScalar(typeNode, "markets", `changed widget type from "stocks" to "markets"`))
+	}
+
+	stocksKey, _ := yamlMappingEntry(mapping, "stocks")
+	marketsKey, _ := yamlMappingEntry(mapping, "markets")
+
+	if stocksKey != nil && marketsKey == nil {
+		edits = append(edits, replaceYAMLScalar(stocksKey, "markets", `renamed "stocks" property to "markets"`))
+	}
+
+	return edits
+}
+
+func migrateLegacyCalendarWidget(mapping *yaml.Node) []configMigrationEdit {
+	_, typeNode := yamlMappingEntry(mapping, "type")
+	if typeNode == nil || typeNode.Value != "calendar-legacy" {
+		return nil
+	}
+
+	edits := []configMigrationEdit{
+		replaceYAMLScalar(typeNode, "calendar", `changed widget type from "calendar-legacy" to "calendar"`),
+	}
+
+	startSundayKey, startSundayValue := yamlMappingEntry(mapping, "start-sunday")
+	if startSundayKey == nil {
+		return edits
+	}
+
+	var startSunday bool
+	if err := startSundayValue.Decode(&startSunday); err == nil && startSunday {
+		edits = append(edits,
+			replaceYAMLScalar(startSundayKey, "first-day-of-week", `replaced "start-sunday: true" with "first-day-of-week: sunday"`),
+			replaceYAMLScalar(startSundayValue, "sunday", ""),
+		)
+	} else {
+		edits = append(edits, removeYAMLMappingEntry(mapping, "start-sunday", `removed "start-sunday" property`))
+	}
+
+	return edits
+}
+
+// remove in v0.10.0 along with serveUpdateNoticeIfConfigLocationNotMigrated
+func configLocationMigrationNotice(configPath string) string {
+	if !isRunningInsideDockerContainer() {
+		return ""
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		return ""
+	}
+
+	if stat, err := os.Stat("glance.yml"); err != nil || stat.IsDir() {
+		return ""
+	}
+
+	return "Your glance.yml appears to be mounted at its pre-v0.7.0 location, this can't be migrated automatically.\n" +
+		"Please see https://github.com/glanceapp/glance/blob/main/docs/v0.7.0-upgrade.md for more information."
+}
+
+type lineDiffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Simple LCS based line diff, the inputs are expected to be config files so
+// the quadratic memory usage of the changed region isn't a concern
+func diffLines(a, b []string) []lineDiffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]lineDiffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineDiffOp{' ', line})
+	}
+
+	i, j := 0, 0
+	for i < len(midA) && j < len(midB) {
+		if midA[i] == midB[j] {
+			ops = append(ops, lineDiffOp{' ', midA[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = append(ops, lineDiffOp{'-', midA[i]})
+			i++
+		} else {
+			ops = append(ops, lineDiffOp{'+', midB[j]})
+			j++
+		}
+	}
+
+	for ; i < len(midA); i++ {
+		ops = append(ops, lineDiffOp{'-', midA[i]})
+	}
+
+	for ; j < len(midB); j++ {
+		ops = append(ops, lineDiffOp{'+', midB[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineDiffOp{' ', line})
+	}
+
+	return ops
+}
+
+func unifiedDiff(name string, a, b []string) string {
+	const contextLines = 3
+
+	ops := diffLines(a, b)
+	var out strings.Builder
+
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		hunkStart := max(0, start-contextLines)
+		hunkEnd := start
+
+		// extend the hunk until there's more than twice the context of unchanged lines
+		for unchanged := 0; hunkEnd < len(ops) && unchanged <= contextLines*2; hunkEnd++ {
+			if ops[hunkEnd].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+
+		for hunkEnd > start && ops[hunkEnd-1].kind == ' ' {
+			hunkEnd--
+		}
+		hunkEnd = min(len(ops), hunkEnd+contextLines)
+
+		lineA, lineB := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != '+' {
+				lineA++
+			}
+			if op.kind != '-' {
+				lineB++
+			}
+		}
+
+		countA, countB := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+
+		start = hunkEnd
+	}
+
+	return out.String()
+}
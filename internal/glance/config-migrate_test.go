@@ -0,0 +1,169 @@
+package glance
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "glance.yml")
+
+	original := `# comment at the top
+pages:
+  - name: Home
+
+    columns:
+      - size: full
+        widgets:
+          - type: stocks # inline comment
+            stocks:
+              - symbol: SPY
+          - type: calendar-legacy
+            start-sunday: false
+          - !include: other.yml
+`
+
+	expected := `# comment at the top
+pages:
+  - name: Home
+
+    columns:
+      - size: full
+        widgets:
+          - type: markets # inline comment
+            markets:
+              - symbol: SPY
+          - type: calendar
+          - $include: other.yml
+`
+
+	if err := os.WriteFile(filePath, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := migrateConfigFile(filePath)
+	if err != nil {
+		t.Fatalf("migrating config: %v", err)
+	}
+
+	if string(file.migrated) != expected {
+		t.Errorf("unexpected migrated config:\n%s", unifiedDiff(
+			filePath,
+			strings.Split(expected, "\n"),
+			strings.Split(string(file.migrated), "\n"),
+		))
+	}
+
+	if len(file.changes) != 5 {
+		t.Errorf("expected 5 changes, got %d", len(file.changes))
+	}
+
+	if err := os.WriteFile(filePath, file.migrated, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = migrateConfigFile(filePath)
+	if err != nil {
+		t.Fatalf("migrating already migrated config: %v", err)
+	}
+
+	if len(file.changes) != 0 {
+		t.Errorf("expected no changes when migrating twice, got %d", len(file.changes))
+	}
+}
+
+func TestMigrateConfigFileKeepsFormatting(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		expected  string
+		wantLines []int
+	}{
+		{
+			name: "four space indentation",
+			original: "pages:\n" +
+				"    - name: Home\n" +
+				"      columns:\n" +
+				"          - size: full\n" +
+				"            widgets:\n" +
+				"                - type: calendar-legacy\n" +
+				"                  start-sunday: true\n",
+			expected: "pages:\n" +
+				"    - name: Home\n" +
+				"      columns:\n" +
+				"          - size: full\n" +
+				"            widgets:\n" +
+				"                - type: calendar\n" +
+				"                  first-day-of-week: sunday\n",
+			wantLines: []int{6, 7},
+		},
+		{
+			name:      "crlf line endings",
+			original:  "widgets:\r\n  - type: stocks\r\n    stocks:\r\n      - symbol: SPY\r\n",
+			expected:  "widgets:\r\n  - type: markets\r\n    markets:\r\n      - symbol: SPY\r\n",
+			wantLines: []int{2, 3},
+		},
+		{
+			name:      "text only changes with crlf line endings",
+			original:  "widgets:\r\n  - !include: other.yml\r\n",
+			expected:  "widgets:\r\n  - $include: other.yml\r\n",
+			wantLines: []int{2},
+		},
+		{
+			name:      "quoted values",
+			original:  "widgets:\n  - type: \"stocks\"\n  - type: 'calendar-legacy'\n",
+			expected:  "widgets:\n  - type: \"markets\"\n  - type: 'calendar'\n",
+			wantLines: []int{2, 3},
+		},
+		{
+			name:      "removed entry spanning several lines",
+			original:  "widgets:\n  - type: calendar-legacy\n    start-sunday:\n      false\n    title: Calendar\n",
+			expected:  "widgets:\n  - type: calendar\n    title: Calendar\n",
+			wantLines: []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "glance.yml")
+			if err := os.WriteFile(filePath, []byte(tt.original), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := migrateConfigFile(filePath)
+			if err != nil {
+				t.Fatalf("migrateConfigFile() error = %v", err)
+			}
+
+			if string(file.migrated) != tt.expected {
+				t.Errorf("migrateConfigFile() migrated = %q, want %q", file.migrated, tt.expected)
+			}
+
+			var lines []int
+			for _, change := range file.changes {
+				lines = append(lines, change.line)
+			}
+
+			if !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("migrateConfigFile() change lines = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigFileRejectsFlowMappings(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "glance.yml")
+	original := "widgets:\n  - {type: calendar-legacy, start-sunday: false}\n"
+
+	if err := os.WriteFile(filePath, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrateConfigFile(filePath); err == nil {
+		t.Error("migrateConfigFile() error = nil, want an error")
+	}
+}
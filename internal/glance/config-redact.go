@@ -0,0 +1,157 @@
+package glance
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields holding secrets are marked with a `secret:"true"` struct tag, if the field
+// is a map or a slice then all of its values are treated as secrets
+const configSecretTag = "secret"
+
+const redactedConfigValue = "[REDACTED]"
+
+// Values that only reference a variable don't contain the secret itself and
+// are useful to see when debugging, so they're left as is
+var configSoleVariablePattern = regexp.MustCompile(`^\$\{(?:[a-zA-Z]+:)?[a-zA-Z0-9_-]+\}$`)
+
+var widgetInterfaceType = reflect.TypeFor[widget]()
+
+// Returns the config with the values of all fields marked as secrets replaced,
+// expects contents that have had their includes expanded
+func redactConfigSecrets(contents []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	if len(document.Content) == 0 {
+		return contents, nil
+	}
+
+	redactYAMLNode(document.Content[0], reflect.TypeFor[config]())
+
+	var encoded bytes.Buffer
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&document); err != nil {
+		return nil, fmt.Errorf("encoding config: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("encoding config: %w", err)
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// Walks the node alongside the type it would get decoded into
+func redactYAMLNode(node *yaml.Node, t reflect.Type) {
+	if node.Kind == yaml.AliasNode || node.Kind == yaml.DocumentNode {
+		return
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind == yaml.MappingNode {
+			redactYAMLStructFields(node, t)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				redactYAMLNode(item, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				redactYAMLNode(node.Content[i], t.Elem())
+			}
+		}
+	case reflect.Interface:
+		if t != widgetInterfaceType || node.Kind != yaml.MappingNode {
+			return
+		}
+
+		_, typeNode := yamlMappingEntry(node, "type")
+		if typeNode == nil {
+			return
+		}
+
+		widget, err := newWidget(typeNode.Value)
+		if err != nil {
+			return
+		}
+
+		redactYAMLNode(node, reflect.TypeOf(widget))
+	}
+}
+
+func redactYAMLStructFields(node *yaml.Node, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		if options == "inline" {
+			redactYAMLNode(node, field.Type)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		_, value := yamlMappingEntry(node, name)
+		if value == nil {
+			continue
+		}
+
+		if field.Tag.Get(configSecretTag) == "true" {
+			redactYAMLSecretNode(value)
+		} else {
+			redactYAMLNode(value, field.Type)
+		}
+	}
+}
+
+func redactYAMLSecretNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" || configSoleVariablePattern.MatchString(node.Value) {
+			return
+		}
+
+		node.Tag = "!!str"
+		node.Style = 0
+		node.Value = redactedConfigValue
+	case yaml.AliasNode:
+		// the anchored value would otherwise still be visible where it's defined
+		redactYAMLSecretNode(node.Alias)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			redactYAMLSecretNode(item)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			redactYAMLSecretNode(node.Content[i])
+		}
+	}
+}
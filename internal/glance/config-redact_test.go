@@ -0,0 +1,73 @@
+package glance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactConfigSecrets(t *testing.T) {
+	original := `auth:
+  secret-key: secret-key-value
+  users:
+    admin:
+      password: password-value
+    other:
+      password: ${secret:other-password}
+notifications:
+  channels:
+    phone:
+      type: ntfy
+      topic: alerts
+      token: ntfy-token-value
+pages:
+  - name: Home
+    columns:
+      - size: full
+        widgets:
+          - type: group
+            widgets:
+              - type: custom-api
+                url: https://example.com
+                headers:
+                  Authorization: header-value
+              - type: reddit
+                subreddit: selfhosted
+                app-auth:
+                  secret: reddit-secret-value
+          - type: dns-stats
+            service: adguard
+            username: admin-username
+            password: dns-password-value
+`
+
+	redacted, err := redactConfigSecrets([]byte(original))
+	if err != nil {
+		t.Fatalf("redacting config: %v", err)
+	}
+
+	output := string(redacted)
+
+	for _, secret := range []string{
+		"secret-key-value",
+		"password-value",
+		"header-value",
+		"reddit-secret-value",
+		"dns-password-value",
+		"ntfy-token-value",
+	} {
+		if strings.Contains(output, secret) {
+			t.Errorf("expected %q to be redacted, got:\n%s", secret, output)
+		}
+	}
+
+	for _, kept := range []string{
+		"${secret:other-password}",
+		"https://example.com",
+		"admin-username",
+		"selfhosted",
+	} {
+		if !strings.Contains(output, kept) {
+			t.Errorf("expected %q to be kept, got:\n%s", kept, output)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package glance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	remoteConfigIncludesCacheDirName  = ".remote-includes-cache"
+	remoteConfigIncludeMaxSize        = 5 << 20 // 5MB
+	remoteConfigIncludeDefaultRefresh = time.Hour
+	// How often the config watcher checks whether any remote includes are due for a refresh
+	remoteConfigIncludesCheckInterval = time.Minute
+)
+
+type remoteConfigInclude struct {
+	URL     string            `yaml:"url"`
+	SHA256  string            `yaml:"sha256"`
+	Headers map[string]string `yaml:"headers"`
+	Refresh durationField     `yaml:"refresh"`
+}
+
+type remoteConfigIncludeCacheEntry struct {
+	contents  []byte
+	fetchedAt time.Time
+	// Whether the last attempt at fetching failed, used to avoid logging the same error on every check
+	failing bool
+}
+
+var remoteConfigIncludesCache = struct {
+	sync.Mutex
+	entries map[string]*remoteConfigIncludeCacheEntry
+	// Closed once the fetch of a URL that's in progress completes, so that other includes
+	// of the same URL can wait for it without the lock being held across the request
+	inFlight map[string]chan struct{}
+}{
+	entries:  make(map[string]*remoteConfigIncludeCacheEntry),
+	inFlight: make(map[string]chan struct{}),
+}
+
+func isRemoteConfigIncludeSource(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+func sameURLHost(a, b string) bool {
+	aURL, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	bURL, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return aURL.Scheme == bURL.Scheme && aURL.Host == bURL.Host
+}
+
+func remoteConfigIncludesCacheDir(configAbsPath string) string {
+	if stat, err := os.Stat(configAbsPath); err == nil && stat.IsDir() {
+		return filepath.Join(configAbsPath, remoteConfigIncludesCacheDirName)
+	}
+
+	return filepath.Join(filepath.Dir(configAbsPath), remoteConfigIncludesCacheDirName)
+}
+
+// Returns nil without an error if the include refers to a local file. Includes are either a
+// plain URL or a single line mapping with the url and its options, such as:
+//
+//	$include: { url: https://example.com/glance.yml, headers: { Authorization: "Bearer ${TOKEN}" } }
+func parseRemoteConfigInclude(target string, source string) (*remoteConfigInclude, error) {
+	include := &remoteConfigInclude{}
+	isMapping := strings.HasPrefix(target, "{")
+
+	if isMapping || isRemoteConfigIncludeSource(target) {
+		// variables have to be replaced here since the rest of the config
+		// only has them replaced after all includes have been expanded
+		replaced, err := parseConfigVariables([]byte(target))
+		if err != nil {
+			return nil, err
+		}
+		target = string(replaced)
+	}
+
+	if isMapping {
+		if err := yaml.Unmarshal([]byte(target), include); err != nil {
+			return nil, fmt.Errorf("parsing include options: %w", err)
+		}
+
+		if include.URL == "" {
+			return nil, errors.New("include is missing a url")
+		}
+	} else {
+		include.URL = target
+	}
+
+	sourceIsRemote := isRemoteConfigIncludeSource(source)
+
+	if !isRemoteConfigIncludeSource(include.URL) {
+		if !sourceIsRemote {
+			if include.SHA256 != "" || len(include.Headers) > 0 {
+				return nil, errors.New("include options are only supported for remote includes")
+			}
+
+			return nil, nil
+		}
+
+		if filepath.IsAbs(include.URL) {
+			return nil, fmt.Errorf("remote include can not include local file %s", include.URL)
+		}
+
+		// relative includes within a remote file are resolved against its URL
+		sourceURL, err := url.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("parsing url of %s: %w", source, err)
+		}
+
+		relativeURL, err := url.Parse(include.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing include url %s: %w", include.URL, err)
+		}
+
+		include.URL = sourceURL.ResolveReference(relativeURL).String()
+	}
+
+	if _, err := url.Parse(include.URL); err != nil {
+		return nil, fmt.Errorf("parsing include url %s: %w", include.URL, err)
+	}
+
+	if include.Refresh <= 0 {
+		include.Refresh = durationField(remoteConfigIncludeDefaultRefresh)
+	}
+
+	include.SHA256 = strings.ToLower(strings.TrimPrefix(include.SHA256, "sha256:"))
+
+	return include, nil
+}
+
+// Returns the contents of the include, fetching them only when the previously fetched contents
+// are older than the refresh interval. If fetching fails, the last successfully fetched contents
+// are used instead, including ones cached on disk by a previous run, so that a temporarily
+// unreachable remote doesn't prevent Glance from starting.
+func (i *remoteConfigInclude) fetch(cacheDir string) ([]byte, error) {
+	cache := &remoteConfigIncludesCache
+	var cacheFilePath string
+
+	if cacheDir != "" {
+		urlHash := sha256.Sum256([]byte(i.URL))
+		cacheFilePath = filepath.Join(cacheDir, hex.EncodeToString(urlHash[:])+".yml")
+	}
+
+	cache.Lock()
+	for {
+		done, fetching := cache.inFlight[i.URL]
+		if !fetching {
+			break
+		}
+
+		cache.Unlock()
+		<-done
+		cache.Lock()
+	}
+
+	entry := i.cachedEntry(cacheFilePath)
+
+	if entry != nil && i.matchesChecksum(entry.contents) {
+		// contents pinned by a checksum never need to be fetched again
+		if i.SHA256 != "" || time.Since(entry.fetchedAt) < time.Duration(i.Refresh) {
+			cache.Unlock()
+			return entry.contents, nil
+		}
+	}
+
+	done := make(chan struct{})
+	cache.inFlight[i.URL] = done
+	cache.Unlock()
+
+	contents, err := i.fetchFromRemote()
+
+	cache.Lock()
+	delete(cache.inFlight, i.URL)
+	close(done)
+
+	if err != nil {
+		defer cache.Unlock()
+
+		if entry == nil || !i.matchesChecksum(entry.contents) {
+			return nil, fmt.Errorf("fetching remote include %s: %w", i.URL, err)
+		}
+
+		if !entry.failing {
+			log.Printf("Failed to fetch remote include %s, using previously fetched contents: %v", i.URL, err)
+			entry.failing = true
+		}
+
+		return entry.contents, nil
+	}
+
+	if entry != nil && entry.failing {
+		log.Printf("Remote include %s can be fetched again", i.URL)
+	}
+
+	cache.entries[i.URL] = &remoteConfigIncludeCacheEntry{contents: contents, fetchedAt: time.Now()}
+	cache.Unlock()
+
+	if cacheFilePath != "" {
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			log.Printf("Failed to create remote includes cache directory: %v", err)
+		} else if err := os.WriteFile(cacheFilePath, contents, 0o600); err != nil {
+			log.Printf("Failed to cache remote include %s: %v", i.URL, err)
+		}
+	}
+
+	return contents, nil
+}
+
+// Returns the entry of the include within the in-memory cache, loading it from the cache
+// on disk if it isn't there yet. Must be called with the lock of the cache held.
+func (i *remoteConfigInclude) cachedEntry(cacheFilePath string) *remoteConfigIncludeCacheEntry {
+	cache := &remoteConfigIncludesCache
+
+	if entry, exists := cache.entries[i.URL]; exists || cacheFilePath == "" {
+		return entry
+	}
+
+	stat, err := os.Stat(cacheFilePath)
+	if err != nil {
+		return nil
+	}
+
+	contents, err := os.ReadFile(cacheFilePath)
+	if err != nil || !i.matchesChecksum(contents) {
+		return nil
+	}
+
+	entry := &remoteConfigIncludeCacheEntry{contents: contents, fetchedAt: stat.ModTime()}
+	cache.entries[i.URL] = entry
+
+	return entry
+}
+
+func (i *remoteConfigInclude) fetchFromRemote() ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, i.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range i.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(response.Body, remoteConfigIncludeMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if len(contents) > remoteConfigIncludeMaxSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", remoteConfigIncludeMaxSize)
+	}
+
+	if !i.matchesChecksum(contents) {
+		return nil, errors.New("contents do not match the sha256 checksum")
+	}
+
+	return contents, nil
+}
+
+func (i *remoteConfigInclude) matchesChecksum(contents []byte) bool {
+	if i.SHA256 == "" {
+		return true
+	}
+
+	hash := sha256.Sum256(contents)
+	return hex.EncodeToString(hash[:]) == i.SHA256
+}
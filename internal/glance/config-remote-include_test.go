@@ -0,0 +1,251 @@
+package glance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRemoteConfigInclude(t *testing.T) {
+	t.Setenv("GLANCE_TEST_TOKEN", "secret")
+
+	tests := []struct {
+		name        string
+		target      string
+		source      string
+		wantURL     string
+		wantHeaders map[string]string
+		wantSHA256  string
+		wantRefresh time.Duration
+		wantErr     bool
+	}{
+		{
+			name:   "local file",
+			target: "widgets.yml",
+			source: "/config/glance.yml",
+		},
+		{
+			name:        "plain url",
+			target:      "https://example.com/glance.yml",
+			source:      "/config/glance.yml",
+			wantURL:     "https://example.com/glance.yml",
+			wantRefresh: remoteConfigIncludeDefaultRefresh,
+		},
+		{
+			name:        "mapping with options",
+			target:      `{ url: https://example.com/a.yml, sha256: "sha256:ABC", refresh: 10m, headers: { Authorization: "Bearer ${GLANCE_TEST_TOKEN}" } }`,
+			source:      "/config/glance.yml",
+			wantURL:     "https://example.com/a.yml",
+			wantHeaders: map[string]string{"Authorization": "Bearer secret"},
+			wantSHA256:  "abc",
+			wantRefresh: 10 * time.Minute,
+		},
+		{
+			name:        "relative include within remote file",
+			target:      "../widgets/clock.yml",
+			source:      "https://example.com/pages/home.yml",
+			wantURL:     "https://example.com/widgets/clock.yml",
+			wantRefresh: remoteConfigIncludeDefaultRefresh,
+		},
+		{
+			name:    "absolute path within remote file",
+			target:  "/etc/passwd",
+			source:  "https://example.com/glance.yml",
+			wantErr: true,
+		},
+		{
+			name:    "options for local file",
+			target:  `{ url: widgets.yml, sha256: abc }`,
+			source:  "/config/glance.yml",
+			wantErr: true,
+		},
+		{
+			name:    "mapping without url",
+			target:  `{ sha256: abc }`,
+			source:  "/config/glance.yml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, err := parseRemoteConfigInclude(tt.target, tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRemoteConfigInclude() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if tt.wantURL == "" {
+				if include != nil {
+					t.Errorf("parseRemoteConfigInclude() = %+v, want nil for a local file", include)
+				}
+				return
+			}
+
+			if include.URL != tt.wantURL || include.SHA256 != tt.wantSHA256 || time.Duration(include.Refresh) != tt.wantRefresh {
+				t.Errorf("parseRemoteConfigInclude() = %+v, want url %s, sha256 %q and refresh %v", include, tt.wantURL, tt.wantSHA256, tt.wantRefresh)
+			}
+
+			if len(include.Headers) != len(tt.wantHeaders) {
+				t.Errorf("headers = %v, want %v", include.Headers, tt.wantHeaders)
+			}
+
+			for key, value := range tt.wantHeaders {
+				if include.Headers[key] != value {
+					t.Errorf("headers = %v, want %v", include.Headers, tt.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoteConfigIncludeCache(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte("- type: clock"))
+	}))
+	defer server.Close()
+
+	include := &remoteConfigInclude{URL: server.URL + "/cache.yml", Refresh: durationField(time.Hour)}
+	cacheDir := t.TempDir()
+
+	expire := func() {
+		remoteConfigIncludesCache.Lock()
+		remoteConfigIncludesCache.entries[include.URL].fetchedAt = time.Now().Add(-2 * time.Hour)
+		remoteConfigIncludesCache.Unlock()
+	}
+
+	steps := []struct {
+		name         string
+		before       func()
+		wantRequests int32
+		wantErr      bool
+	}{
+		{"first fetch", nil, 1, false},
+		{"within refresh interval", nil, 1, false},
+		{"after refresh interval", expire, 2, false},
+		{"failing with cached contents", func() { expire(); failing.Store(true) }, 3, false},
+		{"failure is retried on the next fetch", nil, 4, false},
+		{"recovered", func() { failing.Store(false) }, 5, false},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		contents, err := include.fetch(cacheDir)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: fetch() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+
+		if string(contents) != "- type: clock" {
+			t.Errorf("%s: contents = %q", step.name, contents)
+		}
+
+		if got := requests.Load(); got != step.wantRequests {
+			t.Errorf("%s: requests = %d, want %d", step.name, got, step.wantRequests)
+		}
+	}
+
+	// a new run without anything in memory falls back to the contents cached on disk
+	remoteConfigIncludesCache.Lock()
+	delete(remoteConfigIncludesCache.entries, include.URL)
+	remoteConfigIncludesCache.Unlock()
+	failing.Store(true)
+
+	if contents, err := include.fetch(cacheDir); err != nil || string(contents) != "- type: clock" {
+		t.Errorf("fetch() after restart = %q, %v, want the contents cached on disk", contents, err)
+	}
+}
+
+func TestRemoteConfigIncludeChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("- type: clock"))
+	}))
+	defer server.Close()
+
+	hash := sha256.Sum256([]byte("- type: clock"))
+
+	tests := []struct {
+		name    string
+		sha256  string
+		wantErr bool
+	}{
+		{"matching checksum", hex.EncodeToString(hash[:]), false},
+		{"mismatched checksum", "0000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include := &remoteConfigInclude{URL: server.URL + "/" + tt.name, SHA256: tt.sha256, Refresh: durationField(time.Hour)}
+
+			if _, err := include.fetch(""); (err != nil) != tt.wantErr {
+				t.Errorf("fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteConfigIncludeConcurrentFetches(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	slowInclude := &remoteConfigInclude{URL: slow.URL + "/slow.yml", Refresh: durationField(time.Hour)}
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if contents, err := slowInclude.fetch(""); err != nil || string(contents) != "slow" {
+				t.Errorf("fetch() = %q, %v", contents, err)
+			}
+		}()
+	}
+
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// other includes must not wait for the slow one
+	fastInclude := &remoteConfigInclude{URL: fast.URL + "/fast.yml", Refresh: durationField(time.Hour)}
+	if contents, err := fastInclude.fetch(""); err != nil || string(contents) != "fast" {
+		t.Errorf("fetch() = %q, %v", contents, err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests for the same url = %d, want 1", got)
+	}
+}
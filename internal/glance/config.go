@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -34,10 +35,11 @@ type config struct {
 		Proxied    bool   `yaml:"proxied"`
 		AssetsPath string `yaml:"assets-path"`
 		BaseURL    string `yaml:"base-url"`
+		DataPath   string `yaml:"data-path"`
 	} `yaml:"server"`
 
 	Auth struct {
-		SecretKey string           `yaml:"secret-key"`
+		SecretKey string           `yaml:"secret-key" secret:"true"`
 		Users     map[string]*user `yaml:"users"`
 	} `yaml:"auth"`
 
@@ -65,13 +67,18 @@ type config struct {
 		AppBackgroundColor string        `yaml:"app-background-color"`
 	} `yaml:"branding"`
 
+	Notifications notificationsConfig `yaml:"notifications"`
+
+	StatusPages []statusPage `yaml:"status-pages"`
+
 	Pages []page `yaml:"pages"`
 }
 
 type user struct {
-	Password           string `yaml:"password"`
-	PasswordHashString string `yaml:"password-hash"`
+	Password           string `yaml:"password" secret:"true"`
+	PasswordHashString string `yaml:"password-hash" secret:"true"`
 	PasswordHash       []byte `yaml:"-"`
+	Admin              bool   `yaml:"admin"`
 }
 
 type page struct {
@@ -239,16 +246,201 @@ func formatWidgetInitError(err error, w widget) error {
 
 var configIncludePattern = regexp.MustCompile(`(?m)^([ \t]*)(?:-[ \t]*)?(?:!|\$)include:[ \t]*(.+)$`)
 
+// Where a line of the config with its includes expanded originally came from,
+// a zero line means that the line was added by Glance rather than read from a file
+type configLineOrigin struct {
+	filePath string
+	line     int
+}
+
+type configFileReader func(filePath string) ([]byte, error)
+
+type yamlIncludesParser struct {
+	readFile configFileReader
+	includes map[string]struct{}
+	// Where fetched remote includes get stored so that the config can
+	// still be loaded when they can't be reached, empty disables caching
+	remoteCacheDir string
+	// Headers of remote includes by URL, passed on to relative includes within them
+	remoteHeaders map[string]map[string]string
+}
+
 func parseYAMLIncludes(mainFilePath string) ([]byte, map[string]struct{}, error) {
-	return recursiveParseYAMLIncludes(mainFilePath, nil, 0)
+	contents, includes, _, err := parseYAMLIncludesWithReader(mainFilePath, os.ReadFile)
+	return contents, includes, err
+}
+
+// Same as parseYAMLIncludes but reads files using the provided function and additionally
+// returns the origin of each line in the parsed contents, used to report errors accurately
+func parseYAMLIncludesWithReader(mainFilePath string, readFile configFileReader) ([]byte, map[string]struct{}, []configLineOrigin, error) {
+	parser := &yamlIncludesParser{
+		readFile:      readFile,
+		includes:      make(map[string]struct{}),
+		remoteHeaders: make(map[string]map[string]string),
+	}
+
+	if absPath, err := filepath.Abs(mainFilePath); err == nil {
+		parser.remoteCacheDir = remoteConfigIncludesCacheDir(absPath)
+	}
+
+	var contents []byte
+	var origins []configLineOrigin
+	var err error
+
+	if stat, statErr := os.Stat(mainFilePath); statErr == nil && stat.IsDir() {
+		contents, origins, err = parser.parseConfigDirectory(mainFilePath)
+	} else {
+		contents, origins, err = parser.recursiveParseYAMLIncludes(mainFilePath, 0)
+	}
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return contents, parser.includes, origins, nil
+}
+
+const (
+	configDirectoryMainFile = "glance.yml"
+	configDirectoryPagesDir = "pages"
+)
+
+var configTopLevelPagesPattern = regexp.MustCompile(`(?m)^pages[ \t]*:`)
+
+// Returns the path of the main file and the paths of all page files, sorted
+// by their position property, followed by the ones without one sorted by name
+func configDirectoryFilePaths(dirPath string, readFile configFileReader) (string, []string, error) {
+	mainFilePath := filepath.Join(dirPath, configDirectoryMainFile)
+	pagesDirPath := filepath.Join(dirPath, configDirectoryPagesDir)
+
+	entries, err := os.ReadDir(pagesDirPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading pages directory: %w", err)
+	}
+
+	type pageFile struct {
+		path     string
+		position *int
+	}
+
+	pageFiles := make([]pageFile, 0, len(entries))
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		filePath := filepath.Join(pagesDirPath, entry.Name())
+		contents, err := readFile(filePath)
+		if err != nil {
+			return "", nil, fmt.Errorf("reading %s: %w", filePath, err)
+		}
+
+		var meta struct {
+			Position *int `yaml:"position"`
+		}
+
+		if err := yaml.Unmarshal(contents, &meta); err != nil {
+			return "", nil, fmt.Errorf("parsing %s: %w", filePath, err)
+		}
+
+		pageFiles = append(pageFiles, pageFile{path: filePath, position: meta.Position})
+	}
+
+	// os.ReadDir already returns the entries sorted by name
+	slices.SortStableFunc(pageFiles, func(a, b pageFile) int {
+		if a.position == nil || b.position == nil {
+			return ternary(a.position == nil, 1, 0) - ternary(b.position == nil, 1, 0)
+		}
+
+		return *a.position - *b.position
+	})
+
+	pageFilePaths := make([]string, len(pageFiles))
+	for i := range pageFiles {
+		pageFilePaths[i] = pageFiles[i].path
+	}
+
+	return mainFilePath, pageFilePaths, nil
 }
 
-func recursiveParseYAMLIncludes(mainFilePath string, includes map[string]struct{}, depth int) ([]byte, map[string]struct{}, error) {
+// Combines the glance.yml within the directory with every file in its pages directory, each
+// page file gets added as a single page and can use includes the same way as the main file
+func (p *yamlIncludesParser) parseConfigDirectory(dirPath string) ([]byte, []configLineOrigin, error) {
+	mainFilePath, pageFilePaths, err := configDirectoryFilePaths(dirPath, p.readFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contents, mainOrigins, err := p.recursiveParseYAMLIncludes(mainFilePath, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if configTopLevelPagesPattern.Match(contents) {
+		return nil, nil, fmt.Errorf(
+			"%s must not define pages when using a config directory, add them to the %s directory instead",
+			configDirectoryMainFile, configDirectoryPagesDir,
+		)
+	}
+
+	mainFileAbsPath, err := filepath.Abs(mainFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting absolute path of %s: %w", mainFilePath, err)
+	}
+
+	pagesDirAbsPath, err := filepath.Abs(filepath.Join(dirPath, configDirectoryPagesDir))
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting absolute path of pages directory: %w", err)
+	}
+
+	// watching the directories allows picking up newly created files
+	p.includes[mainFileAbsPath] = struct{}{}
+	p.includes[pagesDirAbsPath] = struct{}{}
+
+	lines := strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+	origins := mainOrigins[:len(lines)]
+
+	lines = append(lines, "pages:")
+	origins = append(origins, configLineOrigin{})
+
+	for _, pageFilePath := range pageFilePaths {
+		pageContents, pageOrigins, err := p.recursiveParseYAMLIncludes(pageFilePath, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pageFileAbsPath, err := filepath.Abs(pageFilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting absolute path of %s: %w", pageFilePath, err)
+		}
+
+		p.includes[pageFileAbsPath] = struct{}{}
+
+		pageLines := strings.Split(strings.TrimRight(string(pageContents), "\n"), "\n")
+
+		lines = append(lines, "  -")
+		origins = append(origins, configLineOrigin{})
+
+		for i := range pageLines {
+			lines = append(lines, "    "+pageLines[i])
+		}
+		origins = append(origins, pageOrigins[:len(pageLines)]...)
+	}
+
+	lines = append(lines, "")
+	origins = append(origins, configLineOrigin{})
+
+	return []byte(strings.Join(lines, "\n")), origins, nil
+}
+
+func (p *yamlIncludesParser) recursiveParseYAMLIncludes(mainFilePath string, depth int) ([]byte, []configLineOrigin, error) {
 	if depth > CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT {
 		return nil, nil, fmt.Errorf("recursion depth limit of %d reached", CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT)
 	}
 
-	mainFileContents, err := os.ReadFile(mainFilePath)
+	mainFileContents, err := p.readFile(mainFilePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("reading %s: %w", mainFilePath, err)
 	}
@@ -257,49 +449,76 @@ func recursiveParseYAMLIncludes(mainFilePath string, includes map[string]struct{
 	if err != nil {
 		return nil, nil, fmt.Errorf("getting absolute path of %s: %w", mainFilePath, err)
 	}
-	mainFileDir := filepath.Dir(mainFileAbsPath)
 
-	if includes == nil {
-		includes = make(map[string]struct{})
+	return p.expandYAMLIncludes(mainFileContents, mainFileAbsPath, depth)
+}
+
+func (p *yamlIncludesParser) parseRemoteYAMLInclude(include *remoteConfigInclude, depth int) ([]byte, []configLineOrigin, error) {
+	if depth > CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT {
+		return nil, nil, fmt.Errorf("recursion depth limit of %d reached", CONFIG_INCLUDE_RECURSION_DEPTH_LIMIT)
 	}
-	var includesLastErr error
 
-	mainFileContents = configIncludePattern.ReplaceAllFunc(mainFileContents, func(match []byte) []byte {
-		if includesLastErr != nil {
-			return nil
-		}
+	contents, err := include.fetch(p.remoteCacheDir)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		matches := configIncludePattern.FindSubmatch(match)
-		if len(matches) != 3 {
-			includesLastErr = fmt.Errorf("invalid include match: %v", matches)
-			return nil
+	return p.expandYAMLIncludes(contents, include.URL, depth)
+}
+
+// Replaces every include directive within contents with the contents of the file it refers to,
+// source is either the absolute path or the URL that the contents were read from
+func (p *yamlIncludesParser) expandYAMLIncludes(contents []byte, source string, depth int) ([]byte, []configLineOrigin, error) {
+	lines := strings.Split(string(contents), "\n")
+	parsed := make([]string, 0, len(lines))
+	origins := make([]configLineOrigin, 0, len(lines))
+
+	for i, line := range lines {
+		matches := configIncludePattern.FindStringSubmatch(line)
+		if matches == nil {
+			parsed = append(parsed, line)
+			origins = append(origins, configLineOrigin{filePath: source, line: i + 1})
+			continue
 		}
 
-		indent := string(matches[1])
-		includeFilePath := strings.TrimSpace(string(matches[2]))
-		if !filepath.IsAbs(includeFilePath) {
-			includeFilePath = filepath.Join(mainFileDir, includeFilePath)
+		indent := matches[1]
+		includeTarget := strings.TrimSpace(matches[2])
+
+		remoteInclude, err := parseRemoteConfigInclude(includeTarget, source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s line %d: %w", source, i+1, err)
 		}
 
 		var fileContents []byte
-		var err error
+		var fileOrigins []configLineOrigin
 
-		includes[includeFilePath] = struct{}{}
+		if remoteInclude != nil {
+			if len(remoteInclude.Headers) == 0 && sameURLHost(source, remoteInclude.URL) {
+				remoteInclude.Headers = p.remoteHeaders[source]
+			}
 
-		fileContents, includes, err = recursiveParseYAMLIncludes(includeFilePath, includes, depth+1)
-		if err != nil {
-			includesLastErr = err
-			return nil
+			p.remoteHeaders[remoteInclude.URL] = remoteInclude.Headers
+			p.includes[remoteInclude.URL] = struct{}{}
+			fileContents, fileOrigins, err = p.parseRemoteYAMLInclude(remoteInclude, depth+1)
+		} else {
+			includeFilePath := includeTarget
+			if !filepath.IsAbs(includeFilePath) {
+				includeFilePath = filepath.Join(filepath.Dir(source), includeFilePath)
+			}
+
+			p.includes[includeFilePath] = struct{}{}
+			fileContents, fileOrigins, err = p.recursiveParseYAMLIncludes(includeFilePath, depth+1)
 		}
 
-		return []byte(prefixStringLines(indent, string(fileContents)))
-	})
+		if err != nil {
+			return nil, nil, err
+		}
 
-	if includesLastErr != nil {
-		return nil, nil, includesLastErr
+		parsed = append(parsed, prefixStringLines(indent, string(fileContents)))
+		origins = append(origins, fileOrigins...)
 	}
 
-	return mainFileContents, includes, nil
+	return []byte(strings.Join(parsed, "\n")), origins, nil
 }
 
 func configFilesWatcher(
@@ -324,12 +543,20 @@ func configFilesWatcher(
 
 	updateWatchedFiles := func(previousWatched map[string]struct{}, newWatched map[string]struct{}) {
 		for filePath := range previousWatched {
+			if isRemoteConfigIncludeSource(filePath) {
+				continue
+			}
+
 			if _, ok := newWatched[filePath]; !ok {
 				watcher.Remove(filePath)
 			}
 		}
 
 		for filePath := range newWatched {
+			if isRemoteConfigIncludeSource(filePath) {
+				continue
+			}
+
 			if _, ok := previousWatched[filePath]; !ok {
 				if err := watcher.Add(filePath); err != nil {
 					log.Printf(
@@ -388,14 +615,34 @@ func configFilesWatcher(
 		delete(lastIncludes, fileAbsPath)
 	}
 
+	// remote includes can't be watched, so they get re-fetched periodically instead, with
+	// the fetching itself only happening once their refresh interval has passed
+	remoteIncludesTicker := time.NewTicker(remoteConfigIncludesCheckInterval)
+	hasRemoteIncludes := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for filePath := range lastIncludes {
+			if isRemoteConfigIncludeSource(filePath) {
+				return true
+			}
+		}
+
+		return false
+	}
+
 	go func() {
 		for {
 			select {
+			case <-remoteIncludesTicker.C:
+				if hasRemoteIncludes() {
+					parseAndCompareBeforeCallback()
+				}
 			case event, isOpen := <-watcher.Events:
 				if !isOpen {
 					return
 				}
-				if event.Has(fsnotify.Write) {
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
 					debouncedParseAndCompareBeforeCallback()
 				} else if event.Has(fsnotify.Rename) {
 					// on linux the file will no longer be watched after a rename, on windows
@@ -440,6 +687,8 @@ func configFilesWatcher(
 			debounceTimer.Stop()
 		}
 
+		remoteIncludesTicker.Stop()
+
 		return watcher.Close()
 	}, nil
 }
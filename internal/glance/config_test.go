@@ -0,0 +1,199 @@
+package glance
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeTestConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestConfigDirectoryFilePaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "sorted by name without positions",
+			files: map[string]string{"pages/b.yml": "name: B", "pages/a.yaml": "name: A"},
+			want:  []string{"a.yaml", "b.yml"},
+		},
+		{
+			name: "positions come first",
+			files: map[string]string{
+				"pages/a.yml": "name: A",
+				"pages/b.yml": "name: B\nposition: 2",
+				"pages/c.yml": "name: C\nposition: 1",
+			},
+			want: []string{"c.yml", "b.yml", "a.yml"},
+		},
+		{
+			name: "equal positions keep name order",
+			files: map[string]string{
+				"pages/b.yml": "position: 1",
+				"pages/a.yml": "position: 1",
+				"pages/c.yml": "position: 0",
+			},
+			want: []string{"c.yml", "a.yml", "b.yml"},
+		},
+		{
+			name: "other files and directories are skipped",
+			files: map[string]string{
+				"pages/a.yml":         "name: A",
+				"pages/notes.txt":     "not a page",
+				"pages/nested/b.yml":  "name: B",
+				"pages/.hidden.yml~":  "backup",
+				"pages/c.yml.example": "example",
+			},
+			want: []string{"a.yml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestConfigFiles(t, tt.files)
+
+			mainFilePath, pageFilePaths, err := configDirectoryFilePaths(dir, os.ReadFile)
+			if err != nil {
+				t.Fatalf("configDirectoryFilePaths() error = %v", err)
+			}
+
+			if mainFilePath != filepath.Join(dir, configDirectoryMainFile) {
+				t.Errorf("main file = %s, want glance.yml within %s", mainFilePath, dir)
+			}
+
+			got := make([]string, len(pageFilePaths))
+			for i := range pageFilePaths {
+				got[i] = filepath.Base(pageFilePaths[i])
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("page files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigDirectoryFilePathsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"missing pages directory", map[string]string{"glance.yml": "server:\n  port: 8080"}},
+		{"invalid page yaml", map[string]string{"pages/a.yml": "name: [unclosed"}},
+		{"non-numeric position", map[string]string{"pages/a.yml": "position: first"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestConfigFiles(t, tt.files)
+
+			if _, _, err := configDirectoryFilePaths(dir, os.ReadFile); err == nil {
+				t.Error("configDirectoryFilePaths() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestParseConfigDirectory(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{
+		"glance.yml":           "server:\n  port: 8080\n",
+		"pages/home.yml":       "name: Home\nposition: 1\ncolumns:\n  - size: full\n    widgets:\n      - $include: ../widgets/clock.yml\n",
+		"pages/about.yml":      "name: About\n",
+		"widgets/clock.yml":    "- type: clock\n  hour-format: 24h",
+		"pages/ignored.yml.md": "name: Ignored",
+	})
+
+	contents, includes, origins, err := parseYAMLIncludesWithReader(dir, os.ReadFile)
+	if err != nil {
+		t.Fatalf("parsing config directory: %v", err)
+	}
+
+	want := `server:
+  port: 8080
+pages:
+  -
+    name: Home
+    position: 1
+    columns:
+      - size: full
+        widgets:
+          - type: clock
+            hour-format: 24h
+  -
+    name: About
+`
+
+	if string(contents) != want {
+		t.Fatalf("unexpected contents:\n%s", unifiedDiff("glance.yml", strings.Split(want, "\n"), strings.Split(string(contents), "\n")))
+	}
+
+	lines := strings.Split(string(contents), "\n")
+	if len(origins) != len(lines) {
+		t.Fatalf("got %d origins for %d lines", len(origins), len(lines))
+	}
+
+	abs := func(name string) string {
+		path, _ := filepath.Abs(filepath.Join(dir, name))
+		return path
+	}
+
+	tests := []struct {
+		line string
+		want configLineOrigin
+	}{
+		{"  port: 8080", configLineOrigin{abs("glance.yml"), 2}},
+		{"pages:", configLineOrigin{}},
+		{"    name: Home", configLineOrigin{abs("pages/home.yml"), 1}},
+		{"            hour-format: 24h", configLineOrigin{abs("widgets/clock.yml"), 2}},
+		{"    name: About", configLineOrigin{abs("pages/about.yml"), 1}},
+	}
+
+	for _, tt := range tests {
+		i := slices.Index(lines, tt.line)
+		if i == -1 {
+			t.Errorf("line %q not found", tt.line)
+			continue
+		}
+
+		if origins[i] != tt.want {
+			t.Errorf("origin of %q = %+v, want %+v", tt.line, origins[i], tt.want)
+		}
+	}
+
+	for _, name := range []string{"glance.yml", "pages", "pages/home.yml", "pages/about.yml"} {
+		if _, ok := includes[abs(name)]; !ok {
+			t.Errorf("expected %s to be watched, got %v", name, includes)
+		}
+	}
+}
+
+func TestParseConfigDirectoryRejectsPagesInMainFile(t *testing.T) {
+	dir := writeTestConfigFiles(t, map[string]string{
+		"glance.yml":  "pages:\n  - name: Home\n",
+		"pages/a.yml": "name: A\n",
+	})
+
+	if _, _, _, err := parseYAMLIncludesWithReader(dir, os.ReadFile); err == nil || !strings.Contains(err.Error(), "must not define pages") {
+		t.Errorf("error = %v, want one about pages being defined in glance.yml", err)
+	}
+}
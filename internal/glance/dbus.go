@@ -0,0 +1,766 @@
+package glance
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal D-Bus client which only supports what's needed to call methods on services
+// listening on a local bus, such as systemd, so that pulling in a whole library isn't needed.
+// See https://dbus.freedesktop.org/doc/dbus-specification.html for the details of the protocol.
+
+const (
+	dbusMessageTypeMethodCall   = 1
+	dbusMessageTypeMethodReturn = 2
+	dbusMessageTypeError        = 3
+
+	dbusHeaderFieldPath        = 1
+	dbusHeaderFieldInterface   = 2
+	dbusHeaderFieldMember      = 3
+	dbusHeaderFieldErrorName   = 4
+	dbusHeaderFieldReplySerial = 5
+	dbusHeaderFieldDestination = 6
+	dbusHeaderFieldSignature   = 8
+
+	// the maximum message size allowed by the specification
+	dbusMaxMessageSize = 128 << 20
+)
+
+type dbusVariant struct {
+	Signature string
+	Value     any
+}
+
+type dbusError struct {
+	Name    string
+	Message string
+}
+
+func (e *dbusError) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+
+	return e.Name + ": " + e.Message
+}
+
+type dbusConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	serial uint32
+}
+
+func systemDBusAddress() string {
+	if address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); address != "" {
+		return address
+	}
+
+	return "unix:path=/var/run/dbus/system_bus_socket"
+}
+
+func sessionDBusAddress() string {
+	if address := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); address != "" {
+		return address
+	}
+
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return "unix:path=" + filepath.Join(runtimeDir, "bus")
+	}
+
+	return "unix:path=/run/user/" + strconv.Itoa(os.Getuid()) + "/bus"
+}
+
+// Only unix sockets are supported, when multiple addresses are specified the first usable one is used
+func parseDBusAddress(address string) (network string, path string, err error) {
+	for _, entry := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(entry, ":")
+		if transport != "unix" {
+			continue
+		}
+
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "path":
+				return "unix", value, nil
+			case "abstract":
+				return "unix", "@" + value, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("no supported address found in %s, only unix sockets are supported", address)
+}
+
+func dialDBus(ctx context.Context, address string) (*dbusConn, error) {
+	network, path, err := parseDBusAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, path)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to bus: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c := &dbusConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if err := c.authenticate(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("authenticating with bus: %w", err)
+	}
+
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", ""); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("registering with bus: %w", err)
+	}
+
+	return c, nil
+}
+
+// Uses the EXTERNAL mechanism, where the bus checks the credentials of the socket's peer
+func (c *dbusConn) authenticate() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+
+	if _, err := c.conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("unexpected response: %s", strings.TrimSpace(line))
+	}
+
+	_, err = c.conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+func (c *dbusConn) Close() error {
+	return c.conn.Close()
+}
+
+// Returns the values of the reply's body, variants are returned as their value and
+// dictionaries as maps with string keys
+func (c *dbusConn) call(destination, path, iface, member, signature string, args ...any) ([]any, error) {
+	c.serial++
+	serial := c.serial
+
+	body := &dbusEncoder{}
+	types, err := splitDBusSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(types) != len(args) {
+		return nil, fmt.Errorf("signature %s expects %d arguments, got %d", signature, len(types), len(args))
+	}
+
+	for i := range types {
+		if err := body.encode(types[i], args[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := []any{
+		[]any{byte(dbusHeaderFieldPath), dbusVariant{"o", path}},
+		[]any{byte(dbusHeaderFieldInterface), dbusVariant{"s", iface}},
+		[]any{byte(dbusHeaderFieldMember), dbusVariant{"s", member}},
+		[]any{byte(dbusHeaderFieldDestination), dbusVariant{"s", destination}},
+	}
+
+	if signature != "" {
+		fields = append(fields, []any{byte(dbusHeaderFieldSignature), dbusVariant{"g", signature}})
+	}
+
+	header := &dbusEncoder{}
+	header.buf = append(header.buf, 'l', dbusMessageTypeMethodCall, 0, 1)
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(serial)
+	if err := header.encode("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	header.align(8)
+
+	if _, err := c.conn.Write(append(header.buf, body.buf...)); err != nil {
+		return nil, fmt.Errorf("sending message: %w", err)
+	}
+
+	for {
+		message, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		// signals, such as the NameAcquired one sent after Hello, are ignored
+		if message.replySerial != serial {
+			continue
+		}
+
+		if message.messageType == dbusMessageTypeError {
+			e := &dbusError{Name: message.errorName}
+			if len(message.body) > 0 {
+				e.Message, _ = message.body[0].(string)
+			}
+
+			return nil, e
+		}
+
+		if message.messageType != dbusMessageTypeMethodReturn {
+			continue
+		}
+
+		return message.body, nil
+	}
+}
+
+type dbusMessage struct {
+	messageType byte
+	replySerial uint32
+	errorName   string
+	body        []any
+}
+
+func (c *dbusConn) readMessage() (*dbusMessage, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, fixed); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid endianness in message: %q", fixed[0])
+	}
+
+	bodyLength := order.Uint32(fixed[4:8])
+	fieldsLength := order.Uint32(fixed[12:16])
+	headerLength := 16 + int(fieldsLength)
+	headerLength += (8 - headerLength%8) % 8
+
+	if uint64(headerLength)+uint64(bodyLength) > dbusMaxMessageSize {
+		return nil, errors.New("message exceeds the maximum size")
+	}
+
+	data := make([]byte, headerLength+int(bodyLength))
+	copy(data, fixed)
+	if _, err := io.ReadFull(c.reader, data[16:]); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+
+	decoder := &dbusDecoder{data: data[:headerLength], order: order, pos: 12}
+	fields, err := decoder.decode("a(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("decoding message header: %w", err)
+	}
+
+	message := &dbusMessage{messageType: fixed[1]}
+	signature := ""
+
+	for _, field := range fields.([]any) {
+		f := field.([]any)
+		switch f[0].(byte) {
+		case dbusHeaderFieldReplySerial:
+			message.replySerial, _ = f[1].(uint32)
+		case dbusHeaderFieldErrorName:
+			message.errorName, _ = f[1].(string)
+		case dbusHeaderFieldSignature:
+			signature, _ = f[1].(string)
+		}
+	}
+
+	types, err := splitDBusSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// the body starts at a multiple of 8, so alignment can be calculated from its start
+	decoder = &dbusDecoder{data: data[headerLength:], order: order}
+	message.body = make([]any, 0, len(types))
+
+	for _, t := range types {
+		value, err := decoder.decode(t)
+		if err != nil {
+			return nil, fmt.Errorf("decoding message body: %w", err)
+		}
+
+		message.body = append(message.body, value)
+	}
+
+	return message, nil
+}
+
+// Splits a signature into its complete types, such as "sa{sv}" into "s" and "a{sv}"
+func splitDBusSignature(signature string) ([]string, error) {
+	types := make([]string, 0)
+
+	for len(signature) > 0 {
+		length, err := dbusTypeLength(signature)
+		if err != nil {
+			return nil, err
+		}
+
+		types = append(types, signature[:length])
+		signature = signature[length:]
+	}
+
+	return types, nil
+}
+
+func dbusTypeLength(signature string) (int, error) {
+	if signature == "" {
+		return 0, errors.New("unexpected end of signature")
+	}
+
+	switch signature[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 'h', 's', 'o', 'g', 'v':
+		return 1, nil
+	case 'a':
+		length, err := dbusTypeLength(signature[1:])
+		return length + 1, err
+	case '(', '{':
+		closing := byte(ternary(signature[0] == '(', ')', '}'))
+		i := 1
+		for i < len(signature) && signature[i] != closing {
+			length, err := dbusTypeLength(signature[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += length
+		}
+
+		if i >= len(signature) {
+			return 0, fmt.Errorf("unterminated %c in signature", signature[0])
+		}
+
+		return i + 1, nil
+	}
+
+	return 0, fmt.Errorf("unsupported type %c in signature", signature[0])
+}
+
+func dbusAlignment(t byte) int {
+	switch t {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+
+	return 4
+}
+
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *dbusEncoder) encode(t string, value any) error {
+	invalid := func() error {
+		return fmt.Errorf("cannot encode %T as %s", value, t)
+	}
+
+	switch t[0] {
+	case 'y':
+		v, ok := value.(byte)
+		if !ok {
+			return invalid()
+		}
+		e.buf = append(e.buf, v)
+	case 'b':
+		v, ok := value.(bool)
+		if !ok {
+			return invalid()
+		}
+		e.uint32(uint32(ternary(v, 1, 0)))
+	case 'n', 'q':
+		var v uint16
+		switch n := value.(type) {
+		case int16:
+			v = uint16(n)
+		case uint16:
+			v = n
+		default:
+			return invalid()
+		}
+		e.align(2)
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+	case 'i', 'u', 'h':
+		var v uint32
+		switch n := value.(type) {
+		case int32:
+			v = uint32(n)
+		case uint32:
+			v = n
+		default:
+			return invalid()
+		}
+		e.uint32(v)
+	case 'x', 't', 'd':
+		var v uint64
+		switch n := value.(type) {
+		case int64:
+			v = uint64(n)
+		case uint64:
+			v = n
+		case float64:
+			v = math.Float64bits(n)
+		default:
+			return invalid()
+		}
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+	case 's', 'o':
+		v, ok := value.(string)
+		if !ok {
+			return invalid()
+		}
+		e.uint32(uint32(len(v)))
+		e.buf = append(append(e.buf, v...), 0)
+	case 'g':
+		v, ok := value.(string)
+		if !ok || len(v) > 255 {
+			return invalid()
+		}
+		e.buf = append(append(append(e.buf, byte(len(v))), v...), 0)
+	case 'v':
+		v, ok := value.(dbusVariant)
+		if !ok {
+			return invalid()
+		}
+		if err := e.encode("g", v.Signature); err != nil {
+			return err
+		}
+		return e.encode(v.Signature, v.Value)
+	case '(':
+		fields, ok := value.([]any)
+		if !ok {
+			return invalid()
+		}
+
+		types, err := splitDBusSignature(t[1 : len(t)-1])
+		if err != nil {
+			return err
+		}
+
+		if len(types) != len(fields) {
+			return invalid()
+		}
+
+		e.align(8)
+		for i := range types {
+			if err := e.encode(types[i], fields[i]); err != nil {
+				return err
+			}
+		}
+	case 'a':
+		elementType := t[1:]
+		var elements []any
+
+		switch v := value.(type) {
+		case []any:
+			elements = v
+		case []string:
+			elements = make([]any, len(v))
+			for i := range v {
+				elements[i] = v[i]
+			}
+		case map[string]any:
+			if !strings.HasPrefix(elementType, "{s") {
+				return invalid()
+			}
+
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			elements = make([]any, len(keys))
+			for i, key := range keys {
+				elements[i] = []any{key, v[key]}
+			}
+		default:
+			return invalid()
+		}
+
+		e.uint32(0)
+		lengthAt := len(e.buf) - 4
+		// padding for the first element isn't included in the length of the array
+		e.align(dbusAlignment(elementType[0]))
+		start := len(e.buf)
+
+		for _, element := range elements {
+			if elementType[0] == '{' {
+				entry, ok := element.([]any)
+				if !ok || len(entry) != 2 {
+					return invalid()
+				}
+
+				e.align(8)
+				keyLength, err := dbusTypeLength(elementType[1:])
+				if err != nil {
+					return err
+				}
+
+				if err := e.encode(elementType[1:1+keyLength], entry[0]); err != nil {
+					return err
+				}
+
+				if err := e.encode(elementType[1+keyLength:len(elementType)-1], entry[1]); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := e.encode(elementType, element); err != nil {
+				return err
+			}
+		}
+
+		binary.LittleEndian.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-start))
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+
+	return nil
+}
+
+type dbusDecoder struct {
+	data  []byte
+	order binary.ByteOrder
+	pos   int
+}
+
+var errDBusMessageTooShort = errors.New("message is too short")
+
+func (d *dbusDecoder) align(n int) error {
+	d.pos += (n - d.pos%n) % n
+	if d.pos > len(d.data) {
+		return errDBusMessageTooShort
+	}
+
+	return nil
+}
+
+func (d *dbusDecoder) read(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) || n < 0 {
+		return nil, errDBusMessageTooShort
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *dbusDecoder) readUint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.order.Uint32(b), nil
+}
+
+func (d *dbusDecoder) decode(t string) (any, error) {
+	switch t[0] {
+	case 'y':
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		v, err := d.readUint32()
+		return v != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.read(2)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint16(b)
+		if t[0] == 'n' {
+			return int16(v), nil
+		}
+		return v, nil
+	case 'i', 'u', 'h':
+		v, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if t[0] == 'i' {
+			return int32(v), nil
+		}
+		return v, nil
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint64(b)
+		switch t[0] {
+		case 'x':
+			return int64(v), nil
+		case 'd':
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case 's', 'o':
+		length, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(length) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(b[:length]), nil
+	case 'g':
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.read(int(b[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(s[:b[0]]), nil
+	case 'v':
+		signature, err := d.decode("g")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := dbusTypeLength(signature.(string)); err != nil {
+			return nil, err
+		}
+		return d.decode(signature.(string))
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+
+		types, err := splitDBusSignature(t[1 : len(t)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make([]any, len(types))
+		for i := range types {
+			if fields[i], err = d.decode(types[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		return fields, nil
+	case 'a':
+		length, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+
+		elementType := t[1:]
+		if err := d.align(dbusAlignment(elementType[0])); err != nil {
+			return nil, err
+		}
+
+		end := d.pos + int(length)
+		if end > len(d.data) {
+			return nil, errDBusMessageTooShort
+		}
+
+		if elementType[0] == '{' {
+			entries := make(map[string]any)
+			for d.pos < end {
+				entry, err := d.decode(elementType)
+				if err != nil {
+					return nil, err
+				}
+
+				pair := entry.([]any)
+				key, ok := pair[0].(string)
+				if !ok {
+					key = fmt.Sprint(pair[0])
+				}
+				entries[key] = pair[1]
+			}
+
+			return entries, nil
+		}
+
+		elements := make([]any, 0)
+		for d.pos < end {
+			element, err := d.decode(elementType)
+			if err != nil {
+				return nil, err
+			}
+
+			elements = append(elements, element)
+		}
+
+		return elements, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// Connects to the bus at the given address, calls the function and closes the connection
+func withDBusConn(address string, timeout time.Duration, f func(*dbusConn) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialDBus(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return f(conn)
+}
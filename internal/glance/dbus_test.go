@@ -0,0 +1,204 @@
+package glance
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeTestHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestSplitDBusSignature(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      []string
+		wantErr   bool
+	}{
+		{"", []string{}, false},
+		{"ss", []string{"s", "s"}, false},
+		{"sa{sv}", []string{"s", "a{sv}"}, false},
+		{"a(ssssssouso)u", []string{"a(ssssssouso)", "u"}, false},
+		{"aa{s(ii)}b", []string{"aa{s(ii)}", "b"}, false},
+		{"a", nil, true},
+		{"(ss", nil, true},
+		{"a{sv", nil, true},
+		{"z", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			got, err := splitDBusSignature(tt.signature)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitDBusSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitDBusSignature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBusEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		value     any
+		// the number of bytes already written, to check that values are aligned
+		// relative to the start of the message rather than to where they begin
+		offset      int
+		wantBytes   string
+		wantDecoded any
+	}{
+		{"byte", "y", byte(0x2a), 0, "2a", byte(0x2a)},
+		{"bool", "b", true, 0, "01000000", true},
+		{"int16", "n", int16(-2), 0, "feff", int16(-2)},
+		{"uint16 after a byte", "q", uint16(1), 1, "00 0100", uint16(1)},
+		{"int32", "i", int32(-1), 0, "ffffffff", int32(-1)},
+		{"uint32 after a byte", "u", uint32(1), 1, "000000 01000000", uint32(1)},
+		{"int64", "x", int64(-1), 0, "ffffffffffffffff", int64(-1)},
+		{"uint64 after a uint32", "t", uint64(1), 4, "00000000 0100000000000000", uint64(1)},
+		{"double", "d", 1.5, 0, "000000000000f83f", 1.5},
+		{"string", "s", "ab", 0, "02000000 616200", "ab"},
+		{"object path", "o", "/a", 0, "02000000 2f6100", "/a"},
+		{"signature", "g", "as", 0, "02 617300", "as"},
+		{"variant", "v", dbusVariant{"s", "a"}, 0, "01 7300 00 01000000 6100", "a"},
+		{"struct after a byte", "(yt)", []any{byte(1), uint64(2)}, 1, "00000000000000 01 00000000000000 0200000000000000", []any{byte(1), uint64(2)}},
+		{"string array", "as", []string{"a", "b"}, 0, "0e000000 01000000 6100 0000 01000000 6200", []any{"a", "b"}},
+		// padding before the first element isn't counted in the length of the array
+		{"uint64 array", "at", []any{uint64(5)}, 0, "08000000 00000000 0500000000000000", []any{uint64(5)}},
+		{"empty uint64 array", "at", []any{}, 0, "00000000 00000000", []any{}},
+		{
+			"dictionary", "a{sv}", map[string]any{"k": dbusVariant{"u", uint32(7)}}, 0,
+			"10000000 00000000 01000000 6b00 017500 000000 07000000",
+			map[string]any{"k": uint32(7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := &dbusEncoder{buf: make([]byte, tt.offset)}
+			if err := encoder.encode(tt.signature, tt.value); err != nil {
+				t.Fatalf("encode() error = %v", err)
+			}
+
+			want := decodeTestHex(t, tt.wantBytes)
+			if got := encoder.buf[tt.offset:]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("encode() = % x, want % x", got, want)
+			}
+
+			decoder := &dbusDecoder{data: encoder.buf, order: binary.LittleEndian, pos: tt.offset}
+			decoded, err := decoder.decode(tt.signature)
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(decoded, tt.wantDecoded) {
+				t.Errorf("decode() = %#v, want %#v", decoded, tt.wantDecoded)
+			}
+
+			if decoder.pos != len(encoder.buf) {
+				t.Errorf("decode() read %d bytes, want %d", decoder.pos, len(encoder.buf))
+			}
+		})
+	}
+}
+
+func TestDBusEncoderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		value     any
+	}{
+		{"mismatched type", "s", 1},
+		{"int instead of int32", "u", 1},
+		{"struct with too few fields", "(su)", []any{"a"}},
+		{"map with non string keys", "a{uv}", map[string]any{"k": dbusVariant{"s", "v"}}},
+		{"dictionary entry that isn't a pair", "a{ss}", []any{[]any{"k"}}},
+		{"signature that's too long", "g", strings.Repeat("s", 256)},
+		{"unsupported type", "z", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&dbusEncoder{}).encode(tt.signature, tt.value); err == nil {
+				t.Errorf("encode() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestDBusDecoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		order     binary.ByteOrder
+		signature string
+		want      any
+		wantErr   bool
+	}{
+		{"big endian", "00000001", binary.BigEndian, "u", uint32(1), false},
+		{"big endian string", "00000002 616200", binary.BigEndian, "s", "ab", false},
+		{"truncated uint32", "0100", binary.LittleEndian, "u", nil, true},
+		{"string longer than the message", "05000000 616200", binary.LittleEndian, "s", nil, true},
+		{"string without terminator", "02000000 6162", binary.LittleEndian, "s", nil, true},
+		{"array longer than the message", "10000000 01000000", binary.LittleEndian, "au", nil, true},
+		{"variant with invalid signature", "01 2800", binary.LittleEndian, "v", nil, true},
+		{"unsupported type", "00", binary.LittleEndian, "z", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := &dbusDecoder{data: decodeTestHex(t, tt.data), order: tt.order}
+			got, err := decoder.decode(tt.signature)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDBusAddress(t *testing.T) {
+	tests := []struct {
+		address  string
+		wantPath string
+		wantErr  bool
+	}{
+		{"unix:path=/run/dbus/system_bus_socket", "/run/dbus/system_bus_socket", false},
+		{"unix:abstract=/tmp/dbus-abc,guid=123", "@/tmp/dbus-abc", false},
+		{"tcp:host=localhost,port=1234;unix:path=/run/bus", "/run/bus", false},
+		{"tcp:host=localhost,port=1234", "", true},
+		{"unix:tmpdir=/tmp", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			network, path, err := parseDBusAddress(tt.address)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDBusAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && (network != "unix" || path != tt.wantPath) {
+				t.Errorf("parseDBusAddress() = %s %s, want unix %s", network, path, tt.wantPath)
+			}
+		})
+	}
+}
@@ -102,7 +102,7 @@ var diagnosticSteps = []diagnosticStep{
 	},
 }
 
-func runDiagnostic() {
+func runDiagnostic(configPath string) {
 	fmt.Println("```")
 	fmt.Println("Glance version: " + buildVersion)
 	fmt.Println("Go version: " + runtime.Version())
@@ -144,6 +144,26 @@ func runDiagnostic() {
 		}
 	}
 	fmt.Println("```")
+
+	printRedactedConfig(configPath)
+}
+
+func printRedactedConfig(configPath string) {
+	fmt.Println("\nConfig, with secrets redacted:")
+
+	contents, _, err := parseYAMLIncludes(configPath)
+	if err == nil {
+		contents, err = redactConfigSecrets(contents)
+	}
+
+	if err != nil {
+		fmt.Printf("Could not include config: %v\n", err)
+		return
+	}
+
+	fmt.Println("```yaml")
+	fmt.Println(strings.TrimRight(string(contents), "\n"))
+	fmt.Println("```")
 }
 
 type diagnosticStep struct {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
@@ -32,12 +33,18 @@ type application struct {
 	CreatedAt time.Time
 	Config    config
 
+	// Path of the config file or directory that the application was created from,
+	// empty when the config wasn't loaded from disk
+	configPath string
+
 	parsedManifest []byte
 
 	slugToPage map[string]*page
 	widgetByID map[uint64]widget
+	notifier   *notifier
 
 	RequiresAuth           bool
+	HasAdminUsers          bool
 	authSecretKey          []byte
 	usernameHashToUsername map[string]string
 	authAttemptsMu         sync.Mutex
@@ -74,6 +81,10 @@ func newApplication(c *config) (*application, error) {
 
 		for username := range config.Auth.Users {
 			user := config.Auth.Users[username]
+			if user.Admin {
+				app.HasAdminUsers = true
+			}
+
 			usernameHash, err := computeUsernameHash(username, secretBytes)
 			if err != nil {
 				return nil, fmt.Errorf("computing username hash for user %s: %v", username, err)
@@ -140,6 +151,16 @@ func newApplication(c *config) (*application, error) {
 		return nil, fmt.Errorf("initializing default theme: %v", err)
 	}
 
+	//
+	// Init notifications
+	//
+
+	notifier, err := newNotifier(&config.Notifications)
+	if err != nil {
+		return nil, fmt.Errorf("notifications: %v", err)
+	}
+	app.notifier = notifier
+
 	//
 	// Init pages
 	//
@@ -148,6 +169,7 @@ func newApplication(c *config) (*application, error) {
 
 	providers := &widgetProviders{
 		assetResolver: app.StaticAssetPath,
+		baseURL:       config.Server.BaseURL,
 	}
 
 	for p := range config.Pages {
@@ -174,7 +196,7 @@ func newApplication(c *config) (*application, error) {
 
 		for i := range page.HeadWidgets {
 			widget := page.HeadWidgets[i]
-			app.widgetByID[widget.GetID()] = widget
+			app.registerWidget(widget)
 			widget.setProviders(providers)
 		}
 
@@ -187,12 +209,46 @@ func newApplication(c *config) (*application, error) {
 
 			for w := range column.Widgets {
 				widget := column.Widgets[w]
-				app.widgetByID[widget.GetID()] = widget
+				app.registerWidget(widget)
 				widget.setProviders(providers)
 			}
 		}
 	}
 
+	for _, widget := range app.widgetByID {
+		notifying, ok := widget.(notifyingWidget)
+		if !ok {
+			continue
+		}
+
+		rule := notifying.notificationRule()
+		if rule == nil {
+			continue
+		}
+
+		for _, name := range rule.Channels {
+			if _, exists := notifier.channels[name]; !exists {
+				return nil, fmt.Errorf("%s widget: notify: channel %s is not defined", widget.GetType(), name)
+			}
+		}
+
+		rule.notifier = notifier
+	}
+
+	// Widget actions are only restricted to logged in users, without
+	// auth anyone who can reach the dashboard would be able to use them
+	if !app.RequiresAuth {
+		for _, widget := range app.widgetByID {
+			if actionable, ok := widget.(actionableWidget); ok && actionable.hasActions() {
+				return nil, fmt.Errorf("%s widget: actions require auth to be enabled", widget.GetType())
+			}
+		}
+	}
+
+	if err := app.initStatusPages(); err != nil {
+		return nil, err
+	}
+
 	config.Server.BaseURL = strings.TrimRight(config.Server.BaseURL, "/")
 	config.Theme.CustomCSSFile = app.resolveUserDefinedAssetPath(config.Theme.CustomCSSFile)
 	config.Branding.LogoURL = app.resolveUserDefinedAssetPath(config.Branding.LogoURL)
@@ -411,26 +467,68 @@ func (a *application) handleNotFound(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (a *application) handleWidgetRequest(w http.ResponseWriter, r *http.Request) {
-	// TODO: this requires a rework of the widget update logic so that rather
-	// than locking the entire page we lock individual widgets
-	w.WriteHeader(http.StatusNotImplemented)
+	// Widget requests can perform actions such as restarting containers, so unlike
+	// pages they always require the user to be logged in when auth is enabled
+	if a.handleUnauthorizedResponse(w, r, showUnauthorizedJSON) {
+		return
+	}
 
-	// widgetValue := r.PathValue("widget")
+	widgetID, err := strconv.ParseUint(r.PathValue("widget"), 10, 64)
+	if err != nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	widget, exists := a.widgetByID[widgetID]
+	if !exists {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	// The page isn't locked here, so widgets must not modify the state
+	// used when rendering them from within their request handlers
+	widget.handleRequest(w, r)
+}
 
-	// widgetID, err := strconv.ParseUint(widgetValue, 10, 64)
-	// if err != nil {
-	// 	a.handleNotFound(w, r)
-	// 	return
-	// }
+// Also registers the widgets within groups and split columns so that they can be looked up by their ID
+func (a *application) registerWidget(widget widget) {
+	a.widgetByID[widget.GetID()] = widget
 
-	// widget, exists := a.widgetByID[widgetID]
+	if container, ok := widget.(interface{ nestedWidgets() widgets }); ok {
+		for _, nested := range container.nestedWidgets() {
+			a.registerWidget(nested)
+		}
+	}
+}
+
+// Where data that needs to persist across restarts gets stored, defaults
+// to a directory named data next to the config file
+func (a *application) dataPath() string {
+	if a.Config.Server.DataPath != "" {
+		return a.Config.Server.DataPath
+	}
+
+	if a.configPath == "" {
+		return "data"
+	}
 
-	// if !exists {
-	// 	a.handleNotFound(w, r)
-	// 	return
-	// }
+	if stat, err := os.Stat(a.configPath); err == nil && stat.IsDir() {
+		return filepath.Join(a.configPath, "data")
+	}
 
-	// widget.handleRequest(w, r)
+	return filepath.Join(filepath.Dir(a.configPath), "data")
+}
+
+func (a *application) startBackgroundWidgets(ctx context.Context, wg *sync.WaitGroup) {
+	for _, widget := range a.widgetByID {
+		if background, ok := widget.(backgroundWidget); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				background.runInBackground(ctx, a)
+			}()
+		}
+	}
 }
 
 func (a *application) StaticAssetPath(asset string) string {
@@ -455,16 +553,30 @@ func (a *application) server() (func() error, func() error) {
 	}
 
 	mux.HandleFunc("/api/widgets/{widget}/{path...}", a.handleWidgetRequest)
+	mux.HandleFunc("GET /api/maintenance", a.handleMaintenanceListRequest)
+	mux.HandleFunc("POST /api/maintenance", a.handleMaintenanceStartRequest)
+	mux.HandleFunc("DELETE /api/maintenance", a.handleMaintenanceEndRequest)
+	mux.HandleFunc("DELETE /api/maintenance/{id}", a.handleMaintenanceEndRequest)
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	if len(a.Config.StatusPages) > 0 {
+		mux.HandleFunc("GET /status/{slug}", a.handleStatusPageRequest)
+	}
+
 	if a.RequiresAuth {
 		mux.HandleFunc("GET /login", a.handleLoginPageRequest)
 		mux.HandleFunc("GET /logout", a.handleLogoutRequest)
 		mux.HandleFunc("POST /api/authenticate", a.handleAuthenticationAttempt)
 	}
 
+	if a.HasAdminUsers && a.configPath != "" {
+		mux.HandleFunc("GET /admin/config", a.handleConfigEditorPageRequest)
+		mux.HandleFunc("GET /api/admin/config/file", a.handleConfigEditorFileRequest)
+		mux.HandleFunc("POST /api/admin/config/file", a.handleConfigEditorSaveRequest)
+	}
+
 	mux.Handle(
 		fmt.Sprintf("GET /static/%s/{path...}", staticFSHash),
 		http.StripPrefix(
@@ -502,7 +614,13 @@ func (a *application) server() (func() error, func() error) {
 		Handler: mux,
 	}
 
+	backgroundCtx, cancelBackgroundWidgets := context.WithCancel(context.Background())
+	var backgroundWidgetsWG sync.WaitGroup
+
 	start := func() error {
+		adHocMaintenance.open(filepath.Join(a.dataPath(), maintenanceFileName))
+		a.startBackgroundWidgets(backgroundCtx, &backgroundWidgetsWG)
+
 		log.Printf("Starting server on %s:%d (base-url: \"%s\", assets-path: \"%s\")\n",
 			a.Config.Server.Host,
 			a.Config.Server.Port,
@@ -518,6 +636,11 @@ func (a *application) server() (func() error, func() error) {
 	}
 
 	stop := func() error {
+		// waiting allows widgets to finish saving their data before the next application starts
+		cancelBackgroundWidgets()
+		backgroundWidgetsWG.Wait()
+		a.notifier.stop()
+
 		return server.Close()
 	}
 
@@ -50,13 +50,22 @@ func Main() int {
 			return 1
 		}
 
+		if options.redact {
+			if contents, err = redactConfigSecrets(contents); err != nil {
+				fmt.Printf("Could not redact config file: %v\n", err)
+				return 1
+			}
+		}
+
 		fmt.Println(string(contents))
+	case cliIntentConfigMigrate:
+		return cliConfigMigrate(options.configPath, options.dryRun)
 	case cliIntentSensorsPrint:
 		return cliSensorsPrint()
 	case cliIntentMountpointInfo:
 		return cliMountpointInfo(options.args[1])
 	case cliIntentDiagnose:
-		runDiagnostic()
+		runDiagnostic(options.configPath)
 	case cliIntentSecretMake:
 		key, err := makeAuthSecretKey(AUTH_SECRET_KEY_LENGTH)
 		if err != nil {
@@ -125,6 +134,8 @@ func serveApp(configPath string) error {
 			return
 		}
 
+		app.configPath = configPath
+
 		if !hadValidConfigOnStartup {
 			hadValidConfigOnStartup = true
 		}
@@ -169,6 +180,7 @@ func serveApp(configPath string) error {
 		if err != nil {
 			return fmt.Errorf("creating application: %w", err)
 		}
+		app.configPath = configPath
 
 		startServer, _ := app.server()
 		if err := startServer(); err != nil {
@@ -0,0 +1,543 @@
+package glance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maintenanceFileName = "maintenance.json"
+	// Limits how far back schedules have to be searched for a match
+	maxScheduledMaintenanceDuration = 7 * 24 * time.Hour
+)
+
+var maintenanceTimeFormats = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	time.RFC3339,
+	"2006-01-02",
+}
+
+// Either recurs on a cron schedule for the given duration or is a one-off range between start and end,
+// applies to the sites or containers with the given names, or all of them if no names are given
+type maintenanceWindow struct {
+	Schedule string        `yaml:"schedule"`
+	Duration durationField `yaml:"duration"`
+	Start    string        `yaml:"start"`
+	End      string        `yaml:"end"`
+	Names    []string      `yaml:"names"`
+
+	schedule *cronSchedule
+	start    time.Time
+	end      time.Time
+}
+
+type maintenanceWindows []maintenanceWindow
+
+func (windows maintenanceWindows) initialize() error {
+	for i := range windows {
+		if err := windows[i].initialize(); err != nil {
+			return fmt.Errorf("maintenance window %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *maintenanceWindow) initialize() error {
+	if w.Schedule != "" {
+		if w.Start != "" || w.End != "" {
+			return errors.New("schedule cannot be used together with start and end")
+		}
+
+		if w.Duration <= 0 {
+			return errors.New("duration is required when using a schedule")
+		}
+
+		if time.Duration(w.Duration) > maxScheduledMaintenanceDuration {
+			return errors.New("duration must not be longer than 7 days")
+		}
+
+		schedule, err := parseCronSchedule(w.Schedule)
+		if err != nil {
+			return fmt.Errorf("schedule: %v", err)
+		}
+
+		w.schedule = schedule
+		return nil
+	}
+
+	if w.Start == "" {
+		return errors.New("either schedule or start is required")
+	}
+
+	start, err := parseMaintenanceTime(w.Start)
+	if err != nil {
+		return fmt.Errorf("start: %v", err)
+	}
+	w.start = start
+
+	switch {
+	case w.End != "":
+		end, err := parseMaintenanceTime(w.End)
+		if err != nil {
+			return fmt.Errorf("end: %v", err)
+		}
+		w.end = end
+	case w.Duration > 0:
+		w.end = start.Add(time.Duration(w.Duration))
+	default:
+		return errors.New("either end or duration is required")
+	}
+
+	if !w.end.After(w.start) {
+		return errors.New("end must be after start")
+	}
+
+	return nil
+}
+
+func parseMaintenanceTime(value string) (time.Time, error) {
+	for _, format := range maintenanceTimeFormats {
+		if t, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %s, expected a format such as 2006-01-02 15:04", value)
+}
+
+func (w *maintenanceWindow) appliesTo(name string) bool {
+	if len(w.Names) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(w.Names, func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+func (w *maintenanceWindow) isActiveAt(now time.Time) bool {
+	if w.schedule == nil {
+		return !now.Before(w.start) && now.Before(w.end)
+	}
+
+	_, ok := w.schedule.previous(now, now.Add(-time.Duration(w.Duration)))
+	return ok
+}
+
+// Reports whether the site or container with the given name is in maintenance, either
+// through one of the windows or through maintenance that was started through the API
+func (windows maintenanceWindows) covers(name string, now time.Time) bool {
+	for i := range windows {
+		if windows[i].appliesTo(name) && windows[i].isActiveAt(now) {
+			return true
+		}
+	}
+
+	return adHocMaintenance.covers(name, now)
+}
+
+// A standard five field cron expression consisting of minute, hour, day of month,
+// month and day of week, each of which supports lists, ranges and steps
+type cronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// When both the day of month and the day of week are restricted,
+	// matching either of them is enough, same as with cron
+	anyDay     bool
+	anyWeekday bool
+}
+
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	schedule := &cronSchedule{
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}
+
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+
+	// both 0 and 7 are sunday
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		start, end := min, max
+		step := 1
+
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %s", stepPart)
+			}
+		}
+
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %s", from)
+			}
+
+			switch {
+			case isRange:
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %s", to)
+				}
+			case !hasStep:
+				end = start
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("%s is out of range %d-%d", part, min, max)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.minutes&(1<<t.Minute()) != 0 && s.hours&(1<<t.Hour()) != 0 &&
+		s.months&(1<<int(t.Month())) != 0 && s.dayMatches(t)
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dayMatches := s.days&(1<<t.Day()) != 0
+	weekdayMatches := s.weekdays&(1<<int(t.Weekday())) != 0
+
+	if s.anyDay || s.anyWeekday {
+		return dayMatches && weekdayMatches
+	}
+
+	return dayMatches || weekdayMatches
+}
+
+// Returns the latest minute at or before t that matches the schedule, as long as it's after
+// earliest. Rather than checking every minute, whole months, days and hours that don't
+// match get skipped at once, so that long windows don't take long to check.
+func (s *cronSchedule) previous(t time.Time, earliest time.Time) (time.Time, bool) {
+	// unlike time.Date, doesn't move times during a repeated hour to its first occurrence
+	t = t.Truncate(time.Minute)
+
+	// moves to the minute before the given one, which is normally the end of the previous
+	// month, day or hour, unless a daylight saving time change would make it go forward
+	rewind := func(to time.Time) {
+		to = to.Add(-time.Minute)
+		t = ternary(to.Before(t), to, t.Add(-time.Minute))
+	}
+
+	for t.After(earliest) {
+		year, month, day := t.Date()
+
+		switch {
+		case s.months&(1<<int(month)) == 0:
+			rewind(time.Date(year, month, 1, 0, 0, 0, 0, t.Location()))
+		case !s.dayMatches(t):
+			rewind(time.Date(year, month, day, 0, 0, 0, 0, t.Location()))
+		case s.hours&(1<<t.Hour()) == 0:
+			rewind(time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location()))
+		default:
+			minutes := s.minutes & (1<<(t.Minute()+1) - 1)
+			if minutes == 0 {
+				rewind(time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location()))
+				continue
+			}
+
+			t = t.Add(-time.Duration(t.Minute()-(bits.Len64(minutes)-1)) * time.Minute)
+			return t, t.After(earliest)
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Maintenance started through the API, shared between all widgets and persisted so that it
+// isn't lost if Glance gets restarted during it, which is likely when maintaining the host
+var adHocMaintenance = &adHocMaintenanceStore{}
+
+type adHocMaintenanceWindow struct {
+	ID    string    `json:"id"`
+	Names []string  `json:"names"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type adHocMaintenanceStore struct {
+	mu       sync.Mutex
+	filePath string
+	windows  []adHocMaintenanceWindow
+}
+
+func (s *adHocMaintenanceStore) open(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.filePath == filePath {
+		return
+	}
+
+	s.filePath = filePath
+
+	contents, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to read maintenance windows: %v", err)
+		return
+	}
+
+	var windows []adHocMaintenanceWindow
+	if err := json.Unmarshal(contents, &windows); err != nil {
+		log.Printf("Failed to parse maintenance windows: %v", err)
+		return
+	}
+
+	s.windows = windows
+}
+
+func (s *adHocMaintenanceStore) covers(name string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.windows {
+		window := &s.windows[i]
+
+		if now.Before(window.Start) || !now.Before(window.End) {
+			continue
+		}
+
+		if len(window.Names) == 0 || slices.ContainsFunc(window.Names, func(n string) bool {
+			return strings.EqualFold(n, name)
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *adHocMaintenanceStore) active(now time.Time) []adHocMaintenanceWindow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make([]adHocMaintenanceWindow, 0, len(s.windows))
+	for i := range s.windows {
+		if now.Before(s.windows[i].End) {
+			active = append(active, s.windows[i])
+		}
+	}
+
+	return active
+}
+
+func (s *adHocMaintenanceStore) start(names []string, duration time.Duration) (adHocMaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	window := adHocMaintenanceWindow{
+		ID:    strconv.FormatInt(now.UnixNano(), 36),
+		Names: names,
+		Start: now,
+		End:   now.Add(duration),
+	}
+
+	s.windows = append(s.windows, window)
+	return window, s.saveLocked(now)
+}
+
+// Ends the window with the given ID, or all of them when the ID is empty
+func (s *adHocMaintenanceStore) end(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	found := false
+
+	for i := range s.windows {
+		if (id == "" || s.windows[i].ID == id) && now.Before(s.windows[i].End) {
+			s.windows[i].End = now
+			found = true
+		}
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	return true, s.saveLocked(now)
+}
+
+func (s *adHocMaintenanceStore) saveLocked(now time.Time) error {
+	s.windows = slices.DeleteFunc(s.windows, func(window adHocMaintenanceWindow) bool {
+		return !now.Before(window.End)
+	})
+
+	if s.filePath == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(s.windows)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.filePath), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(s.filePath, contents, 0o644)
+	}
+
+	if err != nil {
+		return fmt.Errorf("saving maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
+type maintenanceStartRequest struct {
+	Names    []string `json:"names"`
+	Duration string   `json:"duration"`
+}
+
+func (a *application) handleMaintenanceListRequest(w http.ResponseWriter, r *http.Request) {
+	if a.handleUnauthorizedResponse(w, r, showUnauthorizedJSON) {
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, adHocMaintenance.active(time.Now()))
+}
+
+// Like widget actions, changing maintenance is only allowed for logged in users,
+// without auth anyone who can reach the dashboard would be able to do it
+func (a *application) handleMaintenanceChangeUnauthorized(w http.ResponseWriter, r *http.Request) bool {
+	if !a.RequiresAuth {
+		writeJSONResponse(w, http.StatusForbidden, map[string]string{"error": "starting and ending maintenance requires auth to be enabled"})
+		return true
+	}
+
+	return a.handleUnauthorizedResponse(w, r, showUnauthorizedJSON)
+}
+
+func (a *application) handleMaintenanceStartRequest(w http.ResponseWriter, r *http.Request) {
+	if a.handleMaintenanceChangeUnauthorized(w, r) {
+		return
+	}
+
+	var request maintenanceStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	var duration durationField
+	if err := yaml.Unmarshal([]byte(strconv.Quote(request.Duration)), &duration); err != nil || duration <= 0 {
+		writeJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid duration, expected a value such as 30m or 2h"})
+		return
+	}
+
+	window, err := adHocMaintenance.start(request.Names, time.Duration(duration))
+	a.updateWidgetsWithMaintenance()
+
+	if err != nil {
+		log.Printf("Failed to start maintenance: %v", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "maintenance was started but could not be saved, it will end if Glance restarts"})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, window)
+}
+
+func (a *application) handleMaintenanceEndRequest(w http.ResponseWriter, r *http.Request) {
+	if a.handleMaintenanceChangeUnauthorized(w, r) {
+		return
+	}
+
+	found, err := adHocMaintenance.end(r.PathValue("id"))
+	if !found {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "no active maintenance found"})
+		return
+	}
+
+	a.updateWidgetsWithMaintenance()
+
+	if err != nil {
+		log.Printf("Failed to end maintenance: %v", err)
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "maintenance was ended but could not be saved, it will resume if Glance restarts"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Makes the widgets that support maintenance update on the next page load rather than
+// once their cache expires, so that maintenance started through the API shows up right away
+func (a *application) updateWidgetsWithMaintenance() {
+	pages := a.Config.Pages
+
+	// widgets can only be modified while holding the lock of the page they're on,
+	// so hold all of them since it isn't known which widget is on which page
+	for p := range pages {
+		pages[p].mu.Lock()
+		defer pages[p].mu.Unlock()
+	}
+
+	for _, widget := range a.widgetByID {
+		switch widget := widget.(type) {
+		case *monitorWidget:
+			widget.nextUpdate = time.Time{}
+		case *dockerContainersWidget:
+			widget.nextUpdate = time.Time{}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package glance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleMatches(t *testing.T) {
+	tests := []struct {
+		expression string
+		time       string
+		expected   bool
+	}{
+		{"0 3 * * *", "2026-10-18 03:00", true},
+		{"0 3 * * *", "2026-10-18 03:01", false},
+		{"*/15 * * * *", "2026-10-18 10:45", true},
+		{"*/15 * * * *", "2026-10-18 10:46", false},
+		{"0 2-4 * * *", "2026-10-18 04:00", true},
+		{"0 2-4 * * *", "2026-10-18 05:00", false},
+		{"30 1 * * 0", "2026-10-18 01:30", true}, // a sunday
+		{"30 1 * * 7", "2026-10-18 01:30", true},
+		{"30 1 * * 1-5", "2026-10-18 01:30", false},
+		{"0 0 1 * 1", "2026-10-19 00:00", true}, // either the day of month or the day of week
+		{"0 0 1,15 1 *", "2026-01-15 00:00", true},
+		{"0 0 1,15 1 *", "2026-02-15 00:00", false},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCronSchedule(test.expression)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.expression, err)
+		}
+
+		at, _ := time.ParseInLocation("2006-01-02 15:04", test.time, time.Local)
+		if got := schedule.matches(at); got != test.expected {
+			t.Errorf("%q at %s: expected %v, got %v", test.expression, test.time, test.expected, got)
+		}
+	}
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCronSchedule(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestCronSchedulePrevious(t *testing.T) {
+	tests := []struct {
+		expression string
+		time       string
+		earliest   string
+		expected   string
+	}{
+		{"0 3 * * *", "2026-10-18 03:00", "2026-10-18 00:00", "2026-10-18 03:00"},
+		{"0 3 * * *", "2026-10-18 02:59", "2026-10-17 00:00", "2026-10-17 03:00"},
+		{"*/15 * * * *", "2026-10-18 10:44", "2026-10-18 10:00", "2026-10-18 10:30"},
+		{"0 2-4 * * *", "2026-10-18 10:00", "2026-10-18 00:00", "2026-10-18 04:00"},
+		{"30 1 * * 1-5", "2026-10-18 10:00", "2026-10-01 00:00", "2026-10-16 01:30"}, // the friday before
+		{"0 0 1 * 1", "2026-10-18 10:00", "2026-09-01 00:00", "2026-10-12 00:00"},
+		{"0 0 1 * *", "2026-10-18 10:00", "2026-09-01 00:00", "2026-10-01 00:00"},
+		{"0 0 1,15 1 *", "2026-10-18 10:00", "2025-01-01 00:00", "2026-01-15 00:00"},
+		{"0 0 29 2 *", "2026-10-18 10:00", "2023-01-01 00:00", "2024-02-29 00:00"},
+		// earliest itself isn't included
+		{"0 3 * * *", "2026-10-18 02:59", "2026-10-17 03:00", ""},
+		{"0 0 29 2 *", "2026-10-18 10:00", "2025-01-01 00:00", ""},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCronSchedule(test.expression)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.expression, err)
+		}
+
+		at, _ := time.ParseInLocation("2006-01-02 15:04", test.time, time.Local)
+		earliest, _ := time.ParseInLocation("2006-01-02 15:04", test.earliest, time.Local)
+
+		got, ok := schedule.previous(at, earliest)
+		if gotValue := ternary(ok, got.Format("2006-01-02 15:04"), ""); gotValue != test.expected {
+			t.Errorf("%q before %s: expected %q, got %q", test.expression, test.time, test.expected, gotValue)
+		}
+	}
+}
+
+func TestCronSchedulePreviousAcrossDaylightSavingTime(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("loading time zone: %v", err)
+	}
+
+	schedule, err := parseCronSchedule("30 1 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clocks go back from 02:00 to 01:00, so 01:30 happens twice
+	at := time.Date(2026, 11, 1, 1, 45, 0, 0, location).Add(time.Hour)
+	got, ok := schedule.previous(at, at.Add(-24*time.Hour))
+	if !ok || at.Sub(got) != 15*time.Minute {
+		t.Errorf("expected the second 01:30, got %v, %v", got, ok)
+	}
+
+	// clocks go forward from 02:00 to 03:00
+	schedule, _ = parseCronSchedule("0 * * * *")
+	at = time.Date(2026, 3, 8, 3, 10, 0, 0, location)
+	got, ok = schedule.previous(at.Add(-11*time.Minute), at.Add(-24*time.Hour))
+	if !ok || at.Sub(got) != 70*time.Minute {
+		t.Errorf("expected 01:00 before the change, got %v, %v", got, ok)
+	}
+}
+
+func TestMaintenanceWindowIsActiveAt(t *testing.T) {
+	window := maintenanceWindow{Schedule: "0 3 * * *", Duration: durationField(90 * time.Minute)}
+	if err := window.initialize(); err != nil {
+		t.Fatalf("initializing window: %v", err)
+	}
+
+	at := func(value string) time.Time {
+		parsed, _ := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+		return parsed
+	}
+
+	for value, expected := range map[string]bool{
+		"2026-10-18 02:59": false,
+		"2026-10-18 03:00": true,
+		"2026-10-18 04:29": true,
+		"2026-10-18 04:30": false,
+	} {
+		if got := window.isActiveAt(at(value)); got != expected {
+			t.Errorf("at %s: expected %v, got %v", value, expected, got)
+		}
+	}
+
+	oneOff := maintenanceWindow{Start: "2026-10-18 22:00", End: "2026-10-19 01:00"}
+	if err := oneOff.initialize(); err != nil {
+		t.Fatalf("initializing window: %v", err)
+	}
+
+	if !oneOff.isActiveAt(at("2026-10-19 00:30")) || oneOff.isActiveAt(at("2026-10-19 01:00")) {
+		t.Error("one-off window is active at the wrong times")
+	}
+}
+
+func TestMaintenanceAPIRequests(t *testing.T) {
+	secret := make([]byte, AUTH_SECRET_KEY_LENGTH)
+	usernameHash, err := computeUsernameHash("admin", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := generateSessionToken("admin", secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authApp := &application{
+		RequiresAuth:           true,
+		authSecretKey:          secret,
+		usernameHashToUsername: map[string]string{string(usernameHash): "admin"},
+	}
+	authApp.Config.Auth.Users = map[string]*user{"admin": {}}
+
+	noAuthApp := &application{}
+
+	// the parent of the file is a file, so saving always fails
+	blockingFile := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blockingFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		app        *application
+		loggedIn   bool
+		filePath   string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"listing without auth", noAuthApp, false, "", http.MethodGet, "", http.StatusOK},
+		{"starting without auth", noAuthApp, false, "", http.MethodPost, `{"duration": "30m"}`, http.StatusForbidden},
+		{"ending without auth", noAuthApp, false, "", http.MethodDelete, "", http.StatusForbidden},
+		{"starting while logged out", authApp, false, "", http.MethodPost, `{"duration": "30m"}`, http.StatusUnauthorized},
+		{"starting with an invalid duration", authApp, true, "", http.MethodPost, `{"duration": "soon"}`, http.StatusBadRequest},
+		{"starting", authApp, true, "", http.MethodPost, `{"duration": "30m"}`, http.StatusOK},
+		{"ending", authApp, true, "", http.MethodDelete, "", http.StatusNoContent},
+		{"ending when none are active", authApp, true, "", http.MethodDelete, "", http.StatusNotFound},
+		{"starting when saving fails", authApp, true, filepath.Join(blockingFile, "maintenance.json"), http.MethodPost, `{"duration": "30m"}`, http.StatusInternalServerError},
+		{"ending when saving fails", authApp, true, filepath.Join(blockingFile, "maintenance.json"), http.MethodDelete, "", http.StatusInternalServerError},
+	}
+
+	defer func() { adHocMaintenance = &adHocMaintenanceStore{} }()
+	adHocMaintenance = &adHocMaintenanceStore{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adHocMaintenance.filePath = tt.filePath
+
+			request := httptest.NewRequest(tt.method, "/api/maintenance", strings.NewReader(tt.body))
+			if tt.loggedIn {
+				request.AddCookie(&http.Cookie{Name: AUTH_SESSION_COOKIE_NAME, Value: token})
+			}
+
+			recorder := httptest.NewRecorder()
+
+			switch tt.method {
+			case http.MethodGet:
+				tt.app.handleMaintenanceListRequest(recorder, request)
+			case http.MethodPost:
+				tt.app.handleMaintenanceStartRequest(recorder, request)
+			case http.MethodDelete:
+				tt.app.handleMaintenanceEndRequest(recorder, request)
+			}
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+		})
+	}
+}
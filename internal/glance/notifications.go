@@ -0,0 +1,724 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	notificationStatesFileName     = "notifications.json"
+	notificationStatesSaveInterval = time.Minute
+	// States of things that haven't been seen for this long have most likely been removed from the config
+	notificationStatesMaxAge       = 30 * 24 * time.Hour
+	notificationSendTimeout        = 15 * time.Second
+	notificationFirstRetryDelay    = 10 * time.Second
+	defaultNotificationRetries     = 3
+	defaultNotificationDedupWindow = time.Hour
+)
+
+const (
+	notificationChannelWebhook = "webhook"
+	notificationChannelNtfy    = "ntfy"
+	notificationChannelGotify  = "gotify"
+	notificationChannelEmail   = "email"
+)
+
+type notificationsConfig struct {
+	Channels map[string]*notificationChannel `yaml:"channels"`
+	// How long an identical notification for the same thing won't be sent again for
+	DedupWindow durationField `yaml:"dedup-window"`
+	Retries     int           `yaml:"retries"`
+}
+
+type notificationChannel struct {
+	Type     string            `yaml:"type"`
+	URL      string            `yaml:"url"`
+	Method   string            `yaml:"method"`
+	Headers  map[string]string `yaml:"headers" secret:"true"`
+	Body     string            `yaml:"body"`
+	Topic    string            `yaml:"topic"`
+	Token    string            `yaml:"token" secret:"true"`
+	Priority int               `yaml:"priority"`
+	Host     string            `yaml:"host"`
+	Port     uint16            `yaml:"port"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password" secret:"true"`
+	From     string            `yaml:"from"`
+	To       []string          `yaml:"to"`
+
+	bodyTemplate *template.Template
+}
+
+type notification struct {
+	Event   string
+	Widget  string
+	Title   string
+	Message string
+	URL     string
+	Time    time.Time
+	// Identifies the thing that the notification is about, used for deduplication
+	key string
+}
+
+// Attached to widgets through their notify property, can either be a list of
+// channel names or a mapping with the channels along with the other options
+type notificationRule struct {
+	Channels []string `yaml:"channels"`
+	// The events to send notifications for, all of the widget's events when empty
+	On []string `yaml:"on"`
+	// How many consecutive times a new state has to be seen before it's notified about
+	After int `yaml:"after"`
+
+	notifier *notifier
+}
+
+// Implemented by widgets that support the notify property
+type notifyingWidget interface {
+	notificationRule() *notificationRule
+}
+
+func (r *notificationRule) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&r.Channels)
+	}
+
+	type notificationRuleAlias notificationRule
+	return node.Decode((*notificationRuleAlias)(r))
+}
+
+// Validates the rule against the events that the widget can send, any event is allowed when none are given
+func (r *notificationRule) initialize(defaultAfter int, events ...string) error {
+	if r == nil {
+		return nil
+	}
+
+	if len(r.Channels) == 0 {
+		return errors.New("notify: at least one channel is required")
+	}
+
+	if r.After < 0 {
+		return errors.New("notify: after must not be negative")
+	} else if r.After == 0 {
+		r.After = defaultAfter
+	}
+
+	for i := range r.On {
+		r.On[i] = strings.ToLower(r.On[i])
+
+		if len(events) > 0 && !slices.Contains(events, r.On[i]) {
+			return fmt.Errorf("notify: unsupported event %s, must be one of %s", r.On[i], strings.Join(events, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (r *notificationRule) isActive() bool {
+	return r != nil && r.notifier != nil
+}
+
+func (r *notificationRule) send(n notification) {
+	if !r.isActive() {
+		return
+	}
+
+	if len(r.On) > 0 && !slices.Contains(r.On, n.Event) {
+		return
+	}
+
+	r.notifier.send(r.Channels, n)
+}
+
+func (c *notificationChannel) initialize() error {
+	c.Type = strings.ToLower(c.Type)
+
+	switch c.Type {
+	case notificationChannelWebhook:
+		if c.URL == "" {
+			return errors.New("url is required")
+		}
+
+		if c.Method == "" {
+			c.Method = http.MethodPost
+		}
+
+		if c.Body != "" {
+			bodyTemplate, err := template.New("body").Funcs(notificationTemplateFuncs).Parse(c.Body)
+			if err != nil {
+				return fmt.Errorf("parsing body template: %v", err)
+			}
+
+			c.bodyTemplate = bodyTemplate
+		}
+	case notificationChannelNtfy:
+		if c.Topic == "" {
+			return errors.New("topic is required")
+		}
+
+		if c.URL == "" {
+			c.URL = "https://ntfy.sh"
+		}
+	case notificationChannelGotify:
+		if c.URL == "" || c.Token == "" {
+			return errors.New("url and token are required")
+		}
+	case notificationChannelEmail:
+		if c.Host == "" || c.From == "" || len(c.To) == 0 {
+			return errors.New("host, from and to are required")
+		}
+
+		if c.Port == 0 {
+			c.Port = 587
+		}
+	case "":
+		return errors.New("type is required")
+	default:
+		return fmt.Errorf("unsupported type %s, must be one of webhook, ntfy, gotify or email", c.Type)
+	}
+
+	c.URL = strings.TrimRight(c.URL, "/")
+
+	return nil
+}
+
+var notificationTemplateFuncs = template.FuncMap{
+	// Outputs the value as a JSON string so that it can be safely placed within a JSON body
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+func (c *notificationChannel) send(ctx context.Context, n *notification) error {
+	switch c.Type {
+	case notificationChannelWebhook:
+		return c.sendWebhook(ctx, n)
+	case notificationChannelNtfy:
+		return c.sendNtfy(ctx, n)
+	case notificationChannelGotify:
+		return c.sendGotify(ctx, n)
+	case notificationChannelEmail:
+		return c.sendEmail(ctx, n)
+	}
+
+	return fmt.Errorf("unsupported channel type %s", c.Type)
+}
+
+func (c *notificationChannel) sendWebhook(ctx context.Context, n *notification) error {
+	var body bytes.Buffer
+
+	if c.bodyTemplate != nil {
+		if err := c.bodyTemplate.Execute(&body, n); err != nil {
+			return fmt.Errorf("executing body template: %w", err)
+		}
+	} else {
+		err := json.NewEncoder(&body).Encode(map[string]any{
+			"event":   n.Event,
+			"widget":  n.Widget,
+			"title":   n.Title,
+			"message": n.Message,
+			"url":     n.URL,
+			"time":    n.Time,
+		})
+		if err != nil {
+			return fmt.Errorf("encoding body: %w", err)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, c.Method, c.URL, &body)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range c.Headers {
+		request.Header.Set(key, value)
+	}
+
+	return doNotificationRequest(request)
+}
+
+func (c *notificationChannel) sendNtfy(ctx context.Context, n *notification) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/"+c.Topic, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Title", mime.QEncoding.Encode("utf-8", n.Title))
+	request.Header.Set("Tags", n.Event)
+
+	if n.URL != "" {
+		request.Header.Set("Click", n.URL)
+	}
+
+	if c.Priority > 0 {
+		request.Header.Set("Priority", strconv.Itoa(c.Priority))
+	}
+
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	return doNotificationRequest(request)
+}
+
+func (c *notificationChannel) sendGotify(ctx context.Context, n *notification) error {
+	payload := map[string]any{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": c.Priority,
+	}
+
+	if n.URL != "" {
+		payload["extras"] = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": n.URL}},
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding body: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gotify-Key", c.Token)
+
+	return doNotificationRequest(request)
+}
+
+func doNotificationRequest(request *http.Request) error {
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+
+	return nil
+}
+
+// Uses implicit TLS on port 465 and STARTTLS on any other port when the server supports it
+func (c *notificationChannel) sendEmail(ctx context.Context, n *notification) error {
+	address := net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port)))
+	dialer := net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: c.Host}
+
+	if c.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && c.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+
+	for _, to := range c.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	body := n.Message
+	if n.URL != "" {
+		body += "\r\n\r\n" + n.URL
+	}
+
+	fmt.Fprintf(writer, "From: %s\r\n", c.From)
+	fmt.Fprintf(writer, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(writer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title))
+	fmt.Fprintf(writer, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(writer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(writer, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(writer, "%s\r\n", body)
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+type notifier struct {
+	channels    map[string]*notificationChannel
+	dedupWindow time.Duration
+	retries     int
+	// Cancelled when the application stops so that pending retries don't outlive it
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+func newNotifier(config *notificationsConfig) (*notifier, error) {
+	for name, channel := range config.Channels {
+		if channel == nil {
+			return nil, fmt.Errorf("channel %s: type is required", name)
+		}
+
+		if err := channel.initialize(); err != nil {
+			return nil, fmt.Errorf("channel %s: %v", name, err)
+		}
+	}
+
+	if config.Retries < 0 {
+		return nil, errors.New("retries must not be negative")
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+
+	return &notifier{
+		channels:    config.Channels,
+		dedupWindow: ternary(config.DedupWindow > 0, time.Duration(config.DedupWindow), defaultNotificationDedupWindow),
+		retries:     ternary(config.Retries > 0, config.Retries, defaultNotificationRetries),
+		ctx:         ctx,
+		stop:        stop,
+	}, nil
+}
+
+func (n *notifier) send(channelNames []string, notification notification) {
+	notification.Time = time.Now()
+
+	for _, name := range channelNames {
+		channel, exists := n.channels[name]
+		if !exists {
+			continue
+		}
+
+		dedupKey := name + "\x00" + notification.key
+		fingerprint := notification.Event + "\x00" + notification.Title + "\x00" + notification.Message
+
+		if !recentNotifications.claim(dedupKey, fingerprint, n.dedupWindow) {
+			continue
+		}
+
+		go n.deliver(name, channel, notification, dedupKey)
+	}
+}
+
+func (n *notifier) deliver(name string, channel *notificationChannel, notification notification, dedupKey string) {
+	delay := notificationFirstRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(n.ctx, notificationSendTimeout)
+		err := channel.send(ctx, &notification)
+		cancel()
+
+		if err == nil {
+			return
+		}
+
+		if attempt >= n.retries {
+			slog.Error("Failed to send notification", "channel", name, "title", notification.Title, "error", err)
+			// so that it isn't treated as a duplicate if the same thing happens again
+			recentNotifications.release(dedupKey)
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-n.ctx.Done():
+			// the next application will send it again if the state is still the same
+			timer.Stop()
+			recentNotifications.release(dedupKey)
+			return
+		case <-timer.C:
+		}
+
+		delay *= 3
+	}
+}
+
+// Kept across config reloads so that reloading doesn't cause duplicates
+var recentNotifications = &notificationDeduplicator{
+	sent: make(map[string]sentNotification),
+}
+
+type sentNotification struct {
+	fingerprint string
+	sentAt      time.Time
+}
+
+type notificationDeduplicator struct {
+	mu   sync.Mutex
+	sent map[string]sentNotification
+}
+
+// Returns false if an identical notification with the same key has already been sent within the window
+func (d *notificationDeduplicator) claim(key, fingerprint string, window time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+
+	for k, sent := range d.sent {
+		if now.Sub(sent.sentAt) >= window {
+			delete(d.sent, k)
+		}
+	}
+
+	if sent, exists := d.sent[key]; exists && sent.fingerprint == fingerprint {
+		return false
+	}
+
+	d.sent[key] = sentNotification{fingerprint: fingerprint, sentAt: now}
+	return true
+}
+
+func (d *notificationDeduplicator) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.sent, key)
+}
+
+// The last known states of the things that notifications get sent for, persisted so
+// that changes which happen while Glance isn't running still get notified about
+var notificationStates = &notificationStateStore{
+	entries: make(map[string]*notificationStateEntry),
+}
+
+type notificationStateEntry struct {
+	State    string `json:"s"`
+	LastSeen int64  `json:"t"`
+	// A different state that has been seen, but not yet enough times in a row to replace the current one
+	pending      string
+	pendingCount int
+}
+
+type notificationStateStore struct {
+	mu             sync.Mutex
+	filePath       string
+	entries        map[string]*notificationStateEntry
+	dirty          bool
+	lastSave       time.Time
+	lastSaveFailed bool
+}
+
+func (s *notificationStateStore) open(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.filePath == filePath {
+		return
+	}
+
+	if s.filePath != "" && s.dirty {
+		s.saveLocked()
+	}
+
+	s.filePath = filePath
+	s.lastSaveFailed = false
+
+	contents, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to read notification states: %v", err)
+		return
+	}
+
+	var entries map[string]*notificationStateEntry
+	if err := json.Unmarshal(contents, &entries); err != nil {
+		log.Printf("Failed to parse notification states, starting with empty ones: %v", err)
+		return
+	}
+
+	for key, entry := range entries {
+		if _, exists := s.entries[key]; !exists && entry != nil {
+			s.entries[key] = entry
+		}
+	}
+}
+
+// Records the state of something and returns the previous state if it has changed. A new state
+// has to be seen the given number of times in a row before it's considered a change, and the
+// first state seen for a key is never considered one.
+func (s *notificationStateStore) observe(key, state string, after int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dirty = true
+	now := time.Now().Unix()
+
+	entry, exists := s.entries[key]
+	if !exists {
+		s.entries[key] = &notificationStateEntry{State: state, LastSeen: now}
+		return "", false
+	}
+
+	entry.LastSeen = now
+
+	if state == entry.State {
+		entry.pending, entry.pendingCount = "", 0
+		return "", false
+	}
+
+	if state != entry.pending {
+		entry.pending, entry.pendingCount = state, 0
+	}
+
+	entry.pendingCount++
+	if entry.pendingCount < after {
+		return "", false
+	}
+
+	previous := entry.State
+	entry.State, entry.pending, entry.pendingCount = state, "", 0
+
+	return previous, true
+}
+
+func (s *notificationStateStore) saveIfDirty(force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty || s.filePath == "" {
+		return
+	}
+
+	if !force && time.Since(s.lastSave) < notificationStatesSaveInterval {
+		return
+	}
+
+	s.saveLocked()
+}
+
+func (s *notificationStateStore) saveLocked() {
+	s.lastSave = time.Now()
+
+	oldest := time.Now().Add(-notificationStatesMaxAge).Unix()
+	for key, entry := range s.entries {
+		if entry.LastSeen < oldest {
+			delete(s.entries, key)
+		}
+	}
+
+	contents, err := json.Marshal(s.entries)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.filePath), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(s.filePath, contents, 0o644)
+	}
+
+	if err != nil {
+		if !s.lastSaveFailed {
+			log.Printf("Failed to save notification states: %v", err)
+		}
+
+		s.lastSaveFailed = true
+		return
+	}
+
+	s.lastSaveFailed = false
+	s.dirty = false
+}
+
+// Lets widgets which look for changes both when they update and in the background
+// skip the background check when an update has recently done it
+type notificationCheckTimer struct {
+	lastCheck atomic.Int64
+}
+
+func (t *notificationCheckTimer) markChecked() {
+	t.lastCheck.Store(time.Now().UnixNano())
+}
+
+func (t *notificationCheckTimer) checkedWithin(d time.Duration) bool {
+	return time.Since(time.Unix(0, t.lastCheck.Load())) < d
+}
+
+// Calls check on the given interval until the context is done, unless the
+// check has been done by something else since the previous interval
+func runNotificationChecks(
+	ctx context.Context,
+	app *application,
+	interval time.Duration,
+	timer *notificationCheckTimer,
+	check func(),
+) {
+	notificationStates.open(filepath.Join(app.dataPath(), notificationStatesFileName))
+	defer notificationStates.saveIfDirty(true)
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if !timer.checkedWithin(interval * 9 / 10) {
+			check()
+			notificationStates.saveIfDirty(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
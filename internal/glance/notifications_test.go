@@ -0,0 +1,414 @@
+package glance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNotificationDeduplicatorClaim(t *testing.T) {
+	deduplicator := &notificationDeduplicator{sent: make(map[string]sentNotification)}
+
+	steps := []struct {
+		name        string
+		key         string
+		fingerprint string
+		before      func()
+		want        bool
+	}{
+		{name: "first notification", key: "ntfy\x00site", fingerprint: "down", want: true},
+		{name: "identical notification", key: "ntfy\x00site", fingerprint: "down", want: false},
+		{name: "same notification for another channel", key: "email\x00site", fingerprint: "down", want: true},
+		{name: "different notification for the same key", key: "ntfy\x00site", fingerprint: "up", want: true},
+		{name: "previous notification again after a change", key: "ntfy\x00site", fingerprint: "down", want: true},
+		{
+			name:        "identical notification after it was released",
+			key:         "ntfy\x00site",
+			fingerprint: "down",
+			before:      func() { deduplicator.release("ntfy\x00site") },
+			want:        true,
+		},
+		{
+			name:        "identical notification after the window",
+			key:         "email\x00site",
+			fingerprint: "down",
+			before: func() {
+				deduplicator.sent["email\x00site"] = sentNotification{fingerprint: "down", sentAt: time.Now().Add(-2 * time.Hour)}
+			},
+			want: true,
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		if got := deduplicator.claim(step.key, step.fingerprint, time.Hour); got != step.want {
+			t.Errorf("%s: claim() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestNotificationStateStoreObserve(t *testing.T) {
+	type observation struct {
+		state        string
+		wantPrevious string
+		wantChanged  bool
+	}
+
+	tests := []struct {
+		name         string
+		after        int
+		observations []observation
+	}{
+		{
+			name:  "first state is never a change",
+			after: 1,
+			observations: []observation{
+				{"up", "", false},
+				{"up", "", false},
+			},
+		},
+		{
+			name:  "changes immediately with after 1",
+			after: 1,
+			observations: []observation{
+				{"up", "", false},
+				{"down", "up", true},
+				{"down", "", false},
+				{"up", "down", true},
+			},
+		},
+		{
+			name:  "needs consecutive observations",
+			after: 3,
+			observations: []observation{
+				{"up", "", false},
+				{"down", "", false},
+				{"down", "", false},
+				{"down", "up", true},
+				{"down", "", false},
+			},
+		},
+		{
+			name:  "flapping resets the count",
+			after: 2,
+			observations: []observation{
+				{"up", "", false},
+				{"down", "", false},
+				{"up", "", false},
+				{"down", "", false},
+				{"degraded", "", false},
+				{"degraded", "up", true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &notificationStateStore{entries: make(map[string]*notificationStateEntry)}
+
+			for i, o := range tt.observations {
+				previous, changed := store.observe("site", o.state, tt.after)
+				if previous != o.wantPrevious || changed != o.wantChanged {
+					t.Errorf("observation %d (%s): observe() = %q, %v, want %q, %v", i, o.state, previous, changed, o.wantPrevious, o.wantChanged)
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationStateStorePersistence(t *testing.T) {
+	filePath := t.TempDir() + "/notifications.json"
+
+	store := &notificationStateStore{entries: make(map[string]*notificationStateEntry)}
+	store.open(filePath)
+	store.observe("site", "up", 1)
+	store.saveIfDirty(true)
+
+	// a change that happened while glance wasn't running is still notified about
+	restarted := &notificationStateStore{entries: make(map[string]*notificationStateEntry)}
+	restarted.open(filePath)
+
+	if previous, changed := restarted.observe("site", "down", 1); previous != "up" || !changed {
+		t.Errorf("observe() after restart = %q, %v, want \"up\", true", previous, changed)
+	}
+}
+
+type recordedNotificationRequest struct {
+	method  string
+	path    string
+	headers http.Header
+	body    string
+}
+
+func TestNotificationChannelPayloads(t *testing.T) {
+	n := &notification{
+		Event:   "down",
+		Widget:  "monitor",
+		Title:   "Jellyfin is down",
+		Message: "Jellyfin has been down since 12:00",
+		URL:     "https://jellyfin.example.com",
+		Time:    time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		channel notificationChannel
+		check   func(t *testing.T, r recordedNotificationRequest)
+	}{
+		{
+			name:    "default webhook body",
+			channel: notificationChannel{Type: "webhook", Headers: map[string]string{"X-Token": "secret"}},
+			check: func(t *testing.T, r recordedNotificationRequest) {
+				var body map[string]any
+				if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", r.body, err)
+				}
+
+				if r.method != http.MethodPost || r.headers.Get("X-Token") != "secret" {
+					t.Errorf("request = %s with headers %v, want POST with the configured headers", r.method, r.headers)
+				}
+
+				if body["event"] != "down" || body["widget"] != "monitor" || body["title"] != n.Title || body["url"] != n.URL || body["time"] != "2026-01-02T12:00:00Z" {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+		{
+			name: "webhook body template",
+			channel: notificationChannel{
+				Type:   "webhook",
+				Method: http.MethodPut,
+				Body:   `{"text": {{ .Title | json }}, "event": "{{ .Event }}"}`,
+			},
+			check: func(t *testing.T, r recordedNotificationRequest) {
+				if r.method != http.MethodPut || r.body != `{"text": "Jellyfin is down", "event": "down"}` {
+					t.Errorf("request = %s %s", r.method, r.body)
+				}
+			},
+		},
+		{
+			name:    "ntfy",
+			channel: notificationChannel{Type: "ntfy", Topic: "alerts", Token: "tk_abc", Priority: 4},
+			check: func(t *testing.T, r recordedNotificationRequest) {
+				if r.path != "/alerts" || r.body != n.Message {
+					t.Errorf("request = %s %q", r.path, r.body)
+				}
+
+				want := map[string]string{
+					"Title":         "Jellyfin is down",
+					"Tags":          "down",
+					"Click":         n.URL,
+					"Priority":      "4",
+					"Authorization": "Bearer tk_abc",
+				}
+
+				for key, value := range want {
+					if got := r.headers.Get(key); got != value {
+						t.Errorf("header %s = %q, want %q", key, got, value)
+					}
+				}
+			},
+		},
+		{
+			name:    "gotify",
+			channel: notificationChannel{Type: "gotify", Token: "app-token", Priority: 8},
+			check: func(t *testing.T, r recordedNotificationRequest) {
+				if r.path != "/message" || r.headers.Get("X-Gotify-Key") != "app-token" {
+					t.Errorf("request = %s with headers %v", r.path, r.headers)
+				}
+
+				var body struct {
+					Title    string `json:"title"`
+					Message  string `json:"message"`
+					Priority int    `json:"priority"`
+					Extras   map[string]struct {
+						Click struct {
+							URL string `json:"url"`
+						} `json:"click"`
+					} `json:"extras"`
+				}
+
+				if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", r.body, err)
+				}
+
+				if body.Title != n.Title || body.Message != n.Message || body.Priority != 8 || body.Extras["client::notification"].Click.URL != n.URL {
+					t.Errorf("body = %+v", body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan recordedNotificationRequest, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests <- recordedNotificationRequest{r.Method, r.URL.Path, r.Header, string(body)}
+			}))
+			defer server.Close()
+
+			channel := tt.channel
+			channel.URL = server.URL + "/"
+			if err := channel.initialize(); err != nil {
+				t.Fatalf("initialize() error = %v", err)
+			}
+
+			if err := channel.send(context.Background(), n); err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+
+			tt.check(t, <-requests)
+		})
+	}
+}
+
+// Accepts a single message without any extensions and returns its data
+func startFakeSMTPServer(t *testing.T) (string, uint16, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+
+		var data strings.Builder
+		inData := false
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					messages <- data.String()
+					conn.Write([]byte("250 OK\r\n"))
+					continue
+				}
+
+				data.WriteString(line)
+				continue
+			}
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 Go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 Bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return "127.0.0.1", uint16(portNumber), messages
+}
+
+func TestNotificationChannelEmailPayload(t *testing.T) {
+	host, port, messages := startFakeSMTPServer(t)
+
+	channel := notificationChannel{Type: "email", Host: host, Port: port, From: "glance@example.com", To: []string{"a@example.com", "b@example.com"}}
+	if err := channel.initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := channel.send(context.Background(), &notification{
+		Title:   "Jellyfin is down",
+		Message: "Jellyfin has been down since 12:00",
+		URL:     "https://jellyfin.example.com",
+		Time:    time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	message := <-messages
+	for _, want := range []string{
+		"From: glance@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Jellyfin is down\r\n",
+		"Date: Fri, 02 Jan 2026 12:00:00 +0000\r\n",
+		"\r\n\r\nJellyfin has been down since 12:00\r\n\r\nhttps://jellyfin.example.com\r\n",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message does not contain %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestNotifierStopCancelsRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier, err := newNotifier(&notificationsConfig{Channels: map[string]*notificationChannel{
+		"hook": {Type: "webhook", URL: server.URL},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := notification{Event: "down", Title: "Stop test", key: "notifier-stop-test"}
+	notifier.send([]string{"hook"}, n)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	notifier.stop()
+
+	// the notification is released rather than waiting for the next retry
+	dedupKey := "hook\x00" + n.key
+	fingerprint := n.Event + "\x00" + n.Title + "\x00" + n.Message
+	for !recentNotifications.claim(dedupKey, fingerprint, time.Hour) {
+		if time.Now().After(deadline) {
+			t.Fatal("notification was not released after stopping the notifier")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+
+	recentNotifications.release(dedupKey)
+}
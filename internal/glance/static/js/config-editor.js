@@ -0,0 +1,200 @@
+import { find, elem } from "./templating.js";
+
+const FILE_ENDPOINT = pageData.baseURL + "/api/admin/config/file";
+
+const fileSelect = find("#config-editor-file");
+const textarea = find("#config-editor-textarea");
+const gutter = find("#config-editor-gutter");
+const statusMessage = find("#config-editor-status");
+const errorsList = find("#config-editor-errors");
+const validateButton = find("#config-editor-validate");
+const saveButton = find("#config-editor-save");
+
+const state = {
+    name: "",
+    hash: "",
+    savedContents: "",
+    errorLines: new Set(),
+    isLoading: false,
+};
+
+const lang = {
+    loadFailed: "Could not load the file",
+    valid: "Config is valid",
+    saved: "Saved, the config will be reloaded shortly",
+    unsavedChanges: "You have unsaved changes, discard them?",
+    unknownError: "An error occurred, please try again",
+};
+
+function setStatus(message, isError = false) {
+    statusMessage.text(message).classesIf(isError, "is-error");
+}
+
+function updateButtons() {
+    const hasChanges = textarea.value !== state.savedContents;
+    validateButton.disabled = state.isLoading;
+    saveButton.disabled = state.isLoading || !hasChanges;
+}
+
+function renderGutter() {
+    const lineCount = textarea.value.split("\n").length;
+    const lines = new Array(lineCount);
+
+    for (let i = 0; i < lineCount; i++) {
+        lines[i] = elem().text(i + 1).classesIf(state.errorLines.has(i + 1), "has-error");
+    }
+
+    gutter.replaceChildren(...lines);
+    gutter.scrollTop = textarea.scrollTop;
+}
+
+function renderErrors(errors) {
+    state.errorLines.clear();
+    errorsList.replaceChildren();
+
+    for (const error of errors) {
+        const sameFile = error.file === state.name;
+        const location = error.file ? `${error.file}:${error.line}: ` : "";
+
+        if (sameFile && error.line > 0) state.errorLines.add(error.line);
+
+        errorsList.append(
+            elem("li")
+                .text(location + error.message)
+                .on("click", () => { if (sameFile && error.line > 0) goToLine(error.line); })
+        );
+    }
+
+    renderGutter();
+}
+
+function goToLine(line) {
+    const lines = textarea.value.split("\n");
+    let position = 0;
+
+    for (let i = 0; i < line - 1 && i < lines.length; i++) {
+        position += lines[i].length + 1;
+    }
+
+    textarea.focus();
+    textarea.setSelectionRange(position, position + (lines[line - 1]?.length ?? 0));
+
+    const lineHeight = parseFloat(getComputedStyle(textarea).lineHeight);
+    textarea.scrollTop = Math.max(0, (line - 5) * lineHeight);
+}
+
+async function loadFile(name) {
+    state.isLoading = true;
+    textarea.disabled = true;
+    updateButtons();
+    setStatus("");
+    renderErrors([]);
+
+    try {
+        const response = await fetch(FILE_ENDPOINT + "?name=" + encodeURIComponent(name));
+        const data = await response.json();
+
+        if (!response.ok) {
+            setStatus(data.error || lang.loadFailed, true);
+            return;
+        }
+
+        state.name = name;
+        state.hash = data.hash;
+        state.savedContents = data.contents;
+        textarea.value = data.contents;
+        textarea.scrollTop = 0;
+        textarea.disabled = false;
+    } catch (e) {
+        setStatus(lang.loadFailed, true);
+    } finally {
+        state.isLoading = false;
+        updateButtons();
+        renderGutter();
+    }
+}
+
+async function submit(validateOnly) {
+    state.isLoading = true;
+    updateButtons();
+    setStatus("");
+
+    try {
+        const contents = textarea.value;
+        const response = await fetch(FILE_ENDPOINT, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({
+                name: state.name,
+                contents: contents,
+                hash: state.hash,
+                "validate-only": validateOnly,
+            }),
+        });
+
+        const data = await response.json();
+
+        if (response.status === 422) {
+            renderErrors(data.errors);
+            return;
+        }
+
+        renderErrors([]);
+
+        if (!response.ok) {
+            setStatus(data.error || lang.unknownError, true);
+            return;
+        }
+
+        if (validateOnly) {
+            setStatus(lang.valid);
+            return;
+        }
+
+        state.hash = data.hash;
+        state.savedContents = contents;
+        setStatus(lang.saved);
+    } catch (e) {
+        setStatus(lang.unknownError, true);
+    } finally {
+        state.isLoading = false;
+        updateButtons();
+    }
+}
+
+textarea.on("input", () => {
+    updateButtons();
+    renderGutter();
+});
+
+textarea.on("scroll", () => {
+    gutter.scrollTop = textarea.scrollTop;
+});
+
+textarea.on("keydown", (event) => {
+    if (event.key === "Tab" && !event.shiftKey) {
+        event.preventDefault();
+        document.execCommand("insertText", false, "  ");
+    } else if (event.key === "s" && (event.ctrlKey || event.metaKey)) {
+        event.preventDefault();
+        if (!saveButton.disabled) submit(false);
+    }
+});
+
+fileSelect.on("change", () => {
+    if (textarea.value !== state.savedContents && !confirm(lang.unsavedChanges)) {
+        fileSelect.value = state.name;
+        return;
+    }
+
+    loadFile(fileSelect.value);
+});
+
+window.addEventListener("beforeunload", (event) => {
+    if (textarea.value !== state.savedContents) event.preventDefault();
+});
+
+validateButton.on("click", () => submit(true));
+saveButton.on("click", () => submit(false));
+
+if (fileSelect.value) loadFile(fileSelect.value);
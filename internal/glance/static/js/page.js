@@ -744,6 +744,95 @@ function initThemePicker() {
     })
 }
 
+// Used by the docker containers, systemd and home assistant widgets, each button specifies
+// the path of the request within the widget's API and the name of what the action is performed on
+function setupWidgetActions() {
+    const buttons = document.getElementsByClassName("widget-action");
+
+    for (let i = 0; i < buttons.length; i++) {
+        const button = buttons[i];
+        if (button.classList.contains("docker-container-logs-button")) continue;
+        const { widgetId, path, name, action } = button.dataset;
+        const needsConfirmation = button.dataset.confirm !== "false";
+
+        button.addEventListener("click", async () => {
+            if (button.disabled) return;
+            if (needsConfirmation && !confirm(`Are you sure you want to ${action} ${name}?`)) return;
+
+            button.disabled = true;
+            button.classList.add("widget-action-pending");
+
+            try {
+                const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetId}/${path}`, {
+                    method: "POST",
+                });
+
+                if (!response.ok) {
+                    const body = await response.json().catch(() => ({}));
+                    alert(`Failed to ${action} ${name}: ${body.error || response.statusText}`);
+                    return;
+                }
+
+                button.classList.add("widget-action-done");
+
+                // widgets can respond with the new state of the item to show it without reloading the page
+                const body = await response.json().catch(() => ({}));
+                const stateElement = button.closest("[data-action-item]")?.querySelector("[data-action-state]");
+                if (stateElement && body.state !== undefined) stateElement.textContent = body.state;
+            } catch (e) {
+                alert(`Failed to ${action} ${name}: ${e.message}`);
+            } finally {
+                button.disabled = false;
+                button.classList.remove("widget-action-pending");
+            }
+        });
+    }
+}
+
+function setupDockerContainerLogs() {
+    const buttons = document.getElementsByClassName("docker-container-logs-button");
+
+    for (let i = 0; i < buttons.length; i++) {
+        const button = buttons[i];
+        const logsElement = button.parentElement.nextElementSibling;
+        const { widgetId, path, name } = button.dataset;
+
+        button.addEventListener("click", async () => {
+            if (button.disabled) return;
+
+            button.disabled = true;
+            button.classList.add("widget-action-pending");
+
+            try {
+                const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetId}/${path}`);
+                const body = await response.json().catch(() => ({}));
+
+                if (!response.ok) {
+                    alert(`Failed to get the logs of ${name}: ${body.error || response.statusText}`);
+                    return;
+                }
+
+                const lines = body.lines || [];
+                logsElement.replaceChildren(...lines.map(line => {
+                    const element = document.createElement("div");
+                    element.textContent = line.text;
+                    if (line.stream === "stderr") element.classList.add("docker-container-logs-stderr");
+                    return element;
+                }));
+
+                if (lines.length === 0) logsElement.textContent = "No logs available.";
+                logsElement.hidden = false;
+                logsElement.scrollTop = logsElement.scrollHeight;
+            } catch (e) {
+                alert(`Failed to get the logs of ${name}: ${e.message}`);
+            } finally {
+                button.disabled = false;
+                button.classList.remove("widget-action-pending");
+            }
+        });
+    }
+}
+
 async function setupPage() {
     initThemePicker();
 
@@ -766,6 +855,8 @@ async function setupPage() {
         setupMasonries();
         setupDynamicRelativeTime();
         setupLazyImages();
+        setupWidgetActions();
+        setupDockerContainerLogs();
     } finally {
         pageElement.classList.add("content-ready");
         pageElement.setAttribute("aria-busy", "false");
@@ -0,0 +1,302 @@
+package glance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var statusPageTemplate = mustParseTemplate("status-page.html", "document.html", "footer.html")
+
+// Containers are fetched when the page is viewed, since status pages are public this limits
+// how often that can happen regardless of how many requests are made
+const statusPageContainersCacheDuration = 30 * time.Second
+
+const (
+	statusPageStateOperational = "operational"
+	statusPageStateDegraded    = "degraded"
+	statusPageStateDown        = "down"
+	statusPageStateMaintenance = "maintenance"
+	statusPageStateUnknown     = "unknown"
+)
+
+type statusPage struct {
+	Title string `yaml:"title"`
+	Slug  string `yaml:"slug"`
+	// Shown at the top of the page, used to let people know about ongoing incidents
+	Message    string   `yaml:"message"`
+	Sites      []string `yaml:"sites"`
+	Containers []string `yaml:"containers"`
+
+	sites              []statusPageSite
+	dockerWidgets      []*dockerContainersWidget
+	containersMu       sync.Mutex
+	containers         map[string]dockerContainer
+	containersCachedAt time.Time
+}
+
+type statusPageSite struct {
+	widget *monitorWidget
+	index  int
+}
+
+type statusPageItem struct {
+	Title     string
+	State     string
+	StateText string
+	History   *monitorSiteHistoryData
+}
+
+type statusPageTemplateData struct {
+	templateData
+	StatusPage   *statusPage
+	Items        []statusPageItem
+	OverallState string
+	OverallText  string
+	UpdatedAt    time.Time
+}
+
+func (a *application) initStatusPages() error {
+	var monitorWidgets []*monitorWidget
+	var dockerWidgets []*dockerContainersWidget
+
+	for _, widget := range a.widgetByID {
+		switch widget := widget.(type) {
+		case *monitorWidget:
+			monitorWidgets = append(monitorWidgets, widget)
+		case *dockerContainersWidget:
+			dockerWidgets = append(dockerWidgets, widget)
+		}
+	}
+
+	// widgetByID is a map, so sort them to make the lookups below deterministic
+	slices.SortFunc(monitorWidgets, func(a, b *monitorWidget) int { return int(a.GetID()) - int(b.GetID()) })
+	slices.SortFunc(dockerWidgets, func(a, b *dockerContainersWidget) int { return int(a.GetID()) - int(b.GetID()) })
+
+	slugs := make(map[string]bool)
+
+	for i := range a.Config.StatusPages {
+		page := &a.Config.StatusPages[i]
+
+		if page.Title == "" {
+			page.Title = "Status"
+		}
+
+		if page.Slug == "" {
+			page.Slug = titleToSlug(page.Title)
+		}
+
+		if page.Slug == "" || strings.ContainsAny(page.Slug, "/?#") {
+			return fmt.Errorf("status page slug \"%s\" is invalid, it must not be empty or contain any of / ? #", page.Slug)
+		}
+
+		if slugs[page.Slug] {
+			return fmt.Errorf("status page slug %s is used more than once", page.Slug)
+		}
+		slugs[page.Slug] = true
+
+		if len(page.Sites) == 0 && len(page.Containers) == 0 {
+			return fmt.Errorf("status page %s: at least one site or container is required", page.Slug)
+		}
+
+	sites:
+		for _, title := range page.Sites {
+			for _, widget := range monitorWidgets {
+				for s := range widget.Sites {
+					if strings.EqualFold(widget.Sites[s].Title, title) {
+						page.sites = append(page.sites, statusPageSite{widget: widget, index: s})
+						widget.onStatusPage = true
+						continue sites
+					}
+				}
+			}
+
+			return fmt.Errorf("status page %s: no monitor widget has a site titled %s", page.Slug, title)
+		}
+
+		if len(page.Containers) > 0 {
+			if len(dockerWidgets) == 0 {
+				return fmt.Errorf("status page %s: containers require a docker-containers widget", page.Slug)
+			}
+
+			page.dockerWidgets = dockerWidgets
+		}
+	}
+
+	return nil
+}
+
+func (a *application) handleStatusPageRequest(w http.ResponseWriter, r *http.Request) {
+	var page *statusPage
+	for i := range a.Config.StatusPages {
+		if a.Config.StatusPages[i].Slug == r.PathValue("slug") {
+			page = &a.Config.StatusPages[i]
+			break
+		}
+	}
+
+	if page == nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	// status pages are meant to be public, so authorization is deliberately not checked
+	now := time.Now()
+
+	data := statusPageTemplateData{
+		templateData: templateData{App: a},
+		StatusPage:   page,
+		Items:        append(page.siteItems(now), page.containerItems(now)...),
+		UpdatedAt:    now,
+	}
+	a.populateTemplateRequestData(&data.Request, r)
+	data.OverallState, data.OverallText = statusPageOverallState(data.Items)
+
+	var responseBytes bytes.Buffer
+	if err := statusPageTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+func (p *statusPage) siteItems(now time.Time) []statusPageItem {
+	items := make([]statusPageItem, len(p.sites))
+	statusesByWidget := make(map[*monitorWidget][]siteStatus)
+
+	for i := range p.sites {
+		widget := p.sites[i].widget
+		site := &widget.Sites[p.sites[i].index]
+		items[i] = statusPageItem{Title: site.Title, State: statusPageStateUnknown, StateText: "Unknown"}
+
+		statuses, checked := statusesByWidget[widget]
+		if !checked {
+			// only checks the sites which haven't been checked recently in the background
+			var err error
+			statuses, err = widget.checkSites(widget.cacheDuration)
+			if err != nil {
+				slog.Error("Failed to check sites for status page", "error", err)
+			}
+
+			statusesByWidget[widget] = statuses
+		}
+
+		history := monitorHistory.history(site.historyKey)
+		items[i].History = history.templateData(now)
+
+		if statuses == nil {
+			continue
+		}
+
+		// the specific reason of failures isn't shown, since it may reveal internal details
+		_, style, _ := site.evaluateStatus(&statuses[p.sites[i].index], widget.Maintenance.covers(site.Title, now))
+
+		switch style {
+		case "ok":
+			items[i].State, items[i].StateText = statusPageStateOperational, "Operational"
+		case "degraded":
+			items[i].State, items[i].StateText = statusPageStateDegraded, "Degraded"
+		case "maintenance":
+			items[i].State, items[i].StateText = statusPageStateMaintenance, "Maintenance"
+		default:
+			items[i].State, items[i].StateText = statusPageStateDown, "Down"
+		}
+	}
+
+	return items
+}
+
+func (p *statusPage) containerItems(now time.Time) []statusPageItem {
+	if len(p.Containers) == 0 {
+		return nil
+	}
+
+	containers := p.cachedContainers(now)
+	items := make([]statusPageItem, len(p.Containers))
+
+	for i, name := range p.Containers {
+		items[i] = statusPageItem{Title: name, State: statusPageStateUnknown, StateText: "Unknown"}
+
+		container, exists := containers[strings.ToLower(name)]
+		if !exists {
+			continue
+		}
+
+		items[i].Title = container.Name
+
+		switch container.StateIcon {
+		case dockerContainerStateIconOK:
+			items[i].State, items[i].StateText = statusPageStateOperational, "Operational"
+		case dockerContainerStateIconMaintenance:
+			items[i].State, items[i].StateText = statusPageStateMaintenance, "Maintenance"
+		case dockerContainerStateIconPaused:
+			items[i].State, items[i].StateText = statusPageStateDown, "Paused"
+		default:
+			items[i].State, items[i].StateText = statusPageStateDown, ternary(container.State == "running", "Unhealthy", "Down")
+		}
+	}
+
+	return items
+}
+
+func (p *statusPage) cachedContainers(now time.Time) map[string]dockerContainer {
+	p.containersMu.Lock()
+	defer p.containersMu.Unlock()
+
+	if p.containers != nil && now.Sub(p.containersCachedAt) < statusPageContainersCacheDuration {
+		return p.containers
+	}
+
+	containers := make(map[string]dockerContainer)
+
+	for _, widget := range p.dockerWidgets {
+		fetched, err := widget.fetchContainers()
+		if err != nil && !errors.Is(err, errPartialContent) {
+			slog.Error("Failed to fetch containers for status page", "error", err)
+			continue
+		}
+
+		widget.markContainersInMaintenance(fetched)
+
+		for i := range fetched {
+			for _, container := range append(dockerContainerList{fetched[i]}, fetched[i].Children...) {
+				key := strings.ToLower(container.Name)
+				if _, exists := containers[key]; !exists {
+					containers[key] = container
+				}
+			}
+		}
+	}
+
+	p.containers = containers
+	p.containersCachedAt = now
+
+	return containers
+}
+
+func statusPageOverallState(items []statusPageItem) (string, string) {
+	hasState := func(state string) bool {
+		return slices.ContainsFunc(items, func(item statusPageItem) bool { return item.State == state })
+	}
+
+	switch {
+	case hasState(statusPageStateDown):
+		return statusPageStateDown, "Some services are down"
+	case hasState(statusPageStateDegraded):
+		return statusPageStateDegraded, "Some services are degraded"
+	case hasState(statusPageStateMaintenance):
+		return statusPageStateMaintenance, "Some services are under maintenance"
+	case hasState(statusPageStateUnknown):
+		return statusPageStateUnknown, "The status of some services is unknown"
+	}
+
+	return statusPageStateOperational, "All services are operational"
+}
@@ -0,0 +1,115 @@
+package glance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInitStatusPages(t *testing.T) {
+	monitor := &monitorWidget{Sites: []monitorSite{{Title: "Jellyfin"}, {Title: "Immich"}}}
+	monitor.ID = 1
+
+	docker := &dockerContainersWidget{}
+	docker.ID = 2
+
+	tests := []struct {
+		name      string
+		pages     []statusPage
+		widgets   []widget
+		wantSlugs []string
+		wantErr   string
+	}{
+		{
+			name:      "slug from title",
+			pages:     []statusPage{{Title: "Home Services", Sites: []string{"jellyfin"}}},
+			widgets:   []widget{monitor},
+			wantSlugs: []string{"home-services"},
+		},
+		{
+			name:      "default title",
+			pages:     []statusPage{{Sites: []string{"Immich"}}},
+			widgets:   []widget{monitor},
+			wantSlugs: []string{"status"},
+		},
+		{
+			name: "explicit slugs",
+			pages: []statusPage{
+				{Title: "Media", Slug: "media", Sites: []string{"Jellyfin"}},
+				{Title: "Media", Slug: "media-2", Containers: []string{"plex"}},
+			},
+			widgets:   []widget{monitor, docker},
+			wantSlugs: []string{"media", "media-2"},
+		},
+		{
+			name: "duplicate slug",
+			pages: []statusPage{
+				{Title: "Media", Sites: []string{"Jellyfin"}},
+				{Slug: "media", Sites: []string{"Immich"}},
+			},
+			widgets: []widget{monitor},
+			wantErr: "used more than once",
+		},
+		{
+			name:    "slug with a slash",
+			pages:   []statusPage{{Slug: "status/media", Sites: []string{"Jellyfin"}}},
+			widgets: []widget{monitor},
+			wantErr: "is invalid",
+		},
+		{
+			name:    "title without any slug characters",
+			pages:   []statusPage{{Title: " ", Sites: []string{"Jellyfin"}}},
+			widgets: []widget{monitor},
+			wantErr: "is invalid",
+		},
+		{
+			name:    "no sites or containers",
+			pages:   []statusPage{{Title: "Empty"}},
+			wantErr: "at least one site or container",
+		},
+		{
+			name:    "unknown site",
+			pages:   []statusPage{{Sites: []string{"Plex"}}},
+			widgets: []widget{monitor},
+			wantErr: "no monitor widget has a site titled Plex",
+		},
+		{
+			name:    "containers without a docker widget",
+			pages:   []statusPage{{Containers: []string{"plex"}}},
+			widgets: []widget{monitor},
+			wantErr: "require a docker-containers widget",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{widgetByID: make(map[uint64]widget)}
+			monitor.onStatusPage = false
+			app.Config.StatusPages = tt.pages
+			for _, w := range tt.widgets {
+				app.widgetByID[w.GetID()] = w
+			}
+
+			err := app.initStatusPages()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("initStatusPages() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("initStatusPages() error = %v", err)
+			}
+
+			for i, want := range tt.wantSlugs {
+				if got := app.Config.StatusPages[i].Slug; got != want {
+					t.Errorf("slug of page %d = %s, want %s", i, got, want)
+				}
+			}
+
+			if len(tt.pages[0].Sites) > 0 && !monitor.recordsHistory() {
+				t.Error("monitor widget with sites on a status page doesn't record history")
+			}
+		})
+	}
+}
@@ -0,0 +1,706 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var arrWidgetTemplate = mustParseTemplate("arr.html", "widget-base.html", "state-icon.html")
+
+const (
+	arrServiceSonarr   = "sonarr"
+	arrServiceRadarr   = "radarr"
+	arrServiceLidarr   = "lidarr"
+	arrServiceReadarr  = "readarr"
+	arrServiceProwlarr = "prowlarr"
+)
+
+type arrServiceKind struct {
+	title      string
+	apiVersion string
+	// Prowlarr only manages indexers, the rest also have a library with a calendar, a queue and wanted items
+	hasLibrary bool
+	// Makes the calendar and queue include the series, artist, etc. that each item belongs to
+	includeQuery string
+}
+
+var arrServiceKinds = map[string]*arrServiceKind{
+	arrServiceSonarr:   {"Sonarr", "v3", true, "includeSeries=true&includeEpisode=true"},
+	arrServiceRadarr:   {"Radarr", "v3", true, "includeMovie=true"},
+	arrServiceLidarr:   {"Lidarr", "v1", true, "includeArtist=true&includeAlbum=true"},
+	arrServiceReadarr:  {"Readarr", "v1", true, "includeAuthor=true&includeBook=true"},
+	arrServiceProwlarr: {"Prowlarr", "v1", false, ""},
+}
+
+type arrWidget struct {
+	widgetBase    `yaml:",inline"`
+	Services      []*arrService `yaml:"services"`
+	CalendarDays  int           `yaml:"calendar-days"`
+	CalendarLimit int           `yaml:"calendar-limit"`
+	QueueLimit    int           `yaml:"queue-limit"`
+	HideCalendar  bool          `yaml:"hide-calendar"`
+	HideQueue     bool          `yaml:"hide-queue"`
+	HideWanted    bool          `yaml:"hide-wanted"`
+	HideHealth    bool          `yaml:"hide-health"`
+	HideIndexers  bool          `yaml:"hide-indexers"`
+
+	Statuses []arrServiceStatus `yaml:"-"`
+}
+
+type arrService struct {
+	Type          string          `yaml:"type"`
+	Name          string          `yaml:"name"`
+	URL           string          `yaml:"url"`
+	APIKey        string          `yaml:"api-key" secret:"true"`
+	AllowInsecure bool            `yaml:"allow-insecure"`
+	Icon          customIconField `yaml:"icon"`
+
+	kind *arrServiceKind
+}
+
+type arrServiceStatus struct {
+	Name        string
+	URL         string
+	Icon        customIconField
+	Error       error
+	HasLibrary  bool
+	StateIcon   string
+	Calendar    []arrCalendarItem
+	Queue       []arrQueueItem
+	QueueTotal  int
+	Missing     int
+	CutoffUnmet int
+	Health      []arrHealthItem
+	Indexers    int
+	// The names of the enabled indexers which are currently disabled because of failures
+	FailingIndexers []string
+}
+
+type arrCalendarItem struct {
+	Title    string
+	Subtitle string
+	Date     time.Time
+	DateText string
+	HasFile  bool
+}
+
+type arrQueueItem struct {
+	Title           string
+	Subtitle        string
+	ProgressPercent int
+	StatusText      string
+	Message         string
+	IsWarning       bool
+}
+
+type arrHealthItem struct {
+	Source  string
+	Message string
+	WikiURL string
+	IsError bool
+}
+
+type arrCalendarRecord struct {
+	Title           string    `json:"title"`
+	HasFile         bool      `json:"hasFile"`
+	SeasonNumber    int       `json:"seasonNumber"`
+	EpisodeNumber   int       `json:"episodeNumber"`
+	AirDateUTC      time.Time `json:"airDateUtc"`
+	InCinemas       time.Time `json:"inCinemas"`
+	DigitalRelease  time.Time `json:"digitalRelease"`
+	PhysicalRelease time.Time `json:"physicalRelease"`
+	ReleaseDate     time.Time `json:"releaseDate"`
+	Series          *struct {
+		Title string `json:"title"`
+	} `json:"series"`
+	Artist *struct {
+		ArtistName string `json:"artistName"`
+	} `json:"artist"`
+	Author *struct {
+		AuthorName string `json:"authorName"`
+	} `json:"author"`
+}
+
+type arrQueueResponse struct {
+	TotalRecords int `json:"totalRecords"`
+	Records      []struct {
+		Title                 string  `json:"title"`
+		Size                  float64 `json:"size"`
+		SizeLeft              float64 `json:"sizeleft"`
+		TimeLeft              string  `json:"timeleft"`
+		Status                string  `json:"status"`
+		TrackedDownloadStatus string  `json:"trackedDownloadStatus"`
+		TrackedDownloadState  string  `json:"trackedDownloadState"`
+		ErrorMessage          string  `json:"errorMessage"`
+		StatusMessages        []struct {
+			Messages []string `json:"messages"`
+		} `json:"statusMessages"`
+		Series *struct {
+			Title string `json:"title"`
+		} `json:"series"`
+		Episode *struct {
+			Title         string `json:"title"`
+			SeasonNumber  int    `json:"seasonNumber"`
+			EpisodeNumber int    `json:"episodeNumber"`
+		} `json:"episode"`
+		Movie *struct {
+			Title string `json:"title"`
+			Year  int    `json:"year"`
+		} `json:"movie"`
+		Artist *struct {
+			ArtistName string `json:"artistName"`
+		} `json:"artist"`
+		Album *struct {
+			Title string `json:"title"`
+		} `json:"album"`
+		Author *struct {
+			AuthorName string `json:"authorName"`
+		} `json:"author"`
+		Book *struct {
+			Title string `json:"title"`
+		} `json:"book"`
+	} `json:"records"`
+}
+
+type arrHealthRecord struct {
+	Source  string `json:"source"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	WikiURL string `json:"wikiUrl"`
+}
+
+type arrIndexerRecord struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Enable bool   `json:"enable"`
+}
+
+type arrIndexerStatusRecord struct {
+	IndexerID    int       `json:"indexerId"`
+	DisabledTill time.Time `json:"disabledTill"`
+}
+
+type arrPagedResponse struct {
+	TotalRecords int `json:"totalRecords"`
+}
+
+func (widget *arrWidget) initialize() error {
+	if len(widget.Services) == 0 {
+		return errors.New("at least one service is required")
+	}
+
+	names := make(map[string]bool, len(widget.Services))
+
+	for _, service := range widget.Services {
+		kind, ok := arrServiceKinds[service.Type]
+		if !ok {
+			return fmt.Errorf(
+				"service type must be one of: %s, %s, %s, %s, %s",
+				arrServiceSonarr, arrServiceRadarr, arrServiceLidarr, arrServiceReadarr, arrServiceProwlarr,
+			)
+		}
+		service.kind = kind
+
+		service.URL = strings.TrimRight(service.URL, "/")
+		if service.URL == "" {
+			return fmt.Errorf("url is required for %s", service.Type)
+		}
+
+		if service.APIKey == "" {
+			return fmt.Errorf("api-key is required for %s", service.Type)
+		}
+
+		if service.Name == "" {
+			service.Name = kind.title
+		}
+
+		if names[service.Name] {
+			return fmt.Errorf("service name %s is used more than once, set a unique name for each instance", service.Name)
+		}
+		names[service.Name] = true
+
+		if service.Icon.URL == "" {
+			service.Icon = newCustomIconField("di:" + service.Type)
+		}
+	}
+
+	if len(widget.Services) == 1 {
+		widget.withTitle(widget.Services[0].Name).withTitleURL(widget.Services[0].URL)
+	} else {
+		widget.withTitle("Downloads")
+	}
+
+	widget.withCacheDuration(1 * time.Minute)
+
+	if widget.CalendarDays <= 0 {
+		widget.CalendarDays = 7
+	}
+
+	if widget.CalendarLimit <= 0 {
+		widget.CalendarLimit = 5
+	}
+
+	if widget.QueueLimit <= 0 {
+		widget.QueueLimit = 5
+	}
+
+	return nil
+}
+
+func (widget *arrWidget) update(ctx context.Context) {
+	statuses, err := widget.fetchStatuses()
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Statuses = statuses
+}
+
+func (widget *arrWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, arrWidgetTemplate)
+}
+
+// Services which couldn't be reached are still included along with their error, if only some
+// of them couldn't be reached or only returned some of their data the error wraps errPartialContent
+func (widget *arrWidget) fetchStatuses() ([]arrServiceStatus, error) {
+	job := newJob(widget.fetchServiceStatus, widget.Services).withWorkers(len(widget.Services))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, err
+	}
+
+	failed, partial := 0, 0
+	var firstErr error
+
+	for i := range results {
+		if errs[i] == nil {
+			continue
+		}
+
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", widget.Services[i].Name, errs[i])
+		}
+
+		if errors.Is(errs[i], errPartialContent) {
+			partial++
+			continue
+		}
+
+		failed++
+		results[i].Error = errs[i]
+	}
+
+	if failed == len(results) {
+		if len(results) == 1 {
+			return nil, errs[0]
+		}
+
+		return nil, fmt.Errorf("could not reach any of the %d services", len(results))
+	}
+
+	if failed > 0 || partial > 0 {
+		return results, fmt.Errorf("%w: %v", errPartialContent, firstErr)
+	}
+
+	return results, nil
+}
+
+func (widget *arrWidget) fetchServiceStatus(service *arrService) (arrServiceStatus, error) {
+	status := arrServiceStatus{
+		Name:       service.Name,
+		URL:        service.URL,
+		Icon:       service.Icon,
+		HasLibrary: service.kind.hasLibrary,
+	}
+	parts := make(map[string]func() error)
+
+	if service.kind.hasLibrary && !widget.HideCalendar {
+		parts["calendar"] = func() error {
+			items, err := fetchArrCalendar(service, widget.CalendarDays)
+			if err != nil {
+				return err
+			}
+
+			status.Calendar = items[:min(len(items), widget.CalendarLimit)]
+			return nil
+		}
+	}
+
+	if service.kind.hasLibrary && !widget.HideQueue {
+		parts["queue"] = func() error {
+			items, total, err := fetchArrQueue(service, widget.QueueLimit)
+			if err != nil {
+				return err
+			}
+
+			status.Queue, status.QueueTotal = items, total
+			return nil
+		}
+	}
+
+	if service.kind.hasLibrary && !widget.HideWanted {
+		parts["missing"] = func() error {
+			response, err := fetchArrAPI[arrPagedResponse](service, "/wanted/missing?page=1&pageSize=1&monitored=true")
+			if err != nil {
+				return err
+			}
+
+			status.Missing = response.TotalRecords
+			return nil
+		}
+
+		parts["cutoff unmet"] = func() error {
+			response, err := fetchArrAPI[arrPagedResponse](service, "/wanted/cutoff?page=1&pageSize=1&monitored=true")
+			if err != nil {
+				return err
+			}
+
+			status.CutoffUnmet = response.TotalRecords
+			return nil
+		}
+	}
+
+	if !widget.HideHealth {
+		parts["health"] = func() error {
+			records, err := fetchArrAPI[[]arrHealthRecord](service, "/health")
+			if err != nil {
+				return err
+			}
+
+			status.Health = arrHealthItemsFromRecords(records)
+			return nil
+		}
+	}
+
+	if !widget.HideIndexers {
+		parts["indexers"] = func() error {
+			total, failing, err := fetchArrIndexers(service)
+			if err != nil {
+				return err
+			}
+
+			status.Indexers, status.FailingIndexers = total, failing
+			return nil
+		}
+	}
+
+	err := fetchWidgetParts(parts)
+	if err != nil && !errors.Is(err, errPartialContent) {
+		return status, err
+	}
+
+	status.StateIcon = dockerContainerStateIconOK
+	if len(status.Health) > 0 || len(status.FailingIndexers) > 0 {
+		status.StateIcon = dockerContainerStateIconWarn
+	}
+
+	return status, err
+}
+
+func fetchArrAPI[T any](service *arrService, path string) (T, error) {
+	request, err := http.NewRequest("GET", service.URL+"/api/"+service.kind.apiVersion+path, nil)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("X-Api-Key", service.APIKey)
+
+	client := ternary(service.AllowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
+	return decodeJsonFromRequest[T](client, request)
+}
+
+func fetchArrCalendar(service *arrService, days int) ([]arrCalendarItem, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := now.AddDate(0, 0, days)
+
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	query.Set("unmonitored", "false")
+
+	records, err := fetchArrAPI[[]arrCalendarRecord](service, "/calendar?"+query.Encode()+"&"+service.kind.includeQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]arrCalendarItem, 0, len(records))
+	for i := range records {
+		if item, ok := arrCalendarItemFromRecord(&records[i], start, end); ok {
+			item.DateText = arrCalendarDateText(item.Date, now, !records[i].AirDateUTC.IsZero())
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(a, b int) bool {
+		return items[a].Date.Before(items[b].Date)
+	})
+
+	return items, nil
+}
+
+func arrCalendarItemFromRecord(record *arrCalendarRecord, start, end time.Time) (arrCalendarItem, bool) {
+	item := arrCalendarItem{Title: record.Title, HasFile: record.HasFile}
+
+	switch {
+	case record.Series != nil:
+		item.Title = record.Series.Title
+		item.Subtitle = mediaServerEpisodeSubtitle(record.SeasonNumber, record.EpisodeNumber, record.Title)
+		item.Date = record.AirDateUTC
+	case record.Artist != nil:
+		item.Subtitle = record.Artist.ArtistName
+		item.Date = record.ReleaseDate
+	case record.Author != nil:
+		item.Subtitle = record.Author.AuthorName
+		item.Date = record.ReleaseDate
+	default:
+		// Movies show up in the calendar for each of their releases, use the first one within it
+		releases := []struct {
+			label string
+			date  time.Time
+		}{
+			{"In cinemas", record.InCinemas},
+			{"Digital release", record.DigitalRelease},
+			{"Physical release", record.PhysicalRelease},
+		}
+
+		for _, release := range releases {
+			if release.date.Before(start) || release.date.After(end) {
+				continue
+			}
+
+			if item.Date.IsZero() || release.date.Before(item.Date) {
+				item.Date, item.Subtitle = release.date, release.label
+			}
+		}
+	}
+
+	return item, !item.Date.IsZero()
+}
+
+// Only episodes air at a specific time, everything else is released on a given day
+func arrCalendarDateText(date, now time.Time, withTime bool) string {
+	if !withTime {
+		// dates without a time are midnight UTC and would otherwise show up as the previous
+		// day in timezones behind it
+		date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, now.Location())
+	} else {
+		date = date.In(now.Location())
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var text string
+	switch {
+	case day.Equal(today):
+		text = "Today"
+	case day.Equal(today.AddDate(0, 0, 1)):
+		text = "Tomorrow"
+	default:
+		text = date.Format("Mon, Jan 2")
+	}
+
+	if withTime {
+		text += " " + date.Format("15:04")
+	}
+
+	return text
+}
+
+func fetchArrQueue(service *arrService, limit int) ([]arrQueueItem, int, error) {
+	response, err := fetchArrAPI[arrQueueResponse](service, fmt.Sprintf(
+		"/queue?page=1&pageSize=%d&%s", limit, service.kind.includeQuery,
+	))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]arrQueueItem, 0, len(response.Records))
+
+	for i := range response.Records {
+		record := &response.Records[i]
+		item := arrQueueItem{Title: record.Title}
+
+		switch {
+		case record.Series != nil:
+			item.Title = record.Series.Title
+			if record.Episode != nil {
+				item.Subtitle = mediaServerEpisodeSubtitle(record.Episode.SeasonNumber, record.Episode.EpisodeNumber, record.Episode.Title)
+			}
+		case record.Movie != nil:
+			item.Title = record.Movie.Title
+			if record.Movie.Year > 0 {
+				item.Subtitle = strconv.Itoa(record.Movie.Year)
+			}
+		case record.Artist != nil && record.Album != nil:
+			item.Title, item.Subtitle = record.Album.Title, record.Artist.ArtistName
+		case record.Author != nil && record.Book != nil:
+			item.Title, item.Subtitle = record.Book.Title, record.Author.AuthorName
+		}
+
+		if record.Size > 0 {
+			item.ProgressPercent = int(min(100, max(0, (record.Size-record.SizeLeft)*100/record.Size)))
+		}
+
+		item.Message = record.ErrorMessage
+		for _, status := range record.StatusMessages {
+			if item.Message == "" && len(status.Messages) > 0 {
+				item.Message = status.Messages[0]
+			}
+		}
+
+		item.IsWarning = record.TrackedDownloadStatus == "warning" ||
+			record.TrackedDownloadStatus == "error" ||
+			record.Status == "failed" ||
+			record.Status == "warning"
+
+		item.StatusText = arrQueueStatusText(record.Status, record.TrackedDownloadState, record.TimeLeft)
+		items = append(items, item)
+	}
+
+	return items, response.TotalRecords, nil
+}
+
+func arrQueueStatusText(status, trackedState, timeLeft string) string {
+	switch trackedState {
+	case "importPending":
+		return "Import pending"
+	case "importing":
+		return "Importing"
+	case "failedPending", "failed":
+		return "Failed"
+	}
+
+	switch status {
+	case "downloading":
+		if left := arrFormatTimeLeft(timeLeft); left != "" {
+			return left + " left"
+		}
+		return "Downloading"
+	case "paused":
+		return "Paused"
+	case "queued":
+		return "Queued"
+	case "delay":
+		return "Delayed"
+	case "completed":
+		return "Completed"
+	case "failed", "warning":
+		return "Failed"
+	case "downloadClientUnavailable":
+		return "Client unavailable"
+	}
+
+	return status
+}
+
+// The time left is formatted as [d.]hh:mm:ss
+func arrFormatTimeLeft(value string) string {
+	days := 0
+	if d, rest, found := strings.Cut(value, "."); found && !strings.Contains(rest, ".") {
+		parsed, err := strconv.Atoi(d)
+		if err != nil {
+			return ""
+		}
+
+		days, value = parsed, rest
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ""
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+
+	return "<1m"
+}
+
+func arrHealthItemsFromRecords(records []arrHealthRecord) []arrHealthItem {
+	items := make([]arrHealthItem, 0)
+
+	for i := range records {
+		if records[i].Type != "warning" && records[i].Type != "error" {
+			continue
+		}
+
+		items = append(items, arrHealthItem{
+			Source:  records[i].Source,
+			Message: records[i].Message,
+			WikiURL: records[i].WikiURL,
+			IsError: records[i].Type == "error",
+		})
+	}
+
+	sort.SliceStable(items, func(a, b int) bool {
+		return items[a].IsError && !items[b].IsError
+	})
+
+	return items
+}
+
+func fetchArrIndexers(service *arrService) (int, []string, error) {
+	indexers, err := fetchArrAPI[[]arrIndexerRecord](service, "/indexer")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	statuses, err := fetchArrAPI[[]arrIndexerStatusRecord](service, "/indexerstatus")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	disabled := make(map[int]bool, len(statuses))
+	for i := range statuses {
+		if statuses[i].DisabledTill.After(now) {
+			disabled[statuses[i].IndexerID] = true
+		}
+	}
+
+	total := 0
+	failing := make([]string, 0)
+
+	for i := range indexers {
+		if !indexers[i].Enable {
+			continue
+		}
+
+		total++
+		if disabled[indexers[i].ID] {
+			failing = append(failing, indexers[i].Name)
+		}
+	}
+
+	return total, failing, nil
+}
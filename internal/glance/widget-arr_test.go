@@ -0,0 +1,234 @@
+package glance
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArrCalendarItemFromRecord(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name         string
+		record       string
+		wantOK       bool
+		wantTitle    string
+		wantSubtitle string
+		wantDate     string
+	}{
+		{
+			name:         "episode",
+			record:       `{"title": "Pilot", "seasonNumber": 1, "episodeNumber": 2, "airDateUtc": "2025-03-02T20:00:00Z", "series": {"title": "The Show"}}`,
+			wantOK:       true,
+			wantTitle:    "The Show",
+			wantSubtitle: "S01E02 · Pilot",
+			wantDate:     "2025-03-02T20:00:00Z",
+		},
+		{
+			name:         "album",
+			record:       `{"title": "An Album", "releaseDate": "2025-03-03T00:00:00Z", "artist": {"artistName": "The Band"}}`,
+			wantOK:       true,
+			wantTitle:    "An Album",
+			wantSubtitle: "The Band",
+			wantDate:     "2025-03-03T00:00:00Z",
+		},
+		{
+			name:         "book",
+			record:       `{"title": "A Book", "releaseDate": "2025-03-04T00:00:00Z", "author": {"authorName": "An Author"}}`,
+			wantOK:       true,
+			wantTitle:    "A Book",
+			wantSubtitle: "An Author",
+			wantDate:     "2025-03-04T00:00:00Z",
+		},
+		{
+			name:         "movie with the first release within the calendar",
+			record:       `{"title": "A Movie", "inCinemas": "2025-01-10T00:00:00Z", "digitalRelease": "2025-03-05T00:00:00Z", "physicalRelease": "2025-03-06T00:00:00Z"}`,
+			wantOK:       true,
+			wantTitle:    "A Movie",
+			wantSubtitle: "Digital release",
+			wantDate:     "2025-03-05T00:00:00Z",
+		},
+		{
+			name:         "movie with releases in any order",
+			record:       `{"title": "A Movie", "inCinemas": "2025-03-06T00:00:00Z", "physicalRelease": "2025-03-02T00:00:00Z"}`,
+			wantOK:       true,
+			wantTitle:    "A Movie",
+			wantSubtitle: "Physical release",
+			wantDate:     "2025-03-02T00:00:00Z",
+		},
+		{
+			name:   "movie without a release within the calendar",
+			record: `{"title": "A Movie", "inCinemas": "2025-04-01T00:00:00Z"}`,
+		},
+		{
+			name:   "episode without an air date",
+			record: `{"title": "TBA", "series": {"title": "The Show"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record arrCalendarRecord
+			if err := json.Unmarshal([]byte(tt.record), &record); err != nil {
+				t.Fatal(err)
+			}
+
+			item, ok := arrCalendarItemFromRecord(&record, start, end)
+			if ok != tt.wantOK {
+				t.Fatalf("arrCalendarItemFromRecord() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if !ok {
+				return
+			}
+
+			if item.Title != tt.wantTitle || item.Subtitle != tt.wantSubtitle || item.Date.Format(time.RFC3339) != tt.wantDate {
+				t.Errorf("arrCalendarItemFromRecord() = %q, %q, %s, want %q, %q, %s", item.Title, item.Subtitle, item.Date.Format(time.RFC3339), tt.wantTitle, tt.wantSubtitle, tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestArrQueueStatusText(t *testing.T) {
+	tests := []struct {
+		status       string
+		trackedState string
+		timeLeft     string
+		want         string
+	}{
+		{"downloading", "downloading", "01:05:00", "1h 5m left"},
+		{"downloading", "downloading", "", "Downloading"},
+		{"completed", "importPending", "", "Import pending"},
+		{"completed", "importing", "", "Importing"},
+		{"completed", "failedPending", "", "Failed"},
+		{"completed", "imported", "", "Completed"},
+		{"paused", "", "00:10:00", "Paused"},
+		{"queued", "", "", "Queued"},
+		{"delay", "", "", "Delayed"},
+		{"warning", "", "", "Failed"},
+		{"downloadClientUnavailable", "", "", "Client unavailable"},
+		{"somethingNew", "", "", "somethingNew"},
+	}
+
+	for _, tt := range tests {
+		if got := arrQueueStatusText(tt.status, tt.trackedState, tt.timeLeft); got != tt.want {
+			t.Errorf("arrQueueStatusText(%q, %q, %q) = %q, want %q", tt.status, tt.trackedState, tt.timeLeft, got, tt.want)
+		}
+	}
+}
+
+func TestArrHealthItemsFromRecords(t *testing.T) {
+	items := arrHealthItemsFromRecords([]arrHealthRecord{
+		{Source: "IndexerStatusCheck", Type: "warning", Message: "Indexers unavailable"},
+		{Source: "UpdateCheck", Type: "notice", Message: "Update available"},
+		{Source: "DownloadClientCheck", Type: "error", Message: "Client unreachable"},
+		{Source: "RootFolderCheck", Type: "warning", Message: "Missing root folder"},
+	})
+
+	got := make([]string, len(items))
+	for i := range items {
+		got[i] = items[i].Source
+	}
+
+	// notices are skipped and errors come first, otherwise the order is kept
+	want := "DownloadClientCheck,IndexerStatusCheck,RootFolderCheck"
+	if strings.Join(got, ",") != want {
+		t.Errorf("arrHealthItemsFromRecords() = %v, want %s", got, want)
+	}
+
+	if !items[0].IsError || items[1].IsError {
+		t.Errorf("arrHealthItemsFromRecords() = %+v, want only the first to be an error", items)
+	}
+}
+
+func TestArrFormatTimeLeft(t *testing.T) {
+	tests := map[string]string{
+		"00:00:30":   "<1m",
+		"00:12:34":   "12m",
+		"02:03:04":   "2h 3m",
+		"1.02:03:04": "1d 2h",
+		"":           "",
+		"bogus":      "",
+	}
+
+	for input, expected := range tests {
+		if actual := arrFormatTimeLeft(input); actual != expected {
+			t.Errorf("arrFormatTimeLeft(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestArrWidgetAgainstFakeServers(t *testing.T) {
+	disabledTill := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	// serves both sonarr, which uses v3 of the API, and prowlarr, which uses v1
+	url := startFakeAPIServer(t, "X-Api-Key: key", map[string]string{
+		"GET /api/v3/calendar": `[{"title": "Pilot", "seasonNumber": 1, "episodeNumber": 2, "airDateUtc": "` +
+			time.Now().Add(2*time.Hour).UTC().Format(time.RFC3339) + `", "series": {"title": "The Show"}}]`,
+		"GET /api/v3/queue": `{"totalRecords": 12, "records": [
+			{"title": "The.Show.S01E01", "size": 1000, "sizeleft": 250, "status": "downloading",
+				"series": {"title": "The Show"}, "episode": {"title": "Intro", "seasonNumber": 1, "episodeNumber": 1}},
+			{"title": "Some.Release", "status": "completed", "trackedDownloadStatus": "warning",
+				"statusMessages": [{"messages": ["No files found are eligible for import"]}]}
+		]}`,
+		"GET /api/v3/wanted/missing": `{"totalRecords": 42}`,
+		"GET /api/v3/wanted/cutoff":  `{"totalRecords": 7}`,
+		"GET /api/v3/health":         `[]`,
+		"GET /api/v3/indexer":        `[{"id": 1, "name": "Good", "enable": true}, {"id": 2, "name": "Broken", "enable": true}, {"id": 3, "name": "Off", "enable": false}]`,
+		"GET /api/v3/indexerstatus":  `[{"indexerId": 2, "disabledTill": "` + disabledTill + `"}, {"indexerId": 3, "disabledTill": "` + disabledTill + `"}]`,
+		"GET /api/v1/health":         `[]`,
+		"GET /api/v1/indexer":        `[]`,
+	})
+
+	widget := &arrWidget{Services: []*arrService{
+		{Type: arrServiceSonarr, URL: url + "/", APIKey: "key"},
+		// the indexer status of prowlarr fails, but the rest of it is still shown
+		{Type: arrServiceProwlarr, URL: url, APIKey: "key"},
+		{Type: arrServiceRadarr, URL: "http://127.0.0.1:1", APIKey: "key"},
+	}}
+
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	statuses, err := widget.fetchStatuses()
+	if !errors.Is(err, errPartialContent) {
+		t.Fatalf("fetchStatuses() error = %v, want partial content", err)
+	}
+
+	if len(statuses) != 3 || statuses[0].Error != nil || statuses[1].Error != nil || statuses[2].Error == nil {
+		t.Fatalf("statuses = %+v, want only the unreachable service to have an error", statuses)
+	}
+
+	sonarr := statuses[0]
+	if sonarr.Missing != 42 || sonarr.CutoffUnmet != 7 || sonarr.QueueTotal != 12 || len(sonarr.Calendar) != 1 {
+		t.Errorf("sonarr = %+v, want the counts and calendar from the API", sonarr)
+	}
+
+	downloading, importing := sonarr.Queue[0], sonarr.Queue[1]
+	if downloading.Title != "The Show" || downloading.Subtitle != "S01E01 · Intro" || downloading.ProgressPercent != 75 || downloading.IsWarning {
+		t.Errorf("queue item = %+v, want the episode with its progress", downloading)
+	}
+
+	if importing.Title != "Some.Release" || !importing.IsWarning || !strings.Contains(importing.Message, "eligible") {
+		t.Errorf("queue item = %+v, want a warning with its message", importing)
+	}
+
+	if sonarr.Indexers != 2 || strings.Join(sonarr.FailingIndexers, ",") != "Broken" || sonarr.StateIcon != dockerContainerStateIconWarn {
+		t.Errorf("indexers = %d %v, want disabled indexers skipped and the failing one shown", sonarr.Indexers, sonarr.FailingIndexers)
+	}
+
+	if prowlarr := statuses[1]; prowlarr.HasLibrary || prowlarr.Health == nil {
+		t.Errorf("prowlarr = %+v, want its health without a library", prowlarr)
+	}
+
+	unauthorized := *widget.Services[0]
+	unauthorized.APIKey = "wrong"
+	if _, err := fetchArrAPI[arrPagedResponse](&unauthorized, "/wanted/missing"); err == nil {
+		t.Error("fetchArrAPI() error = nil, want an error with an invalid API key")
+	}
+}
@@ -0,0 +1,331 @@
+package glance
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var certificatesWidgetTemplate = mustParseTemplate("certificates.html", "widget-base.html")
+
+type certificatesWidget struct {
+	widgetBase   `yaml:",inline"`
+	Certificates []*certificateRequest `yaml:"certificates"`
+	WarningDays  int                   `yaml:"warning-days"`
+	CriticalDays int                   `yaml:"critical-days"`
+}
+
+type certificateRequest struct {
+	Title         string        `yaml:"title"`
+	Host          string        `yaml:"host"`
+	Port          uint16        `yaml:"port"`
+	ServerName    string        `yaml:"server-name"`
+	StartTLS      string        `yaml:"starttls"`
+	AllowInsecure bool          `yaml:"allow-insecure"`
+	Timeout       durationField `yaml:"timeout"`
+	URL           string        `yaml:"url"`
+	SameTab       bool          `yaml:"same-tab"`
+
+	Result *certificateStatus `yaml:"-"`
+}
+
+type certificateStatus struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	DaysLeft int
+	Names    []string
+	Chain    []certificateChainItem
+	Error    error
+	// Problems that don't prevent the certificate from being read, such as a name mismatch
+	Problems  []string
+	State     string
+	StateText string
+}
+
+type certificateChainItem struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+}
+
+func (widget *certificatesWidget) initialize() error {
+	widget.withTitle("Certificates").withCacheDuration(1 * time.Hour)
+
+	if widget.WarningDays <= 0 {
+		widget.WarningDays = 30
+	}
+
+	if widget.CriticalDays <= 0 {
+		widget.CriticalDays = 7
+	}
+
+	if widget.CriticalDays > widget.WarningDays {
+		return errors.New("critical-days must not be greater than warning-days")
+	}
+
+	for _, request := range widget.Certificates {
+		if request.Host == "" {
+			return errors.New("host is required for each certificate")
+		}
+
+		request.StartTLS = strings.ToLower(request.StartTLS)
+		if request.StartTLS != "" && request.StartTLS != "smtp" && request.StartTLS != "imap" {
+			return fmt.Errorf("unsupported starttls protocol %s, must be either smtp or imap", request.StartTLS)
+		}
+
+		if request.Port == 0 {
+			request.Port = 443
+		}
+
+		if request.ServerName == "" {
+			request.ServerName = request.Host
+		}
+
+		if request.Title == "" {
+			request.Title = request.Host
+			if request.Port != 443 {
+				request.Title += ":" + strconv.Itoa(int(request.Port))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (widget *certificatesWidget) update(ctx context.Context) {
+	job := newJob(fetchCertificateStatusTask, widget.Certificates).withWorkers(20)
+	results, _, err := workerPoolDo(job)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	now := time.Now()
+
+	for i := range widget.Certificates {
+		result := &results[i]
+		widget.Certificates[i].Result = result
+
+		if result.Error != nil {
+			result.State, result.StateText = "critical", "Error"
+			continue
+		}
+
+		result.DaysLeft = int(result.NotAfter.Sub(now).Hours() / 24)
+
+		switch {
+		case !now.Before(result.NotAfter):
+			result.State = "critical"
+			result.StateText = ternary(result.DaysLeft == 0, "Expired today", "Expired "+formatCertificateDays(-result.DaysLeft)+" ago")
+		case result.DaysLeft <= widget.CriticalDays || len(result.Problems) > 0:
+			result.State = "critical"
+		case result.DaysLeft <= widget.WarningDays:
+			result.State = "warning"
+		default:
+			result.State = "ok"
+		}
+
+		if result.StateText == "" {
+			result.StateText = "Expires in " + formatCertificateDays(result.DaysLeft)
+		}
+	}
+}
+
+func (widget *certificatesWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, certificatesWidgetTemplate)
+}
+
+func formatCertificateDays(days int) string {
+	return strconv.Itoa(days) + ternary(days == 1, " day", " days")
+}
+
+func certificateNameMismatch(serverName string, certificate *x509.Certificate) string {
+	names := strings.Join(certificate.DNSNames, ", ")
+	if names == "" {
+		// without subject alternative names the common name isn't used for
+		// verification anymore, but it's still the best hint about what the certificate is for
+		names = certificate.Subject.CommonName
+	}
+
+	if names == "" {
+		return "Name mismatch: " + serverName + ", certificate has no names"
+	}
+
+	return "Name mismatch: " + serverName + " not in " + names
+}
+
+func fetchCertificateStatusTask(request *certificateRequest) (certificateStatus, error) {
+	timeout := ternary(request.Timeout > 0, time.Duration(request.Timeout), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	certificates, err := fetchPeerCertificates(ctx, request)
+	if err != nil {
+		return certificateStatus{Error: err}, nil
+	}
+
+	if len(certificates) == 0 {
+		return certificateStatus{Error: errors.New("server did not send a certificate")}, nil
+	}
+
+	leaf := certificates[0]
+	status := certificateStatus{
+		Subject:  certificateName(leaf.Subject.CommonName, leaf.Subject.Organization),
+		Issuer:   certificateName(leaf.Issuer.CommonName, leaf.Issuer.Organization),
+		NotAfter: leaf.NotAfter,
+		Names:    leaf.DNSNames,
+	}
+
+	for _, certificate := range certificates {
+		status.Chain = append(status.Chain, certificateChainItem{
+			Subject:  certificateName(certificate.Subject.CommonName, certificate.Subject.Organization),
+			Issuer:   certificateName(certificate.Issuer.CommonName, certificate.Issuer.Organization),
+			NotAfter: certificate.NotAfter,
+		})
+	}
+
+	if err := leaf.VerifyHostname(request.ServerName); err != nil {
+		status.Problems = append(status.Problems, certificateNameMismatch(request.ServerName, leaf))
+	}
+
+	if !request.AllowInsecure {
+		intermediates := x509.NewCertPool()
+		for _, certificate := range certificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+
+		// the expiry date is already reported separately, so verify the chain as it was just before it
+		verifyAt := time.Now()
+		if verifyAt.After(leaf.NotAfter) {
+			verifyAt = leaf.NotAfter.Add(-time.Second)
+		}
+
+		_, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: verifyAt})
+		if err != nil {
+			var unknownAuthorityErr x509.UnknownAuthorityError
+			if errors.As(err, &unknownAuthorityErr) {
+				status.Problems = append(status.Problems, "Untrusted issuer")
+			} else {
+				status.Problems = append(status.Problems, err.Error())
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// Verification is done separately rather than during the handshake so that
+// details about the certificate can still be shown when it's invalid
+func fetchPeerCertificates(ctx context.Context, request *certificateRequest) ([]*x509.Certificate, error) {
+	dialer := net.Dialer{}
+	address := net.JoinHostPort(request.Host, strconv.Itoa(int(request.Port)))
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	switch request.StartTLS {
+	case "smtp":
+		err = startSMTPTLS(conn)
+	case "imap":
+		err = startIMAPTLS(conn)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("starttls: %w", err)
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         request.ServerName,
+		InsecureSkipVerify: true,
+	})
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return tlsConn.ConnectionState().PeerCertificates, nil
+}
+
+func startSMTPTLS(conn net.Conn) error {
+	text := textproto.NewConn(conn)
+
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if _, err := text.Cmd("EHLO glance"); err != nil {
+		return err
+	}
+
+	if _, _, err := text.ReadResponse(250); err != nil {
+		return err
+	}
+
+	if _, err := text.Cmd("STARTTLS"); err != nil {
+		return err
+	}
+
+	_, _, err := text.ReadResponse(220)
+	return err
+}
+
+func startIMAPTLS(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(greeting))
+	}
+
+	if _, err := conn.Write([]byte("a1 STARTTLS\r\n")); err != nil {
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(line, "a1 ") {
+			if !strings.HasPrefix(line, "a1 OK") {
+				return fmt.Errorf("server refused: %s", strings.TrimSpace(line))
+			}
+
+			return nil
+		}
+	}
+}
+
+func certificateName(commonName string, organization []string) string {
+	if commonName != "" {
+		return commonName
+	}
+
+	if len(organization) > 0 {
+		return organization[0]
+	}
+
+	return "Unknown"
+}
@@ -0,0 +1,228 @@
+package glance
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, names []string, notAfter time.Time) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Serves the certificate over TLS, optionally after negotiating STARTTLS the
+// way a mail server would. An imap server with refuse set rejects STARTTLS.
+func startTestCertificateServer(t *testing.T, certificate tls.Certificate, starttls string, refuse bool) (string, uint16) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				reader := bufio.NewReader(conn)
+
+				switch starttls {
+				case "smtp":
+					conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+					if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "EHLO ") {
+						return
+					}
+					conn.Write([]byte("250-mail.example.com\r\n250 STARTTLS\r\n"))
+					if line, _ := reader.ReadString('\n'); line != "STARTTLS\r\n" {
+						return
+					}
+					conn.Write([]byte("220 Ready to start TLS\r\n"))
+				case "imap":
+					conn.Write([]byte("* OK IMAP4rev1 ready\r\n"))
+					if line, _ := reader.ReadString('\n'); line != "a1 STARTTLS\r\n" {
+						return
+					}
+					if refuse {
+						conn.Write([]byte("a1 NO STARTTLS unavailable\r\n"))
+						return
+					}
+					conn.Write([]byte("* CAPABILITY IMAP4rev1\r\na1 OK Begin TLS negotiation now\r\n"))
+				}
+
+				tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return "127.0.0.1", uint16(portNumber)
+}
+
+func TestFetchCertificateStatus(t *testing.T) {
+	certificate := newTestCertificate(t, []string{"mail.example.com"}, time.Now().Add(30*24*time.Hour))
+
+	tests := []struct {
+		name         string
+		starttls     string
+		refuse       bool
+		serverName   string
+		wantErr      bool
+		wantProblems []string
+	}{
+		{name: "plain tls", serverName: "mail.example.com"},
+		{name: "smtp starttls", starttls: "smtp", serverName: "mail.example.com"},
+		{name: "imap starttls", starttls: "imap", serverName: "mail.example.com"},
+		{name: "imap starttls refused", starttls: "imap", refuse: true, serverName: "mail.example.com", wantErr: true},
+		{name: "hostname mismatch", serverName: "other.example.com", wantProblems: []string{"Name mismatch: other.example.com not in mail.example.com"}},
+		{name: "hostname mismatch after starttls", starttls: "smtp", serverName: "other.example.com", wantProblems: []string{"Name mismatch: other.example.com not in mail.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := startTestCertificateServer(t, certificate, tt.starttls, tt.refuse)
+			request := &certificateRequest{
+				Host:          host,
+				Port:          port,
+				ServerName:    tt.serverName,
+				StartTLS:      tt.starttls,
+				AllowInsecure: true,
+			}
+
+			status, err := fetchCertificateStatusTask(request)
+			if err != nil {
+				t.Fatalf("fetchCertificateStatusTask() error = %v", err)
+			}
+
+			if (status.Error != nil) != tt.wantErr {
+				t.Fatalf("status error = %v, wantErr %v", status.Error, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if status.Subject != "mail.example.com" {
+				t.Errorf("subject = %s, want mail.example.com", status.Subject)
+			}
+
+			if strings.Join(status.Problems, "; ") != strings.Join(tt.wantProblems, "; ") {
+				t.Errorf("problems = %v, want %v", status.Problems, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func TestCertificateNameMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		dnsNames    []string
+		commonName  string
+		serverName  string
+		wantProblem string
+	}{
+		{"dns names", []string{"a.example.com", "b.example.com"}, "a.example.com", "c.example.com", "Name mismatch: c.example.com not in a.example.com, b.example.com"},
+		{"common name only", nil, "legacy.example.com", "example.com", "Name mismatch: example.com not in legacy.example.com"},
+		{"no names", nil, "", "example.com", "Name mismatch: example.com, certificate has no names"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certificate := &x509.Certificate{DNSNames: tt.dnsNames, Subject: pkix.Name{CommonName: tt.commonName}}
+
+			if got := certificateNameMismatch(tt.serverName, certificate); got != tt.wantProblem {
+				t.Errorf("certificateNameMismatch() = %q, want %q", got, tt.wantProblem)
+			}
+		})
+	}
+}
+
+func TestFetchCertificateStatusUntrustedIssuer(t *testing.T) {
+	certificate := newTestCertificate(t, []string{"example.com"}, time.Now().Add(30*24*time.Hour))
+	host, port := startTestCertificateServer(t, certificate, "", false)
+
+	status, _ := fetchCertificateStatusTask(&certificateRequest{Host: host, Port: port, ServerName: "example.com"})
+	if status.Error != nil || len(status.Problems) != 1 || status.Problems[0] != "Untrusted issuer" {
+		t.Errorf("status = %+v, want only an untrusted issuer problem", status)
+	}
+}
+
+func TestCertificatesWidgetStateText(t *testing.T) {
+	tests := []struct {
+		name          string
+		notAfter      time.Duration
+		wantState     string
+		wantStateText string
+	}{
+		{"expired within the last day", -time.Hour, "critical", "Expired today"},
+		{"expired days ago", -(2*24 + 1) * time.Hour, "critical", "Expired 2 days ago"},
+		{"expires within critical days", (3*24 + 1) * time.Hour, "critical", "Expires in 3 days"},
+		{"expires within warning days", (10*24 + 1) * time.Hour, "warning", "Expires in 10 days"},
+		{"expires later", (90*24 + 1) * time.Hour, "ok", "Expires in 90 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certificate := newTestCertificate(t, []string{"example.com"}, time.Now().Add(tt.notAfter))
+			host, port := startTestCertificateServer(t, certificate, "", false)
+
+			widget := &certificatesWidget{Certificates: []*certificateRequest{
+				{Host: host, Port: port, ServerName: "example.com", AllowInsecure: true},
+			}}
+
+			if err := widget.initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			widget.update(context.Background())
+
+			result := widget.Certificates[0].Result
+			if result.State != tt.wantState || result.StateText != tt.wantStateText {
+				t.Errorf("state = %s %q, want %s %q", result.State, result.StateText, tt.wantState, tt.wantStateText)
+			}
+		})
+	}
+}
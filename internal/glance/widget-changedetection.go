@@ -2,11 +2,13 @@ package glance
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,9 +20,14 @@ type changeDetectionWidget struct {
 	ChangeDetections changeDetectionWatchList `yaml:"-"`
 	WatchUUIDs       []string                 `yaml:"watches"`
 	InstanceURL      string                   `yaml:"instance-url"`
-	Token            string                   `yaml:"token"`
+	Token            string                   `yaml:"token" secret:"true"`
 	Limit            int                      `yaml:"limit"`
 	CollapseAfter    int                      `yaml:"collapse-after"`
+	Notify           *notificationRule        `yaml:"notify"`
+
+	// The watches from the config, when empty the ones that get fetched are stored in WatchUUIDs
+	configuredWatchUUIDs []string
+	notificationTimer    notificationCheckTimer
 }
 
 func (widget *changeDetectionWidget) initialize() error {
@@ -38,7 +45,9 @@ func (widget *changeDetectionWidget) initialize() error {
 		widget.InstanceURL = "https://www.changedetection.io"
 	}
 
-	return nil
+	widget.configuredWatchUUIDs = widget.WatchUUIDs
+
+	return widget.Notify.initialize(1, "change")
 }
 
 func (widget *changeDetectionWidget) update(ctx context.Context) {
@@ -58,6 +67,8 @@ func (widget *changeDetectionWidget) update(ctx context.Context) {
 		return
 	}
 
+	widget.notifyOnChanges(watches)
+
 	if len(watches) > widget.Limit {
 		watches = watches[:widget.Limit]
 	}
@@ -65,6 +76,66 @@ func (widget *changeDetectionWidget) update(ctx context.Context) {
 	widget.ChangeDetections = watches
 }
 
+func (widget *changeDetectionWidget) notificationRule() *notificationRule {
+	return widget.Notify
+}
+
+func (widget *changeDetectionWidget) runInBackground(ctx context.Context, app *application) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	runNotificationChecks(ctx, app, widget.cacheDuration, &widget.notificationTimer, func() {
+		uuids := widget.configuredWatchUUIDs
+		if len(uuids) == 0 {
+			var err error
+			uuids, err = fetchWatchUUIDsFromChangeDetection(widget.InstanceURL, widget.Token)
+			if err != nil {
+				slog.Error("Failed to check change detection watches for notifications", "error", err)
+				return
+			}
+		}
+
+		watches, err := fetchWatchesFromChangeDetection(widget.InstanceURL, uuids, widget.Token)
+		if err != nil && !errors.Is(err, errPartialContent) {
+			slog.Error("Failed to check change detection watches for notifications", "error", err)
+			return
+		}
+
+		widget.notifyOnChanges(watches)
+	})
+}
+
+func (widget *changeDetectionWidget) notifyOnChanges(watches changeDetectionWatchList) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	widget.notificationTimer.markChecked()
+
+	for i := range watches {
+		watch := &watches[i]
+		if watch.LastChanged.IsZero() {
+			continue
+		}
+
+		key := "change-detection:" + watch.URL
+		_, changed := notificationStates.observe(key, strconv.FormatInt(watch.LastChanged.Unix(), 10), 1)
+		if !changed {
+			continue
+		}
+
+		widget.Notify.send(notification{
+			key:     key,
+			Event:   "change",
+			Widget:  widget.Title,
+			Title:   watch.Title + " has changed",
+			Message: fmt.Sprintf("A change was detected on %s", watch.URL),
+			URL:     watch.DiffURL,
+		})
+	}
+}
+
 func (widget *changeDetectionWidget) Render() template.HTML {
 	return widget.renderTemplate(widget, changeDetectionWidgetTemplate)
 }
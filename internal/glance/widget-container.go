@@ -41,6 +41,10 @@ func (widget *containerWidgetBase) _update(ctx context.Context) {
 	wg.Wait()
 }
 
+func (widget *containerWidgetBase) nestedWidgets() widgets {
+	return widget.Widgets
+}
+
 func (widget *containerWidgetBase) _setProviders(providers *widgetProviders) {
 	for i := range widget.Widgets {
 		widget.Widgets[i].setProviders(providers)
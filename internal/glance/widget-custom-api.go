@@ -27,7 +27,7 @@ var customAPIWidgetTemplate = mustParseTemplate("custom-api.html", "widget-base.
 type CustomAPIRequest struct {
 	URL                string               `yaml:"url"`
 	AllowInsecure      bool                 `yaml:"allow-insecure"`
-	Headers            map[string]string    `yaml:"headers"`
+	Headers            map[string]string    `yaml:"headers" secret:"true"`
 	Parameters         queryParametersField `yaml:"parameters"`
 	Method             string               `yaml:"method"`
 	BodyType           string               `yaml:"body-type"`
@@ -37,9 +37,9 @@ type dnsStatsWidget struct {
 	Service        string `yaml:"service"`
 	AllowInsecure  bool   `yaml:"allow-insecure"`
 	URL            string `yaml:"url"`
-	Token          string `yaml:"token"`
+	Token          string `yaml:"token" secret:"true"`
 	Username       string `yaml:"username"`
-	Password       string `yaml:"password"`
+	Password       string `yaml:"password" secret:"true"`
 }
 
 const (
@@ -0,0 +1,126 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dockerContainerLogsDefaultLines = 100
+	dockerContainerLogsMaxLines     = 1000
+	// lines can be arbitrarily long, this keeps a container that logs huge
+	// blobs from making the response take up an unreasonable amount of memory
+	dockerContainerLogsMaxBytes = 2 << 20
+)
+
+type dockerContainerLogLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+func fetchDockerContainerLogs(source *dockerSource, containerID string, lines int) ([]dockerContainerLogLine, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("stdout", "true")
+	query.Set("stderr", "true")
+	query.Set("tail", strconv.Itoa(lines))
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		source.baseURL+"/containers/"+url.PathEscape(containerID)+"/logs?"+query.Encode(),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := source.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("sending request to socket: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, dockerContainerLogsMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	// containers started with a TTY aren't multiplexed, which is indicated by the raw-stream content type
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType == "application/vnd.docker.multiplexed-stream" {
+		return demuxDockerLogStream(body), nil
+	}
+
+	return splitDockerLogLines(body, "stdout", make([]dockerContainerLogLine, 0)), nil
+}
+
+// Unless the container was started with a TTY, stdout and stderr are multiplexed into a single stream
+// where each frame is prefixed by an 8 byte header containing the stream type, 3 bytes of padding and
+// the big endian size of the frame. Frames don't necessarily end on a new line, so the partial lines
+// of each stream are kept until they're completed by a later frame.
+func demuxDockerLogStream(data []byte) []dockerContainerLogLine {
+	lines := make([]dockerContainerLogLine, 0)
+	pending := make(map[string][]byte, 2)
+
+	for len(data) >= 8 {
+		stream := ternary(data[0] == 2, "stderr", "stdout")
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		data = data[8:]
+
+		// the response got cut off by the size limit
+		if size > len(data) {
+			size = len(data)
+		}
+
+		frame := append(pending[stream], data[:size]...)
+		data = data[size:]
+
+		last := bytes.LastIndexByte(frame, '\n')
+		if last == -1 {
+			pending[stream] = frame
+			continue
+		}
+
+		lines = splitDockerLogLines(frame[:last+1], stream, lines)
+		pending[stream] = frame[last+1:]
+	}
+
+	for _, stream := range []string{"stdout", "stderr"} {
+		if len(pending[stream]) > 0 {
+			lines = splitDockerLogLines(pending[stream], stream, lines)
+		}
+	}
+
+	return lines
+}
+
+func splitDockerLogLines(data []byte, stream string, lines []dockerContainerLogLine) []dockerContainerLogLine {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return lines
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, dockerContainerLogLine{
+			Stream: stream,
+			Text:   strings.TrimSuffix(line, "\r"),
+		})
+	}
+
+	return lines
+}
@@ -0,0 +1,106 @@
+package glance
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func dockerLogFrame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func TestDemuxDockerLogStream(t *testing.T) {
+	frame := dockerLogFrame
+
+	var data []byte
+	data = append(data, frame(1, "first\nsecond ")...)
+	data = append(data, frame(2, "error\r\n")...)
+	data = append(data, frame(1, "half\n")...)
+	data = append(data, frame(2, "no new line")...)
+
+	expected := []dockerContainerLogLine{
+		{Stream: "stdout", Text: "first"},
+		{Stream: "stderr", Text: "error"},
+		{Stream: "stdout", Text: "second half"},
+		{Stream: "stderr", Text: "no new line"},
+	}
+
+	if lines := demuxDockerLogStream(data); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+
+	// cut off in the middle of the last frame by the size limit
+	truncated := data[:len(data)-4]
+	expected[3].Text = "no new "
+	if lines := demuxDockerLogStream(truncated); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestFetchDockerContainerLogsContentType(t *testing.T) {
+	multiplexed := append(dockerLogFrame(1, "out\n"), dockerLogFrame(2, "err\n")...)
+	// output of a container with a TTY which happens to start with bytes that look like a frame header
+	frameLike := "\x01\x00\x00\x00tty output\n"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []dockerContainerLogLine
+	}{
+		{
+			name:        "multiplexed stream",
+			contentType: "application/vnd.docker.multiplexed-stream",
+			body:        string(multiplexed),
+			want:        []dockerContainerLogLine{{"stdout", "out"}, {"stderr", "err"}},
+		},
+		{
+			name:        "raw stream",
+			contentType: "application/vnd.docker.raw-stream",
+			body:        "first\nsecond\n",
+			want:        []dockerContainerLogLine{{"stdout", "first"}, {"stdout", "second"}},
+		},
+		{
+			name:        "raw stream that looks like a frame",
+			contentType: "application/vnd.docker.raw-stream",
+			body:        frameLike,
+			want:        []dockerContainerLogLine{{"stdout", frameLike[:len(frameLike)-1]}},
+		},
+		{
+			name:        "no content type",
+			contentType: "",
+			body:        frameLike,
+			want:        []dockerContainerLogLine{{"stdout", frameLike[:len(frameLike)-1]}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{tt.contentType}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			source := &dockerSource{SockPath: server.URL}
+			if err := source.initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			lines, err := fetchDockerContainerLogs(source, "web", 100)
+			if err != nil {
+				t.Fatalf("fetchDockerContainerLogs() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("fetchDockerContainerLogs() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
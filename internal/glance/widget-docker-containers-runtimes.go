@@ -0,0 +1,284 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultContainerdSockPath = "/run/containerd/containerd.sock"
+
+// Used when no sock path is specified, in order of preference
+func dockerSockPathCandidates() []string {
+	candidates := []string{"/var/run/docker.sock"}
+
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+
+	return append(candidates, "/run/podman/podman.sock")
+}
+
+func detectDockerSockPath() string {
+	candidates := dockerSockPathCandidates()
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return candidates[0]
+}
+
+type podmanContainerJsonResponse struct {
+	ID      string                `json:"Id"`
+	Names   []string              `json:"Names"`
+	Image   string                `json:"Image"`
+	ImageID string                `json:"ImageID"`
+	State   string                `json:"State"`
+	Status  string                `json:"Status"`
+	Labels  dockerContainerLabels `json:"Labels"`
+	Pod     string                `json:"Pod"`
+	PodName string                `json:"PodName"`
+	IsInfra bool                  `json:"IsInfra"`
+}
+
+// Podman's Docker compatible API doesn't include which pod a container belongs to, so its own API
+// is used for listing containers, while everything else goes through the compatible API
+func fetchPodmanContainers(source *dockerSource, runningOnly bool) ([]dockerContainerJsonResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fetchAll := ternary(runningOnly, "false", "true")
+	list, err := fetchDockerAPI[[]podmanContainerJsonResponse](ctx, source, "/v4.0.0/libpod/containers/json?all="+fetchAll)
+	if err != nil {
+		return nil, err
+	}
+
+	return podmanContainersToDocker(list), nil
+}
+
+// Pods are shown as a parent with their containers as its children. The infra container is used to
+// represent the pod since its state is the pod's state, or the first container for pods without one.
+// Labels specified on the containers take precedence.
+func podmanContainersToDocker(list []podmanContainerJsonResponse) []dockerContainerJsonResponse {
+	parentByPod := make(map[string]int)
+
+	for i := range list {
+		if list[i].Pod != "" && list[i].IsInfra {
+			parentByPod[list[i].Pod] = i
+		}
+	}
+
+	for i := range list {
+		if _, exists := parentByPod[list[i].Pod]; list[i].Pod != "" && !exists {
+			parentByPod[list[i].Pod] = i
+		}
+	}
+
+	containers := make([]dockerContainerJsonResponse, len(list))
+
+	for i := range list {
+		c := &list[i]
+		container := dockerContainerJsonResponse{
+			ID:      c.ID,
+			Names:   make([]string, len(c.Names)),
+			Image:   c.Image,
+			ImageID: c.ImageID,
+			State:   c.State,
+			Status:  ternary(c.Status != "", c.Status, c.State),
+			Labels:  c.Labels,
+		}
+
+		for n := range c.Names {
+			container.Names[n] = "/" + strings.TrimLeft(c.Names[n], "/")
+		}
+
+		if c.Pod != "" {
+			if container.Labels == nil {
+				container.Labels = make(dockerContainerLabels)
+			}
+
+			podID := "pod:" + c.Pod
+
+			if parentByPod[c.Pod] == i {
+				if c.IsInfra && c.PodName != "" {
+					container.Names = []string{"/" + c.PodName}
+				}
+
+				setLabelIfMissing(container.Labels, dockerContainerLabelID, podID)
+			} else {
+				setLabelIfMissing(container.Labels, dockerContainerLabelParent, podID)
+			}
+		}
+
+		containers[i] = container
+	}
+
+	return containers
+}
+
+func setLabelIfMissing(labels dockerContainerLabels, label, value string) {
+	if labels[label] == "" {
+		labels[label] = value
+	}
+}
+
+type nerdctlContainerInspectResponse struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	Image string `json:"Image"`
+	State struct {
+		Status     string `json:"Status"`
+		ExitCode   int    `json:"ExitCode"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+	} `json:"State"`
+	Config struct {
+		Labels dockerContainerLabels `json:"Labels"`
+	} `json:"Config"`
+}
+
+// containerd only has a gRPC API, so nerdctl is used to list its containers since it
+// already knows how to get them into the same format as Docker. This requires the
+// nerdctl binary to be available on the PATH, which is checked when the source is
+// initialized, and to have access to the socket.
+func fetchNerdctlContainers(source *dockerSource, runningOnly bool) ([]dockerContainerJsonResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listArgs := []string{"ps", "--quiet", "--no-trunc"}
+	if !runningOnly {
+		listArgs = append(listArgs, "--all")
+	}
+
+	output, err := runNerdctl(ctx, source, listArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return []dockerContainerJsonResponse{}, nil
+	}
+
+	output, err = runNerdctl(ctx, source, append([]string{"container", "inspect", "--mode", "dockercompat"}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var inspected []nerdctlContainerInspectResponse
+	if err := json.Unmarshal(output, &inspected); err != nil {
+		return nil, fmt.Errorf("decoding nerdctl output: %w", err)
+	}
+
+	containers := make([]dockerContainerJsonResponse, len(inspected))
+	now := time.Now()
+
+	for i := range inspected {
+		c := &inspected[i]
+		containers[i] = dockerContainerJsonResponse{
+			ID:     c.ID,
+			Names:  []string{"/" + strings.TrimLeft(c.Name, "/")},
+			Image:  c.Image,
+			State:  c.State.Status,
+			Status: nerdctlContainerStatus(c, now),
+			Labels: c.Config.Labels,
+		}
+	}
+
+	return containers, nil
+}
+
+// Builds the same status text that Docker shows, such as "Up 3 hours" or "Exited (0) 2 days ago",
+// since nerdctl only provides the state along with the times it was started and finished at
+func nerdctlContainerStatus(c *nerdctlContainerInspectResponse, now time.Time) string {
+	startedAt, _ := time.Parse(time.RFC3339Nano, c.State.StartedAt)
+	finishedAt, _ := time.Parse(time.RFC3339Nano, c.State.FinishedAt)
+
+	var status string
+
+	switch {
+	case c.State.Status == "running" || c.State.Status == "paused":
+		status = "Up " + formatDockerStatusDuration(now.Sub(startedAt))
+		if c.State.Status == "paused" {
+			status += " (Paused)"
+		}
+	case c.State.Status == "restarting":
+		status = "Restarting (" + strconv.Itoa(c.State.ExitCode) + ") " + formatDockerStatusDuration(now.Sub(finishedAt)) + " ago"
+	case c.State.Status == "dead":
+		status = "Dead"
+	case startedAt.IsZero():
+		status = "Created"
+	case finishedAt.IsZero():
+		status = c.State.Status
+	default:
+		status = "Exited (" + strconv.Itoa(c.State.ExitCode) + ") " + formatDockerStatusDuration(now.Sub(finishedAt)) + " ago"
+	}
+
+	if c.State.Error != "" {
+		status += ", " + c.State.Error
+	}
+
+	return status
+}
+
+// Same as the durations in the statuses of Docker's API
+func formatDockerStatusDuration(d time.Duration) string {
+	seconds := int(d.Seconds())
+	minutes := int(d.Minutes())
+	hours := int(d.Hours() + 0.5)
+
+	switch {
+	case seconds < 1:
+		return "Less than a second"
+	case seconds == 1:
+		return "1 second"
+	case seconds < 60:
+		return strconv.Itoa(seconds) + " seconds"
+	case minutes == 1:
+		return "About a minute"
+	case minutes < 60:
+		return strconv.Itoa(minutes) + " minutes"
+	case hours == 1:
+		return "About an hour"
+	case hours < 48:
+		return strconv.Itoa(hours) + " hours"
+	case hours < 24*7*2:
+		return strconv.Itoa(hours/24) + " days"
+	case hours < 24*30*2:
+		return strconv.Itoa(hours/24/7) + " weeks"
+	case hours < 24*365*2:
+		return strconv.Itoa(hours/24/30) + " months"
+	}
+
+	return strconv.Itoa(int(d.Hours()/24/365)) + " years"
+}
+
+func runNerdctl(ctx context.Context, source *dockerSource, args ...string) ([]byte, error) {
+	args = append([]string{"--address", source.SockPath, "--namespace", source.Namespace}, args...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "nerdctl", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("running nerdctl: %w: %s", err, message)
+		}
+
+		return nil, fmt.Errorf("running nerdctl: %w", err)
+	}
+
+	return output, nil
+}
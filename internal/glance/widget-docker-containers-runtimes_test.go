@@ -0,0 +1,121 @@
+package glance
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDockerStatusDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{500 * time.Millisecond, "Less than a second"},
+		{time.Second, "1 second"},
+		{45 * time.Second, "45 seconds"},
+		{90 * time.Second, "About a minute"},
+		{12 * time.Minute, "12 minutes"},
+		{70 * time.Minute, "About an hour"},
+		{3 * time.Hour, "3 hours"},
+		{47 * time.Hour, "47 hours"},
+		{5 * 24 * time.Hour, "5 days"},
+		{3 * 7 * 24 * time.Hour, "3 weeks"},
+		{90 * 24 * time.Hour, "3 months"},
+		{3 * 365 * 24 * time.Hour, "3 years"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDockerStatusDuration(tt.duration); got != tt.want {
+			t.Errorf("formatDockerStatusDuration(%v) = %q, want %q", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestNerdctlContainerStatus(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339Nano) }
+	never := "0001-01-01T00:00:00Z"
+
+	tests := []struct {
+		name       string
+		status     string
+		startedAt  string
+		finishedAt string
+		exitCode   int
+		err        string
+		want       string
+	}{
+		{"running", "running", ago(3 * time.Hour), never, 0, "", "Up 3 hours"},
+		{"paused", "paused", ago(10 * time.Minute), never, 0, "", "Up 10 minutes (Paused)"},
+		{"restarting", "restarting", ago(time.Hour), ago(5 * time.Second), 1, "", "Restarting (1) 5 seconds ago"},
+		{"exited", "exited", ago(3 * 24 * time.Hour), ago(2 * 24 * time.Hour), 137, "", "Exited (137) 2 days ago"},
+		{"exited with an error", "exited", ago(time.Hour), ago(30 * time.Second), 1, "oom", "Exited (1) 30 seconds ago, oom"},
+		{"created", "created", never, never, 0, "", "Created"},
+		{"missing times", "created", "", "", 0, "", "Created"},
+		{"dead", "dead", ago(time.Hour), ago(time.Minute), 0, "", "Dead"},
+		{"unknown state without a finish time", "unknown", ago(time.Hour), never, 0, "", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &nerdctlContainerInspectResponse{}
+			c.State.Status = tt.status
+			c.State.StartedAt = tt.startedAt
+			c.State.FinishedAt = tt.finishedAt
+			c.State.ExitCode = tt.exitCode
+			c.State.Error = tt.err
+
+			if got := nerdctlContainerStatus(c, now); got != tt.want {
+				t.Errorf("nerdctlContainerStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNerdctlSourceRequiresBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	source := &dockerSource{Mode: dockerSourceModeNerdctl}
+	if err := source.initialize(); err == nil || !strings.Contains(err.Error(), "nerdctl binary") {
+		t.Errorf("initialize() error = %v, want one about the missing binary", err)
+	}
+}
+
+func TestFetchNerdctlContainers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of nerdctl")
+	}
+
+	dir := t.TempDir()
+	startedAt := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339Nano)
+
+	// stands in for nerdctl, answering the list and inspect commands that are run
+	script := `#!/bin/sh
+case "$*" in
+  *" ps "*) echo abc123 ;;
+  *" inspect "*) echo '[{"Id": "abc123", "Name": "web", "Image": "nginx", "State": {"Status": "running", "StartedAt": "` + startedAt + `"}, "Config": {"Labels": {"glance.name": "Web"}}}]' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "nerdctl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	source := &dockerSource{Mode: dockerSourceModeNerdctl}
+	if err := source.initialize(); err != nil {
+		t.Fatalf("initialize() error = %v", err)
+	}
+
+	containers, err := fetchNerdctlContainers(source, false)
+	if err != nil {
+		t.Fatalf("fetchNerdctlContainers() error = %v", err)
+	}
+
+	if len(containers) != 1 || containers[0].Names[0] != "/web" || containers[0].Status != "Up 3 hours" || containers[0].Labels["glance.name"] != "Web" {
+		t.Errorf("containers = %+v", containers)
+	}
+}
@@ -0,0 +1,281 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Docker samples the stats twice in order to calculate the CPU usage, so each request
+	// takes at least a second, this keeps hosts with many containers from opening too many
+	// connections at once and the deadline keeps a host that stops responding from holding
+	// up the next round of fetches
+	dockerContainerStatsWorkers  = 10
+	dockerContainerStatsDeadline = 30 * time.Second
+	dockerContainerStatsHistory  = 30
+	// How long the first update waits for the stats, so that they're shown right
+	// away unless fetching them takes long enough to noticeably hold it up
+	dockerContainerStatsFirstWait = 3 * time.Second
+)
+
+type dockerContainerStats struct {
+	CPUPercent         float64
+	MemoryUsedMB       uint64
+	MemoryLimitMB      uint64
+	NetworkReceivedMB  uint64
+	NetworkSentMB      uint64
+	CPUSparklinePoints string
+}
+
+func (s *dockerContainerStats) CPUText() string {
+	return strconv.FormatFloat(s.CPUPercent, 'f', ternary(s.CPUPercent < 10, 1, 0), 64) + "%"
+}
+
+type dockerContainerStatsResponse struct {
+	CPUStats    dockerContainerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerContainerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+type dockerContainerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemCPUUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs     uint32 `json:"online_cpus"`
+}
+
+type dockerContainerStatsRequest struct {
+	ctx         context.Context
+	source      *dockerSource
+	containerID string
+}
+
+// Stats are fetched in the background so that they don't hold up the widget's update, which
+// means that the values shown are the ones fetched after the previous update. The exception
+// is the first update, which waits for up to dockerContainerStatsFirstWait for them.
+type dockerContainerStatsCollector struct {
+	mu sync.Mutex
+	// by source and container name so that they're kept when a container gets recreated
+	latest     map[string]dockerContainerStats
+	cpuHistory map[string][]float64
+	fetching   bool
+	started    bool
+}
+
+func dockerContainerStatsKey(container *dockerContainer) string {
+	return container.source.SockPath + ":" + container.Name
+}
+
+// Only fetched for running containers at the top level, the history used for the
+// sparklines is kept for as long as the container keeps showing up in updates.
+// The containers of all hosts share the same workers and deadline.
+func (widget *dockerContainersWidget) applyContainerStats(hosts []dockerContainerHost) {
+	collector := &widget.stats
+	containers := make([]*dockerContainer, 0)
+	keys := make([]string, 0)
+	requests := make([]dockerContainerStatsRequest, 0)
+
+	for h := range hosts {
+		for i := range hosts[h].Containers {
+			container := &hosts[h].Containers[i]
+			if container.State != "running" || container.ID == "" || !container.source.hasContainerAPI() {
+				continue
+			}
+
+			containers = append(containers, container)
+			keys = append(keys, dockerContainerStatsKey(container))
+			requests = append(requests, dockerContainerStatsRequest{
+				source:      container.source,
+				containerID: container.ID,
+			})
+		}
+	}
+
+	var firstFetch chan struct{}
+
+	collector.mu.Lock()
+	if !collector.fetching {
+		done := make(chan struct{})
+		if !collector.started {
+			firstFetch = done
+		}
+
+		collector.fetching = true
+		collector.started = true
+		go collector.fetch(keys, requests, done)
+	}
+	collector.mu.Unlock()
+
+	if firstFetch != nil {
+		select {
+		case <-firstFetch:
+		case <-time.After(dockerContainerStatsFirstWait):
+		}
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	for i, container := range containers {
+		if stats, exists := collector.latest[keys[i]]; exists {
+			container.Stats = &stats
+		}
+	}
+}
+
+func (collector *dockerContainerStatsCollector) fetch(keys []string, requests []dockerContainerStatsRequest, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dockerContainerStatsDeadline)
+	defer cancel()
+
+	for i := range requests {
+		requests[i].ctx = ctx
+	}
+
+	job := newJob(fetchDockerContainerStatsTask, requests).withWorkers(dockerContainerStatsWorkers)
+	results, errs, err := workerPoolDo(job)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	collector.fetching = false
+
+	if err != nil {
+		slog.Error("Failed to fetch docker container stats", "error", err)
+		return
+	}
+
+	if collector.latest == nil {
+		collector.latest = make(map[string]dockerContainerStats)
+		collector.cpuHistory = make(map[string][]float64)
+	}
+
+	seen := make(map[string]bool, len(keys))
+	failed := 0
+	var firstErr error
+
+	for i, key := range keys {
+		// the last known values are kept for containers that couldn't be fetched this time
+		seen[key] = true
+
+		if errs[i] != nil {
+			if failed == 0 {
+				firstErr = errs[i]
+			}
+
+			failed++
+			continue
+		}
+
+		history := append(collector.cpuHistory[key], results[i].CPUPercent)
+		if len(history) > dockerContainerStatsHistory {
+			history = history[len(history)-dockerContainerStatsHistory:]
+		}
+
+		collector.cpuHistory[key] = history
+		results[i].CPUSparklinePoints = svgPolylineCoordsFromYValues(100, 20, history)
+		collector.latest[key] = results[i]
+	}
+
+	for key := range collector.latest {
+		if !seen[key] {
+			delete(collector.latest, key)
+			delete(collector.cpuHistory, key)
+		}
+	}
+
+	if failed > 0 {
+		slog.Warn("Failed to fetch stats for some docker containers", "failed", failed, "total", len(keys), "error", firstErr)
+	}
+}
+
+func fetchDockerContainerStatsTask(request dockerContainerStatsRequest) (dockerContainerStats, error) {
+	// skips the remaining containers once the deadline is reached rather than queueing up requests
+	if err := request.ctx.Err(); err != nil {
+		return dockerContainerStats{}, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(
+		request.ctx,
+		"GET",
+		request.source.baseURL+"/containers/"+url.PathEscape(request.containerID)+"/stats?stream=false",
+		nil,
+	)
+	if err != nil {
+		return dockerContainerStats{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := request.source.client.Do(httpRequest)
+	if err != nil {
+		return dockerContainerStats{}, fmt.Errorf("sending request to socket: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return dockerContainerStats{}, fmt.Errorf("non-200 response status: %s", response.Status)
+	}
+
+	var body dockerContainerStatsResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return dockerContainerStats{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return body.toStats(), nil
+}
+
+// Calculated the same way as the docker stats command does
+func (r *dockerContainerStatsResponse) toStats() dockerContainerStats {
+	stats := dockerContainerStats{MemoryLimitMB: r.MemoryStats.Limit / 1024 / 1024}
+
+	cpuDelta := float64(r.CPUStats.CPUUsage.TotalUsage) - float64(r.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(r.CPUStats.SystemCPUUsage) - float64(r.PreCPUStats.SystemCPUUsage)
+	onlineCPUs := float64(r.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(r.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// the page cache isn't considered as used, the key depends on whether cgroups v1 or v2 is used
+	inactiveFile, ok := r.MemoryStats.Stats["total_inactive_file"]
+	if !ok {
+		inactiveFile = r.MemoryStats.Stats["inactive_file"]
+	}
+
+	memoryUsed := r.MemoryStats.Usage
+	if inactiveFile < memoryUsed {
+		memoryUsed -= inactiveFile
+	}
+	stats.MemoryUsedMB = memoryUsed / 1024 / 1024
+
+	var received, sent uint64
+	for _, network := range r.Networks {
+		received += network.RxBytes
+		sent += network.TxBytes
+	}
+
+	stats.NetworkReceivedMB = received / 1024 / 1024
+	stats.NetworkSentMB = sent / 1024 / 1024
+
+	return stats
+}
@@ -0,0 +1,120 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDockerContainerStatsResponseToStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     dockerContainerStats
+	}{
+		{
+			name: "cgroups v2",
+			response: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 400}, "system_cpu_usage": 2000, "online_cpus": 4},
+				"precpu_stats": {"cpu_usage": {"total_usage": 200}, "system_cpu_usage": 1000},
+				"memory_stats": {"usage": 209715200, "limit": 2147483648, "stats": {"inactive_file": 104857600}},
+				"networks": {"eth0": {"rx_bytes": 3145728, "tx_bytes": 1048576}, "eth1": {"rx_bytes": 1048576, "tx_bytes": 1048576}}
+			}`,
+			want: dockerContainerStats{CPUPercent: 80, MemoryUsedMB: 100, MemoryLimitMB: 2048, NetworkReceivedMB: 4, NetworkSentMB: 2},
+		},
+		{
+			name: "cgroups v1 without online cpus",
+			response: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 300, "percpu_usage": [1, 2]}, "system_cpu_usage": 2000},
+				"precpu_stats": {"cpu_usage": {"total_usage": 200}, "system_cpu_usage": 1000},
+				"memory_stats": {"usage": 104857600, "stats": {"total_inactive_file": 52428800, "inactive_file": 1}}
+			}`,
+			want: dockerContainerStats{CPUPercent: 20, MemoryUsedMB: 50},
+		},
+		{
+			name: "first sample without previous cpu stats",
+			response: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 2000, "online_cpus": 1},
+				"memory_stats": {"usage": 1048576, "stats": {"inactive_file": 2097152}}
+			}`,
+			want: dockerContainerStats{CPUPercent: 15, MemoryUsedMB: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response dockerContainerStatsResponse
+			if err := json.Unmarshal([]byte(tt.response), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := response.toStats(); got != tt.want {
+				t.Errorf("toStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerContainersStatsFetchedInBackground(t *testing.T) {
+	url, _ := startFakeDockerAPI(t, []dockerContainerJsonResponse{
+		{ID: "web", Names: []string{"/web"}, State: "running"},
+		{ID: "old", Names: []string{"/old"}, State: "exited"},
+	})
+
+	widget := &dockerContainersWidget{SockPath: url, ShowStats: true}
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	stats := func() map[string]*dockerContainerStats {
+		widget.update(context.Background())
+
+		stats := make(map[string]*dockerContainerStats)
+		for _, container := range widget.Hosts[0].Containers {
+			stats[container.Name] = container.Stats
+		}
+
+		return stats
+	}
+
+	first := stats()
+	if web := first["web"]; web == nil || web.CPUPercent != 40 || web.MemoryUsedMB != 100 || web.MemoryLimitMB != 1024 {
+		t.Errorf("stats on the first update = %+v, want the ones it waited for", web)
+	}
+
+	if first["old"] != nil {
+		t.Errorf("stats of a stopped container = %+v, want none", first["old"])
+	}
+
+	// waits for the fetch started by the last update to finish
+	waitForFetch := func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			widget.stats.mu.Lock()
+			fetching := widget.stats.fetching
+			widget.stats.mu.Unlock()
+
+			if !fetching {
+				return
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("stats were not fetched in the background")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// only the first update waits for the stats, so the second value needed
+	// for a sparkline is fetched after the second update and shown by the third
+	waitForFetch()
+	stats()
+	waitForFetch()
+
+	if third := stats(); third["web"] == nil || third["web"].CPUSparklinePoints == "" {
+		t.Errorf("stats on the third update = %+v, want a sparkline from the previous values", third["web"])
+	}
+
+	waitForFetch()
+}
@@ -0,0 +1,150 @@
+package glance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dockerLabelComposeProject = "com.docker.compose.project"
+	dockerLabelStackNamespace = "com.docker.stack.namespace"
+)
+
+type dockerSwarmServiceResponse struct {
+	ID   string `json:"ID"`
+	Spec struct {
+		Name   string                `json:"Name"`
+		Labels dockerContainerLabels `json:"Labels"`
+		Mode   struct {
+			Replicated *struct {
+				Replicas uint64 `json:"Replicas"`
+			} `json:"Replicated"`
+			Global *struct{} `json:"Global"`
+		} `json:"Mode"`
+		TaskTemplate struct {
+			ContainerSpec struct {
+				Image string `json:"Image"`
+			} `json:"ContainerSpec"`
+		} `json:"TaskTemplate"`
+	} `json:"Spec"`
+}
+
+type dockerSwarmTaskResponse struct {
+	ServiceID    string `json:"ServiceID"`
+	DesiredState string `json:"DesiredState"`
+	Status       struct {
+		State string `json:"State"`
+		Err   string `json:"Err"`
+	} `json:"Status"`
+}
+
+// Services are represented the same way as containers so that everything else, such as labels,
+// hiding, categories and parent/child grouping, works the same regardless of the mode. Their state
+// is derived from how many of their tasks are running compared to how many should be.
+func fetchDockerSwarmServices(source *dockerSource, runningOnly bool) ([]dockerContainerJsonResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	services, err := fetchDockerAPI[[]dockerSwarmServiceResponse](ctx, source, "/services")
+	if err != nil {
+		return nil, fmt.Errorf("fetching services: %w", err)
+	}
+
+	filters := url.QueryEscape(`{"desired-state":["running"]}`)
+	tasks, err := fetchDockerAPI[[]dockerSwarmTaskResponse](ctx, source, "/tasks?filters="+filters)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tasks: %w", err)
+	}
+
+	type taskCounts struct {
+		running int
+		desired int
+		lastErr string
+	}
+
+	counts := make(map[string]*taskCounts, len(services))
+	for i := range tasks {
+		task := &tasks[i]
+		c, ok := counts[task.ServiceID]
+		if !ok {
+			c = &taskCounts{}
+			counts[task.ServiceID] = c
+		}
+
+		c.desired++
+		if task.Status.State == "running" {
+			c.running++
+		} else if task.Status.Err != "" {
+			c.lastErr = task.Status.Err
+		}
+	}
+
+	containers := make([]dockerContainerJsonResponse, 0, len(services))
+
+	for i := range services {
+		service := &services[i]
+		c := counts[service.ID]
+		if c == nil {
+			c = &taskCounts{}
+		}
+
+		// for global services the number of tasks that should be running is
+		// the number of nodes, which is what the tasks themselves reflect
+		desired := c.desired
+		if service.Spec.Mode.Replicated != nil {
+			desired = int(service.Spec.Mode.Replicated.Replicas)
+		}
+
+		state := "running"
+		switch {
+		case desired == 0:
+			state = "scaled down"
+		case c.running == 0:
+			state = "down"
+		case c.running < desired:
+			state = "degraded"
+		}
+
+		if runningOnly && state != "running" && state != "degraded" {
+			continue
+		}
+
+		status := strconv.Itoa(c.running) + "/" + strconv.Itoa(desired) + " replicas"
+		if service.Spec.Mode.Global != nil {
+			status = strconv.Itoa(c.running) + "/" + strconv.Itoa(desired) + " tasks (global)"
+		}
+
+		if c.lastErr != "" && c.running < desired {
+			status += ", " + c.lastErr
+		}
+
+		image, _, _ := strings.Cut(service.Spec.TaskTemplate.ContainerSpec.Image, "@")
+
+		containers = append(containers, dockerContainerJsonResponse{
+			ID:     service.ID,
+			Names:  []string{"/" + service.Spec.Name},
+			Image:  image,
+			State:  state,
+			Status: status,
+			Labels: service.Spec.Labels,
+		})
+	}
+
+	return containers, nil
+}
+
+func fetchDockerAPI[T any](ctx context.Context, source *dockerSource, path string) (T, error) {
+	var result T
+
+	request, err := http.NewRequestWithContext(ctx, "GET", source.baseURL+path, nil)
+	if err != nil {
+		return result, fmt.Errorf("creating request: %w", err)
+	}
+
+	return decodeJsonFromRequest[T](source.client, request)
+}
@@ -0,0 +1,214 @@
+package glance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchDockerSwarmServices(t *testing.T) {
+	services := `[
+		{"ID": "web", "Spec": {"Name": "app_web", "Labels": {"com.docker.stack.namespace": "app"}, "Mode": {"Replicated": {"Replicas": 3}}, "TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.27@sha256:abc"}}}},
+		{"ID": "db", "Spec": {"Name": "app_db", "Mode": {"Replicated": {"Replicas": 1}}}},
+		{"ID": "worker", "Spec": {"Name": "worker", "Mode": {"Replicated": {"Replicas": 2}}}},
+		{"ID": "batch", "Spec": {"Name": "batch", "Mode": {"Replicated": {"Replicas": 0}}}},
+		{"ID": "agent", "Spec": {"Name": "agent", "Mode": {"Global": {}}}}
+	]`
+
+	tasks := `[
+		{"ServiceID": "web", "Status": {"State": "running"}},
+		{"ServiceID": "web", "Status": {"State": "running"}},
+		{"ServiceID": "web", "Status": {"State": "pending", "Err": "no suitable node"}},
+		{"ServiceID": "db", "Status": {"State": "running"}},
+		{"ServiceID": "worker", "Status": {"State": "rejected", "Err": "image not found"}},
+		{"ServiceID": "agent", "Status": {"State": "running"}},
+		{"ServiceID": "agent", "Status": {"State": "running"}}
+	]`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services":
+			w.Write([]byte(services))
+		case "/tasks":
+			if !strings.Contains(r.URL.Query().Get("filters"), "desired-state") {
+				t.Errorf("expected tasks to be filtered by their desired state, got %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(tasks))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &dockerSource{SockPath: server.URL, Mode: dockerSourceModeSwarm}
+	if err := source.initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		runningOnly bool
+		want        map[string][2]string
+	}{
+		{
+			name: "all services",
+			want: map[string][2]string{
+				"/app_web": {"degraded", "2/3 replicas, no suitable node"},
+				"/app_db":  {"running", "1/1 replicas"},
+				"/worker":  {"down", "0/2 replicas, image not found"},
+				"/batch":   {"scaled down", "0/0 replicas"},
+				"/agent":   {"running", "2/2 tasks (global)"},
+			},
+		},
+		{
+			name:        "running only keeps degraded services",
+			runningOnly: true,
+			want: map[string][2]string{
+				"/app_web": {"degraded", "2/3 replicas, no suitable node"},
+				"/app_db":  {"running", "1/1 replicas"},
+				"/agent":   {"running", "2/2 tasks (global)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containers, err := fetchDockerSwarmServices(source, tt.runningOnly)
+			if err != nil {
+				t.Fatalf("fetchDockerSwarmServices() error = %v", err)
+			}
+
+			if len(containers) != len(tt.want) {
+				t.Fatalf("got %d services, want %d: %+v", len(containers), len(tt.want), containers)
+			}
+
+			for _, container := range containers {
+				want, ok := tt.want[container.Names[0]]
+				if !ok {
+					t.Errorf("unexpected service %s", container.Names[0])
+					continue
+				}
+
+				if container.State != want[0] || container.Status != want[1] {
+					t.Errorf("%s = %q %q, want %q %q", container.Names[0], container.State, container.Status, want[0], want[1])
+				}
+			}
+
+			if containers[0].Image != "nginx:1.27" || containers[0].Labels[dockerLabelStackNamespace] != "app" {
+				t.Errorf("expected the image without its digest and the labels of the service, got %+v", containers[0])
+			}
+		})
+	}
+}
+
+func TestDockerContainersGroupContainers(t *testing.T) {
+	container := func(name, stateIcon, project string) dockerContainer {
+		return dockerContainer{Name: name, StateIcon: stateIcon, project: project}
+	}
+
+	type group struct {
+		name       string
+		stateIcon  string
+		containers []string
+	}
+
+	tests := []struct {
+		name       string
+		groupBy    string
+		containers dockerContainerList
+		want       []group
+	}{
+		{
+			name:    "grouping disabled",
+			groupBy: "",
+			containers: dockerContainerList{
+				container("db", dockerContainerStateIconOK, "media"),
+				container("web", dockerContainerStateIconOK, ""),
+			},
+			want: []group{{"", "", []string{"db", "web"}}},
+		},
+		{
+			name:    "groups sorted by name with ungrouped containers last",
+			groupBy: "project",
+			containers: dockerContainerList{
+				container("jellyfin", dockerContainerStateIconOK, "media"),
+				container("proxy", dockerContainerStateIconOK, ""),
+				container("immich", dockerContainerStateIconOK, "Photos"),
+				container("sonarr", dockerContainerStateIconOK, "media"),
+			},
+			want: []group{
+				{"media", dockerContainerStateIconOK, []string{"jellyfin", "sonarr"}},
+				{"Photos", dockerContainerStateIconOK, []string{"immich"}},
+				{"", "", []string{"proxy"}},
+			},
+		},
+		{
+			name:    "groups needing attention come first",
+			groupBy: "project",
+			containers: dockerContainerList{
+				container("db", dockerContainerStateIconWarn, "zeta"),
+				container("web", dockerContainerStateIconOK, "alpha"),
+				container("cache", dockerContainerStateIconOK, "zeta"),
+			},
+			want: []group{
+				{"zeta", dockerContainerStateIconWarn, []string{"db", "cache"}},
+				{"alpha", dockerContainerStateIconOK, []string{"web"}},
+			},
+		},
+		{
+			name:       "no containers",
+			groupBy:    "project",
+			containers: dockerContainerList{},
+			want:       []group{{"", "", nil}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			widget := &dockerContainersWidget{GroupBy: tt.groupBy}
+			groups := widget.groupContainers(tt.containers)
+
+			if len(groups) != len(tt.want) {
+				t.Fatalf("got %d groups, want %d: %+v", len(groups), len(tt.want), groups)
+			}
+
+			for i, want := range tt.want {
+				names := make([]string, 0, len(groups[i].Containers))
+				for _, c := range groups[i].Containers {
+					names = append(names, c.Name)
+				}
+
+				if groups[i].Name != want.name || groups[i].StateIcon != want.stateIcon || strings.Join(names, ",") != strings.Join(want.containers, ",") {
+					t.Errorf("group %d = %s %s %v, want %s %s %v", i, groups[i].Name, groups[i].StateIcon, names, want.name, want.stateIcon, want.containers)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchDockerContainersProject(t *testing.T) {
+	url, _ := startFakeDockerAPI(t, []dockerContainerJsonResponse{
+		{ID: "a", Names: []string{"/a"}, Labels: dockerContainerLabels{dockerLabelComposeProject: "media"}},
+		{ID: "b", Names: []string{"/b"}, Labels: dockerContainerLabels{dockerLabelStackNamespace: "app"}},
+		{ID: "c", Names: []string{"/c"}, Labels: dockerContainerLabels{dockerLabelComposeProject: "media", dockerLabelStackNamespace: "app"}},
+		{ID: "d", Names: []string{"/d"}},
+	})
+
+	source := &dockerSource{SockPath: url}
+	if err := source.initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := fetchDockerContainers(source, false, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a": "media", "b": "app", "c": "media", "d": ""}
+	for _, container := range containers {
+		if container.project != want[container.Name] {
+			t.Errorf("project of %s = %q, want %q", container.Name, container.project, want[container.Name])
+		}
+	}
+}
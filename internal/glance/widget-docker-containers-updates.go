@@ -0,0 +1,446 @@
+package glance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Registries such as Docker Hub rate limit requests, and images don't usually get updated
+	// more than a few times a day, so there's no point in checking them any more often than this
+	dockerImageUpdatesCacheDuration      = 6 * time.Hour
+	dockerImageUpdatesErrorCacheDuration = 1 * time.Hour
+	dockerImageUpdatesMaxConcurrentFetch = 4
+	dockerImageUpdatesRequestTimeout     = 15 * time.Second
+)
+
+const dockerHubRegistry = "docker.io"
+
+var dockerImageManifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+type dockerImageReference struct {
+	Registry   string
+	Repository string
+	// Empty when the reference doesn't include a tag
+	Tag string
+}
+
+// Accepts references such as nginx, nginx:1.27, linuxserver/jellyfin and
+// ghcr.io/owner/image:tag, images pinned to a digest aren't supported
+func parseDockerImageReference(value string) (dockerImageReference, error) {
+	if value == "" || strings.HasPrefix(value, "sha256:") || strings.Contains(value, "@") {
+		return dockerImageReference{}, fmt.Errorf("unsupported image reference: %s", value)
+	}
+
+	ref := dockerImageReference{Registry: dockerHubRegistry}
+	name := value
+
+	if registry, rest, found := strings.Cut(name, "/"); found {
+		if strings.ContainsAny(registry, ".:") || registry == "localhost" {
+			ref.Registry = registry
+			name = rest
+		}
+	}
+
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+
+		if ref.Tag == "" {
+			return dockerImageReference{}, fmt.Errorf("invalid image reference: %s", value)
+		}
+	}
+
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return dockerImageReference{}, fmt.Errorf("invalid image reference: %s", value)
+	}
+
+	if ref.Registry == "index.docker.io" || ref.Registry == "registry-1.docker.io" {
+		ref.Registry = dockerHubRegistry
+	}
+
+	if ref.Registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	ref.Repository = name
+
+	return ref, nil
+}
+
+func (ref dockerImageReference) tagOrLatest() string {
+	return ternary(ref.Tag == "", "latest", ref.Tag)
+}
+
+func (ref dockerImageReference) String() string {
+	return ref.Registry + "/" + ref.Repository + ":" + ref.tagOrLatest()
+}
+
+func (ref dockerImageReference) registryAPIHost() string {
+	if ref.Registry == dockerHubRegistry {
+		return "registry-1.docker.io"
+	}
+
+	return ref.Registry
+}
+
+type dockerRegistryCredentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+type dockerRemoteDigest struct {
+	digest    string
+	err       error
+	fetchedAt time.Time
+}
+
+type dockerLocalDigests struct {
+	digests   []string
+	err       error
+	fetchedAt time.Time
+}
+
+// Shared by all widgets since the same images are often used across multiple hosts.
+// Checks are done in the background so that they never hold up the widget's update,
+// which means that the result for an image is only shown on the update after it.
+type dockerImageUpdateChecker struct {
+	mu sync.Mutex
+	// by image reference, such as docker.io/library/nginx:latest
+	remote map[string]*dockerRemoteDigest
+	// by source and image ID, an image's repo digests never change so these only get
+	// refetched after failing, image IDs are only unique within the host they're on
+	local     map[string]*dockerLocalDigests
+	inFlight  map[string]bool
+	semaphore chan struct{}
+}
+
+var dockerImageUpdates = &dockerImageUpdateChecker{
+	remote:    make(map[string]*dockerRemoteDigest),
+	local:     make(map[string]*dockerLocalDigests),
+	inFlight:  make(map[string]bool),
+	semaphore: make(chan struct{}, dockerImageUpdatesMaxConcurrentFetch),
+}
+
+// Returns whether a newer image is available and whether that's known yet
+func (c *dockerImageUpdateChecker) status(
+	source *dockerSource,
+	imageID string,
+	image string,
+	credentials map[string]*dockerRegistryCredentials,
+) (bool, bool) {
+	ref, err := parseDockerImageReference(image)
+	if err != nil || imageID == "" {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	key := ref.String()
+	localKey := source.SockPath + "\x00" + imageID
+
+	local, localKnown := c.local[localKey]
+	if localKnown && local.err != nil && now.Sub(local.fetchedAt) > dockerImageUpdatesErrorCacheDuration {
+		localKnown = false
+	}
+
+	remote, remoteKnown := c.remote[key]
+	if remoteKnown {
+		maxAge := ternary(remote.err != nil, dockerImageUpdatesErrorCacheDuration, dockerImageUpdatesCacheDuration)
+		if now.Sub(remote.fetchedAt) > maxAge {
+			remoteKnown = false
+		}
+	}
+
+	if !localKnown && !c.inFlight[localKey] {
+		c.inFlight[localKey] = true
+		go c.fetchLocal(source, imageID, localKey)
+	}
+
+	if !remoteKnown && !c.inFlight[key] {
+		c.inFlight[key] = true
+		go c.fetchRemote(ref, credentials[ref.Registry])
+	}
+
+	if remote == nil || remote.err != nil || local == nil || local.err != nil {
+		return false, false
+	}
+
+	// images built locally or pulled by digest don't have a repo digest for their tag
+	if len(local.digests) == 0 {
+		return false, false
+	}
+
+	for _, digest := range local.digests {
+		if digest == remote.digest {
+			return false, true
+		}
+	}
+
+	return true, true
+}
+
+func (c *dockerImageUpdateChecker) fetchLocal(source *dockerSource, imageID string, key string) {
+	c.semaphore <- struct{}{}
+	digests, err := fetchDockerImageRepoDigests(source, imageID)
+	<-c.semaphore
+
+	if err != nil {
+		slog.Warn("Failed to fetch docker image digests", "image", imageID, "error", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inFlight, key)
+	c.local[key] = &dockerLocalDigests{digests: digests, err: err, fetchedAt: time.Now()}
+}
+
+func (c *dockerImageUpdateChecker) fetchRemote(ref dockerImageReference, credentials *dockerRegistryCredentials) {
+	c.semaphore <- struct{}{}
+	digest, err := fetchRegistryManifestDigest(ref, credentials)
+	<-c.semaphore
+
+	if err != nil {
+		slog.Warn("Failed to check for image update", "image", ref.String(), "error", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := ref.String()
+	delete(c.inFlight, key)
+	c.remote[key] = &dockerRemoteDigest{digest: digest, err: err, fetchedAt: time.Now()}
+}
+
+func (widget *dockerContainersWidget) markImageUpdates(hosts []dockerContainerHost) {
+	for h := range hosts {
+		for i := range hosts[h].Containers {
+			container := &hosts[h].Containers[i]
+			if !container.checkImageUpdates || !container.source.hasContainerAPI() {
+				continue
+			}
+
+			container.ImageUpdateAvailable, _ = dockerImageUpdates.status(
+				container.source,
+				container.imageID,
+				container.Image,
+				widget.Registries,
+			)
+		}
+	}
+}
+
+func fetchDockerImageRepoDigests(source *dockerSource, imageID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", source.baseURL+"/images/"+url.PathEscape(imageID)+"/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := decodeJsonFromRequest[struct {
+		RepoDigests []string `json:"RepoDigests"`
+	}](source.client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]string, 0, len(response.RepoDigests))
+	for _, repoDigest := range response.RepoDigests {
+		if _, digest, found := strings.Cut(repoDigest, "@"); found {
+			digests = append(digests, digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// Uses the registry API described in the OCI distribution spec, which Docker Hub, GHCR, Quay and
+// most self-hosted registries implement, authenticating with a token when the registry asks for one
+func fetchRegistryManifestDigest(ref dockerImageReference, credentials *dockerRegistryCredentials) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerImageUpdatesRequestTimeout)
+	defer cancel()
+
+	manifestURL := "https://" + ref.registryAPIHost() + "/v2/" + ref.Repository + "/manifests/" + url.PathEscape(ref.tagOrLatest())
+	authorization := ""
+
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, "HEAD", manifestURL, nil)
+		if err != nil {
+			return "", fmt.Errorf("creating request: %w", err)
+		}
+
+		request.Header.Set("Accept", strings.Join(dockerImageManifestMediaTypes, ", "))
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		response, err := defaultHTTPClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			authorization, err = fetchRegistryAuthorization(ctx, response.Header.Get("WWW-Authenticate"), credentials)
+			if err != nil {
+				return "", fmt.Errorf("authenticating: %w", err)
+			}
+
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected response status for manifest: %s", response.Status)
+		}
+
+		if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+			return digest, nil
+		}
+
+		// not all registries include the digest in the response to HEAD requests
+		return fetchRegistryManifestDigestFromBody(ctx, manifestURL, authorization)
+	}
+
+	return "", errors.New("registry did not accept the credentials")
+}
+
+func fetchRegistryManifestDigestFromBody(ctx context.Context, manifestURL string, authorization string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	request.Header.Set("Accept", strings.Join(dockerImageManifestMediaTypes, ", "))
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status for manifest: %s", response.Status)
+	}
+
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(response.Body, 4*1024*1024)); err != nil {
+		return "", fmt.Errorf("reading manifest: %w", err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the value of the Authorization header to use based on the challenge from the registry,
+// credentials are optional since most registries hand out anonymous tokens for public images
+func fetchRegistryAuthorization(ctx context.Context, challenge string, credentials *dockerRegistryCredentials) (string, error) {
+	scheme, params := parseRegistryAuthChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if credentials == nil {
+			return "", errors.New("registry requires credentials")
+		}
+
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.Username+":"+credentials.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge: %s", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid realm in challenge: %s", challenge)
+	}
+
+	query := realm.Query()
+	for _, name := range []string{"service", "scope"} {
+		if params[name] != "" {
+			query.Set(name, params[name])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+
+	if credentials != nil {
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+
+	response, err := decodeJsonFromRequest[struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}](defaultHTTPClient, request)
+	if err != nil {
+		return "", fmt.Errorf("fetching token: %w", err)
+	}
+
+	token := ternary(response.Token != "", response.Token, response.AccessToken)
+	if token == "" {
+		return "", errors.New("registry did not return a token")
+	}
+
+	return "Bearer " + token, nil
+}
+
+// Parses values such as Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseRegistryAuthChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := make(map[string]string)
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.IndexByte(value[1:], '"')
+			if end == -1 {
+				params[key] = value[1:]
+				break
+			}
+
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return scheme, params
+}
@@ -0,0 +1,163 @@
+package glance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseDockerImageReference(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected dockerImageReference
+	}{
+		{"nginx", dockerImageReference{"docker.io", "library/nginx", ""}},
+		{"nginx:1.27-alpine", dockerImageReference{"docker.io", "library/nginx", "1.27-alpine"}},
+		{"linuxserver/jellyfin:latest", dockerImageReference{"docker.io", "linuxserver/jellyfin", "latest"}},
+		{"docker.io/glanceapp/glance", dockerImageReference{"docker.io", "glanceapp/glance", ""}},
+		{"index.docker.io/library/redis:7", dockerImageReference{"docker.io", "library/redis", "7"}},
+		{"ghcr.io/owner/app/web:v2", dockerImageReference{"ghcr.io", "owner/app/web", "v2"}},
+		{"registry.local:5000/app", dockerImageReference{"registry.local:5000", "app", ""}},
+		{"localhost/app:dev", dockerImageReference{"localhost", "app", "dev"}},
+	}
+
+	for _, test := range tests {
+		ref, err := parseDockerImageReference(test.value)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.value, err)
+			continue
+		}
+
+		if ref != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.value, test.expected, ref)
+		}
+	}
+
+	for _, invalid := range []string{"", "sha256:4f1b2c", "nginx@sha256:4f1b2c", "nginx:", "ghcr.io/"} {
+		if _, err := parseDockerImageReference(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestParseRegistryAuthChallenge(t *testing.T) {
+	scheme, params := parseRegistryAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+
+	if scheme != "Bearer" {
+		t.Errorf("expected scheme Bearer, got %s", scheme)
+	}
+
+	expected := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, params[key])
+		}
+	}
+}
+
+func TestDockerImageUpdateCheckerStatus(t *testing.T) {
+	// serves the repo digests of every image, or fails when digest is empty
+	startImageAPI := func(digest string, requests *atomic.Int32) *dockerSource {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if digest == "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Write([]byte(`{"RepoDigests": ["nginx@` + digest + `"]}`))
+		}))
+		t.Cleanup(server.Close)
+
+		source := &dockerSource{SockPath: server.URL}
+		if err := source.initialize(); err != nil {
+			t.Fatal(err)
+		}
+
+		return source
+	}
+
+	var upToDateRequests, outdatedRequests, failingRequests atomic.Int32
+	upToDate := startImageAPI("sha256:new", &upToDateRequests)
+	outdated := startImageAPI("sha256:old", &outdatedRequests)
+	failing := startImageAPI("", &failingRequests)
+
+	checker := &dockerImageUpdateChecker{
+		remote: map[string]*dockerRemoteDigest{
+			"docker.io/library/nginx:latest": {digest: "sha256:new", fetchedAt: time.Now()},
+		},
+		local:     make(map[string]*dockerLocalDigests),
+		inFlight:  make(map[string]bool),
+		semaphore: make(chan struct{}, dockerImageUpdatesMaxConcurrentFetch),
+	}
+
+	waitForFetches := func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			checker.mu.Lock()
+			inFlight := len(checker.inFlight)
+			checker.mu.Unlock()
+
+			if inFlight == 0 {
+				return
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("fetches did not finish")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	expireLocal := func(source *dockerSource) func() {
+		return func() {
+			checker.mu.Lock()
+			checker.local[source.SockPath+"\x00sha256:image"].fetchedAt = time.Now().Add(-2 * dockerImageUpdatesErrorCacheDuration)
+			checker.mu.Unlock()
+		}
+	}
+
+	// the same image ID is used on every host, so results must not be shared between them
+	steps := []struct {
+		name          string
+		source        *dockerSource
+		requests      *atomic.Int32
+		before        func()
+		wantAvailable bool
+		wantKnown     bool
+		wantRequests  int32
+	}{
+		{"unknown until fetched", upToDate, &upToDateRequests, nil, false, false, 1},
+		{"up to date", upToDate, &upToDateRequests, nil, false, true, 1},
+		{"same image ID on another host", outdated, &outdatedRequests, nil, false, false, 1},
+		{"outdated on the other host", outdated, &outdatedRequests, nil, true, true, 1},
+		{"failing host", failing, &failingRequests, nil, false, false, 1},
+		{"failure is cached", failing, &failingRequests, nil, false, false, 1},
+		{"failure is retried once expired", failing, &failingRequests, expireLocal(failing), false, false, 2},
+		{"successful results don't expire", upToDate, &upToDateRequests, nil, false, true, 1},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		available, known := checker.status(step.source, "sha256:image", "nginx", nil)
+		waitForFetches()
+
+		if available != step.wantAvailable || known != step.wantKnown {
+			t.Errorf("%s: status() = %v, %v, want %v, %v", step.name, available, known, step.wantAvailable, step.wantKnown)
+		}
+
+		if got := step.requests.Load(); got != step.wantRequests {
+			t.Errorf("%s: requests = %d, want %d", step.name, got, step.wantRequests)
+		}
+	}
+}
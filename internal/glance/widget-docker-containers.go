@@ -2,55 +2,492 @@ package glance
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 )
 
-var dockerContainersWidgetTemplate = mustParseTemplate("docker-containers.html", "widget-base.html")
+var dockerContainersWidgetTemplate = mustParseTemplate("docker-containers.html", "widget-base.html", "state-icon.html")
 
 type dockerContainersWidget struct {
 	widgetBase           `yaml:",inline"`
-	HideByDefault        bool                         `yaml:"hide-by-default"`
-	RunningOnly          bool                         `yaml:"running-only"`
-	Category             string                       `yaml:"category"`
-	SockPath             string                       `yaml:"sock-path"`
-	FormatContainerNames bool                         `yaml:"format-container-names"`
-	Containers           dockerContainerList          `yaml:"-"`
-	LabelOverrides       map[string]map[string]string `yaml:"containers"`
+	HideByDefault        bool                                  `yaml:"hide-by-default"`
+	RunningOnly          bool                                  `yaml:"running-only"`
+	Category             string                                `yaml:"category"`
+	SockPath             string                                `yaml:"sock-path"`
+	Mode                 string                                `yaml:"mode"`
+	Namespace            string                                `yaml:"namespace"`
+	GroupBy              string                                `yaml:"group-by"`
+	Sources              []*dockerSource                       `yaml:"sources"`
+	FormatContainerNames bool                                  `yaml:"format-container-names"`
+	Hosts                []dockerContainerHost                 `yaml:"-"`
+	LabelOverrides       map[string]map[string]string          `yaml:"containers"`
+	Notify               *notificationRule                     `yaml:"notify"`
+	Maintenance          maintenanceWindows                    `yaml:"maintenance"`
+	Actions              []string                              `yaml:"actions"`
+	ShowLogs             bool                                  `yaml:"show-logs"`
+	LogLines             int                                   `yaml:"log-lines"`
+	ShowStats            bool                                  `yaml:"show-stats"`
+	CheckImageUpdates    bool                                  `yaml:"check-image-updates"`
+	Registries           map[string]*dockerRegistryCredentials `yaml:"registries"`
+	notificationTimer    notificationCheckTimer
+	stats                dockerContainerStatsCollector
 }
 
+var dockerContainerActions = []string{"start", "stop", "restart"}
+
 func (widget *dockerContainersWidget) initialize() error {
 	widget.withTitle("Docker Containers").withCacheDuration(1 * time.Minute)
 
-	if widget.SockPath == "" {
-		widget.SockPath = "/var/run/docker.sock"
+	if widget.GroupBy != "" && widget.GroupBy != "project" {
+		return fmt.Errorf("unsupported group-by value %s, must be project", widget.GroupBy)
 	}
 
-	return nil
+	if len(widget.Sources) == 0 {
+		widget.Sources = []*dockerSource{{SockPath: widget.SockPath, Mode: widget.Mode, Namespace: widget.Namespace}}
+	} else if widget.SockPath != "" {
+		return errors.New("sock-path can't be used together with sources, specify it for each source instead")
+	}
+
+	names := make(map[string]bool, len(widget.Sources))
+
+	for _, source := range widget.Sources {
+		if len(widget.Sources) > 1 {
+			if source.Name == "" {
+				return errors.New("name is required for each source when there's more than one")
+			}
+
+			if names[source.Name] {
+				return fmt.Errorf("source name %s is used more than once", source.Name)
+			}
+			names[source.Name] = true
+		}
+
+		// the overrides for the widget apply to every source, with the ones for the source taking precedence
+		overrides := make(map[string]map[string]string, len(widget.LabelOverrides)+len(source.LabelOverrides))
+		for _, from := range []map[string]map[string]string{widget.LabelOverrides, source.LabelOverrides} {
+			for container, labels := range from {
+				if overrides[container] == nil {
+					overrides[container] = make(map[string]string, len(labels))
+				}
+
+				for label, value := range labels {
+					overrides[container][label] = value
+				}
+			}
+		}
+		source.LabelOverrides = overrides
+
+		if source.Mode == "" {
+			source.Mode = widget.Mode
+		}
+
+		if source.Namespace == "" {
+			source.Namespace = widget.Namespace
+		}
+
+		if err := source.initialize(); err != nil {
+			return ternary(source.Name == "", err, fmt.Errorf("source %s: %w", source.Name, err))
+		}
+	}
+
+	// keyed the same way as image references so that they can be looked up directly
+	registries := make(map[string]*dockerRegistryCredentials, len(widget.Registries))
+	for registry, credentials := range widget.Registries {
+		ref, err := parseDockerImageReference(strings.ToLower(registry) + "/image")
+		if err != nil || (ref.Repository != "image" && ref.Repository != "library/image") {
+			return fmt.Errorf("invalid registry %s", registry)
+		}
+
+		registries[ref.Registry] = credentials
+	}
+	widget.Registries = registries
+
+	if err := widget.Maintenance.initialize(); err != nil {
+		return err
+	}
+
+	for i := range widget.Actions {
+		widget.Actions[i] = strings.ToLower(widget.Actions[i])
+		if !slices.Contains(dockerContainerActions, widget.Actions[i]) {
+			return fmt.Errorf("unsupported action %s, must be one of %s", widget.Actions[i], strings.Join(dockerContainerActions, ", "))
+		}
+	}
+
+	if widget.LogLines == 0 {
+		widget.LogLines = dockerContainerLogsDefaultLines
+	} else if widget.LogLines < 0 || widget.LogLines > dockerContainerLogsMaxLines {
+		return fmt.Errorf("log-lines must be between 1 and %d", dockerContainerLogsMaxLines)
+	}
+
+	return widget.Notify.initialize(1)
 }
 
 func (widget *dockerContainersWidget) update(ctx context.Context) {
-	containers, err := fetchDockerContainers(
-		widget.SockPath,
-		widget.HideByDefault,
-		widget.Category,
-		widget.RunningOnly,
-		widget.FormatContainerNames,
-		widget.LabelOverrides,
-	)
+	hosts, err := widget.fetchHosts()
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
 	}
 
-	containers.sortByStateIconThenName()
-	widget.Containers = containers
+	for i := range hosts {
+		widget.markContainersInMaintenance(hosts[i].Containers)
+		widget.notifyOnStateChanges(hosts[i].Containers)
+	}
+
+	if widget.ShowStats {
+		widget.applyContainerStats(hosts)
+	}
+
+	if widget.CheckImageUpdates {
+		widget.markImageUpdates(hosts)
+	}
+
+	for i := range hosts {
+		hosts[i].Containers.sortByStateIconThenName()
+		hosts[i].Groups = widget.groupContainers(hosts[i].Containers)
+	}
+
+	widget.Hosts = hosts
+}
+
+type dockerContainerHost struct {
+	Name       string
+	Error      error
+	Containers dockerContainerList
+	Groups     []dockerContainerGroup
+}
+
+type dockerContainerGroup struct {
+	// Empty for the containers that don't belong to a group, or when grouping is disabled
+	Name       string
+	StateIcon  string
+	Containers dockerContainerList
+}
+
+// Groups by compose project or swarm stack, with the containers that aren't part
+// of one coming last. The containers are expected to already be sorted.
+func (widget *dockerContainersWidget) groupContainers(containers dockerContainerList) []dockerContainerGroup {
+	if widget.GroupBy == "" {
+		return []dockerContainerGroup{{Containers: containers}}
+	}
+
+	indexByName := make(map[string]int)
+	groups := make([]dockerContainerGroup, 0)
+	var ungrouped dockerContainerList
+
+	for i := range containers {
+		project := containers[i].project
+		if project == "" {
+			ungrouped = append(ungrouped, containers[i])
+			continue
+		}
+
+		index, exists := indexByName[project]
+		if !exists {
+			index = len(groups)
+			indexByName[project] = index
+			groups = append(groups, dockerContainerGroup{Name: project})
+		}
+
+		groups[index].Containers = append(groups[index].Containers, containers[i])
+	}
+
+	p := dockerContainerStateIconPriorities
+
+	for i := range groups {
+		// the containers are sorted by state first, so the first one has the state that needs the most attention
+		groups[i].StateIcon = groups[i].Containers[0].StateIcon
+	}
+
+	sort.SliceStable(groups, func(a, b int) bool {
+		if p[groups[a].StateIcon] != p[groups[b].StateIcon] {
+			return p[groups[a].StateIcon] < p[groups[b].StateIcon]
+		}
+
+		return strings.ToLower(groups[a].Name) < strings.ToLower(groups[b].Name)
+	})
+
+	if len(ungrouped) > 0 || len(groups) == 0 {
+		groups = append(groups, dockerContainerGroup{Containers: ungrouped})
+	}
+
+	return groups
+}
+
+// Hosts which couldn't be reached are still included along with their error, if only some
+// of them couldn't be reached the returned error wraps errPartialContent
+func (widget *dockerContainersWidget) fetchHosts() ([]dockerContainerHost, error) {
+	job := newJob(func(source *dockerSource) (dockerContainerList, error) {
+		return fetchDockerContainers(
+			source,
+			widget.HideByDefault,
+			widget.Category,
+			widget.RunningOnly,
+			widget.FormatContainerNames,
+		)
+	}, widget.Sources).withWorkers(len(widget.Sources))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]dockerContainerHost, len(widget.Sources))
+	failed := 0
+
+	for i, source := range widget.Sources {
+		hosts[i] = dockerContainerHost{Name: source.Name, Error: errs[i], Containers: results[i]}
+
+		if errs[i] != nil {
+			failed++
+			continue
+		}
+
+		for c := range hosts[i].Containers {
+			widget.setAvailableActions(&hosts[i].Containers[c])
+		}
+	}
+
+	if failed == len(hosts) {
+		if len(hosts) == 1 {
+			return nil, errs[0]
+		}
+
+		return nil, fmt.Errorf("could not reach any of the %d hosts", len(hosts))
+	}
+
+	if failed > 0 {
+		return hosts, fmt.Errorf("%w: could not reach %d of %d hosts", errPartialContent, failed, len(hosts))
+	}
+
+	return hosts, nil
+}
+
+// Returns the containers of all hosts which could be reached, the error is the same as the one from fetchHosts
+func (widget *dockerContainersWidget) fetchContainers() (dockerContainerList, error) {
+	hosts, err := widget.fetchHosts()
+	if err != nil && !errors.Is(err, errPartialContent) {
+		return nil, err
+	}
+
+	containers := make(dockerContainerList, 0)
+	for i := range hosts {
+		containers = append(containers, hosts[i].Containers...)
+	}
+
+	return containers, err
+}
+
+func (widget *dockerContainersWidget) setAvailableActions(container *dockerContainer) {
+	if !container.source.hasContainerAPI() {
+		return
+	}
+
+	container.LogsAvailable = widget.logsAllowed(container)
+
+	isRunning := container.State == "running" || container.State == "paused"
+
+	// only the actions that make sense for the current state are shown,
+	// though any of the allowed ones can still be requested
+	for _, action := range widget.allowedActions(container) {
+		if (action == "start" && isRunning) || (action == "stop" && !isRunning) {
+			continue
+		}
+
+		container.Actions = append(container.Actions, action)
+	}
+}
+
+// The glance.actions label can only narrow down the actions enabled
+// for the widget, it can't enable ones that weren't enabled there
+func (widget *dockerContainersWidget) allowedActions(container *dockerContainer) []string {
+	if container.actionsLabel == "" {
+		return widget.Actions
+	}
+
+	allowed := make([]string, 0, len(widget.Actions))
+	for _, action := range strings.Split(container.actionsLabel, ",") {
+		action = strings.ToLower(strings.TrimSpace(action))
+		if slices.Contains(widget.Actions, action) && !slices.Contains(allowed, action) {
+			allowed = append(allowed, action)
+		}
+	}
+
+	return allowed
+}
+
+// Logs can contain sensitive information, so they have to be enabled
+// for the widget and then explicitly allowed for each container
+func (widget *dockerContainersWidget) logsAllowed(container *dockerContainer) bool {
+	return widget.ShowLogs && container.logsLabel
+}
+
+func (widget *dockerContainersWidget) hasActions() bool {
+	return len(widget.Actions) > 0 || widget.ShowLogs
+}
+
+// Handles POST requests to containers/{id}/{action} and GET requests to containers/{id}/logs
+func (widget *dockerContainersWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.PathValue("path"), "/")
+	if len(parts) != 3 || parts[0] != "containers" {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	containerID, action := parts[1], parts[2]
+	isLogs := action == "logs"
+
+	if (isLogs && r.Method != http.MethodGet) || (!isLogs && r.Method != http.MethodPost) {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Containers are fetched again rather than using the ones from the last update since
+	// those are used for rendering and may be replaced while this request is handled
+	containers, err := widget.fetchContainers()
+	if err != nil && !errors.Is(err, errPartialContent) {
+		slog.Error("Failed to fetch containers for action", "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": "could not fetch containers"})
+		return
+	}
+
+	index := slices.IndexFunc(containers, func(c dockerContainer) bool { return c.ID == containerID })
+	if index == -1 {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "container not found"})
+		return
+	}
+
+	container := &containers[index]
+
+	if isLogs {
+		widget.handleLogsRequest(w, container)
+		return
+	}
+
+	if !container.source.hasContainerAPI() || !slices.Contains(widget.allowedActions(container), action) {
+		writeJSONResponse(w, http.StatusForbidden, map[string]string{"error": "action is not allowed for this container"})
+		return
+	}
+
+	if err := performDockerContainerAction(container.source, containerID, action); err != nil {
+		slog.Error("Failed to perform container action", "container", container.Name, "action", action, "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Performed container action", "container", container.Name, "action", action)
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (widget *dockerContainersWidget) handleLogsRequest(w http.ResponseWriter, container *dockerContainer) {
+	if !container.source.hasContainerAPI() || !widget.logsAllowed(container) {
+		writeJSONResponse(w, http.StatusForbidden, map[string]string{"error": "logs are not allowed for this container"})
+		return
+	}
+
+	lines, err := fetchDockerContainerLogs(container.source, container.ID, widget.LogLines)
+	if err != nil {
+		slog.Error("Failed to fetch container logs", "container", container.Name, "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]any{"lines": lines})
+}
+
+func (widget *dockerContainersWidget) notificationRule() *notificationRule {
+	return widget.Notify
+}
+
+func (widget *dockerContainersWidget) runInBackground(ctx context.Context, app *application) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	runNotificationChecks(ctx, app, widget.cacheDuration, &widget.notificationTimer, func() {
+		containers, err := widget.fetchContainers()
+		if err != nil && !errors.Is(err, errPartialContent) {
+			slog.Error("Failed to check docker containers for notifications", "error", err)
+			return
+		}
+
+		widget.markContainersInMaintenance(containers)
+		widget.notifyOnStateChanges(containers)
+	})
+}
+
+func (widget *dockerContainersWidget) markContainersInMaintenance(containers dockerContainerList) {
+	now := time.Now()
+
+	for i := range containers {
+		container := &containers[i]
+
+		for c := range container.Children {
+			if widget.Maintenance.covers(container.Children[c].Name, now) {
+				container.Children[c].StateIcon = dockerContainerStateIconMaintenance
+			}
+		}
+
+		if widget.Maintenance.covers(container.Name, now) {
+			container.StateIcon = dockerContainerStateIconMaintenance
+		}
+	}
+}
+
+func (widget *dockerContainersWidget) notifyOnStateChanges(containers dockerContainerList) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	widget.notificationTimer.markChecked()
+
+	for i := range containers {
+		widget.notifyOnStateChange(&containers[i])
+
+		for c := range containers[i].Children {
+			widget.notifyOnStateChange(&containers[i].Children[c])
+		}
+	}
+}
+
+func (widget *dockerContainersWidget) notifyOnStateChange(container *dockerContainer) {
+	// the state during maintenance is expected, so it's neither notified about nor compared against
+	if container.StateIcon == dockerContainerStateIconMaintenance {
+		return
+	}
+
+	state := container.State
+	if strings.Contains(strings.ToLower(container.StateText), "(unhealthy)") {
+		state = "unhealthy"
+	}
+
+	key := "docker:" + container.source.SockPath + ":" + container.Name
+	previous, changed := notificationStates.observe(key, state, widget.Notify.After)
+	if !changed {
+		return
+	}
+
+	widget.Notify.send(notification{
+		key:     key,
+		Event:   state,
+		Widget:  widget.Title,
+		Title:   container.Name + " is " + state,
+		Message: fmt.Sprintf("Container %s changed from %s to %s", container.Name, previous, state),
+		URL:     container.URL,
+	})
 }
 
 func (widget *dockerContainersWidget) Render() template.HTML {
@@ -58,15 +495,18 @@ func (widget *dockerContainersWidget) Render() template.HTML {
 }
 
 const (
-	dockerContainerLabelHide        = "glance.hide"
-	dockerContainerLabelName        = "glance.name"
-	dockerContainerLabelURL         = "glance.url"
-	dockerContainerLabelDescription = "glance.description"
-	dockerContainerLabelSameTab     = "glance.same-tab"
-	dockerContainerLabelIcon        = "glance.icon"
-	dockerContainerLabelID          = "glance.id"
-	dockerContainerLabelParent      = "glance.parent"
-	dockerContainerLabelCategory    = "glance.category"
+	dockerContainerLabelHide         = "glance.hide"
+	dockerContainerLabelName         = "glance.name"
+	dockerContainerLabelURL          = "glance.url"
+	dockerContainerLabelDescription  = "glance.description"
+	dockerContainerLabelSameTab      = "glance.same-tab"
+	dockerContainerLabelIcon         = "glance.icon"
+	dockerContainerLabelID           = "glance.id"
+	dockerContainerLabelParent       = "glance.parent"
+	dockerContainerLabelCategory     = "glance.category"
+	dockerContainerLabelActions      = "glance.actions"
+	dockerContainerLabelImageUpdates = "glance.check-image-updates"
+	dockerContainerLabelLogs         = "glance.logs"
 )
 
 const (
@@ -74,21 +514,26 @@ const (
 	dockerContainerStateIconPaused = "paused"
 	dockerContainerStateIconWarn   = "warn"
 	dockerContainerStateIconOther  = "other"
+	// Containers within a maintenance window, regardless of their actual state
+	dockerContainerStateIconMaintenance = "maintenance"
 )
 
 var dockerContainerStateIconPriorities = map[string]int{
-	dockerContainerStateIconWarn:   0,
-	dockerContainerStateIconOther:  1,
-	dockerContainerStateIconPaused: 2,
-	dockerContainerStateIconOK:     3,
+	dockerContainerStateIconWarn:        0,
+	dockerContainerStateIconOther:       1,
+	dockerContainerStateIconPaused:      2,
+	dockerContainerStateIconMaintenance: 2,
+	dockerContainerStateIconOK:          3,
 }
 
 type dockerContainerJsonResponse struct {
-	Names  []string              `json:"Names"`
-	Image  string                `json:"Image"`
-	State  string                `json:"State"`
-	Status string                `json:"Status"`
-	Labels dockerContainerLabels `json:"Labels"`
+	ID      string                `json:"Id"`
+	ImageID string                `json:"ImageID"`
+	Names   []string              `json:"Names"`
+	Image   string                `json:"Image"`
+	State   string                `json:"State"`
+	Status  string                `json:"Status"`
+	Labels  dockerContainerLabels `json:"Labels"`
 }
 
 type dockerContainerLabels map[string]string
@@ -111,6 +556,7 @@ func (l *dockerContainerLabels) getOrDefault(label, def string) string {
 }
 
 type dockerContainer struct {
+	ID          string
 	Name        string
 	URL         string
 	SameTab     bool
@@ -121,6 +567,19 @@ type dockerContainer struct {
 	Description string
 	Icon        customIconField
 	Children    dockerContainerList
+	// The actions which can be performed given the container's current state
+	Actions       []string
+	LogsAvailable bool
+	Stats         *dockerContainerStats
+	// Only set when checking for image updates is enabled and the result is known
+	ImageUpdateAvailable bool
+	actionsLabel         string
+	logsLabel            bool
+	source               *dockerSource
+	imageID              string
+	checkImageUpdates    bool
+	// The compose project or swarm stack the container belongs to
+	project string
 }
 
 type dockerContainerList []dockerContainer
@@ -147,7 +606,7 @@ func dockerContainerStateToStateIcon(container *dockerContainerJsonResponse) str
 		return dockerContainerStateIconOK
 	case "paused":
 		return dockerContainerStateIconPaused
-	case "exited", "dead":
+	case "exited", "dead", "down", "degraded":
 		return dockerContainerStateIconWarn
 	default:
 		return dockerContainerStateIconOther
@@ -155,14 +614,13 @@ func dockerContainerStateToStateIcon(container *dockerContainerJsonResponse) str
 }
 
 func fetchDockerContainers(
-	socketPath string,
+	source *dockerSource,
 	hideByDefault bool,
 	category string,
 	runningOnly bool,
 	formatNames bool,
-	labelOverrides map[string]map[string]string,
 ) (dockerContainerList, error) {
-	containers, err := fetchDockerContainersFromSource(socketPath, category, runningOnly, labelOverrides)
+	containers, err := fetchDockerContainersFromSource(source, category, runningOnly)
 	if err != nil {
 		return nil, fmt.Errorf("fetching containers: %w", err)
 	}
@@ -174,14 +632,24 @@ func fetchDockerContainers(
 		container := &containers[i]
 
 		dc := dockerContainer{
-			Name:        deriveDockerContainerName(container, formatNames),
-			URL:         container.Labels.getOrDefault(dockerContainerLabelURL, ""),
-			Description: container.Labels.getOrDefault(dockerContainerLabelDescription, ""),
-			SameTab:     stringToBool(container.Labels.getOrDefault(dockerContainerLabelSameTab, "false")),
-			Image:       container.Image,
-			State:       strings.ToLower(container.State),
-			StateText:   strings.ToLower(container.Status),
-			Icon:        newCustomIconField(container.Labels.getOrDefault(dockerContainerLabelIcon, "si:docker")),
+			ID:           container.ID,
+			Name:         deriveDockerContainerName(container, formatNames),
+			URL:          container.Labels.getOrDefault(dockerContainerLabelURL, ""),
+			Description:  container.Labels.getOrDefault(dockerContainerLabelDescription, ""),
+			SameTab:      stringToBool(container.Labels.getOrDefault(dockerContainerLabelSameTab, "false")),
+			Image:        container.Image,
+			State:        strings.ToLower(container.State),
+			StateText:    strings.ToLower(container.Status),
+			Icon:         newCustomIconField(container.Labels.getOrDefault(dockerContainerLabelIcon, "si:docker")),
+			actionsLabel: container.Labels.getOrDefault(dockerContainerLabelActions, ""),
+			logsLabel:    stringToBool(container.Labels.getOrDefault(dockerContainerLabelLogs, "false")),
+			source:       source,
+			imageID:      container.ImageID,
+			project: container.Labels.getOrDefault(dockerLabelComposeProject,
+				container.Labels.getOrDefault(dockerLabelStackNamespace, "")),
+			// only running containers are checked since stopped ones would use the new image once started anyway
+			checkImageUpdates: strings.EqualFold(container.State, "running") &&
+				stringToBool(container.Labels.getOrDefault(dockerContainerLabelImageUpdates, "true")),
 		}
 
 		if idValue := container.Labels.getOrDefault(dockerContainerLabelID, ""); idValue != "" {
@@ -190,6 +658,8 @@ func fetchDockerContainers(
 					child := &children[i]
 					dc.Children = append(dc.Children, dockerContainer{
 						Name:      deriveDockerContainerName(child, formatNames),
+						source:    source,
+						State:     strings.ToLower(child.State),
 						StateText: child.Status,
 						StateIcon: dockerContainerStateToStateIcon(child),
 					})
@@ -283,71 +753,30 @@ func isDockerContainerHidden(container *dockerContainerJsonResponse, hideByDefau
 }
 
 func fetchDockerContainersFromSource(
-	source string,
+	source *dockerSource,
 	category string,
 	runningOnly bool,
-	labelOverrides map[string]map[string]string,
 ) ([]dockerContainerJsonResponse, error) {
-	var hostname string
-	var scheme string
-
-	var client *http.Client
-	if strings.HasPrefix(source, "tcp://") || strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
-		client = &http.Client{}
-		parsed, err := url.Parse(source)
-		if err != nil {
-			return nil, fmt.Errorf("parsing URL: %w", err)
-		}
-
-		scheme = parsed.Scheme
-		if scheme == "tcp" {
-			scheme = "http"
-		}
-
-		port := parsed.Port()
-		if port == "" {
-			if scheme == "https" {
-				port = "443"
-			} else {
-				port = "80"
-			}
-		}
-
-		hostname = parsed.Hostname() + ":" + port
-	} else {
-		scheme = "http"
-		hostname = "docker"
-		client = &http.Client{
-			Transport: &http.Transport{
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial("unix", source)
-				},
-			},
-		}
-	}
-
-	fetchAll := ternary(runningOnly, "false", "true")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	var containers []dockerContainerJsonResponse
+	var err error
+
+	switch source.Mode {
+	case dockerSourceModeSwarm:
+		containers, err = fetchDockerSwarmServices(source, runningOnly)
+	case dockerSourceModePodman:
+		containers, err = fetchPodmanContainers(source, runningOnly)
+	case dockerSourceModeNerdctl:
+		containers, err = fetchNerdctlContainers(source, runningOnly)
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	request, err := http.NewRequestWithContext(ctx, "GET", scheme+"://"+hostname+"/containers/json?all="+fetchAll, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		fetchAll := ternary(runningOnly, "false", "true")
+		containers, err = fetchDockerAPI[[]dockerContainerJsonResponse](ctx, source, "/containers/json?all="+fetchAll)
 	}
 
-	response, err := client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("sending request to socket: %w", err)
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("non-200 response status: %s", response.Status)
-	}
-
-	var containers []dockerContainerJsonResponse
-	if err := json.NewDecoder(response.Body).Decode(&containers); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+		return nil, err
 	}
 
 	for i := range containers {
@@ -358,7 +787,7 @@ func fetchDockerContainersFromSource(
 			continue
 		}
 
-		overrides, ok := labelOverrides[name]
+		overrides, ok := source.LabelOverrides[name]
 		if !ok {
 			continue
 		}
@@ -390,3 +819,187 @@ func fetchDockerContainersFromSource(
 
 	return containers, nil
 }
+
+const (
+	dockerSourceModeContainers = "containers"
+	dockerSourceModeSwarm      = "swarm"
+	dockerSourceModePodman     = "podman"
+	dockerSourceModeNerdctl    = "nerdctl"
+)
+
+type dockerSource struct {
+	Name           string                       `yaml:"name"`
+	SockPath       string                       `yaml:"sock-path"`
+	Mode           string                       `yaml:"mode"`
+	Namespace      string                       `yaml:"namespace"`
+	TLSCert        string                       `yaml:"tls-cert"`
+	TLSKey         string                       `yaml:"tls-key"`
+	TLSCA          string                       `yaml:"tls-ca"`
+	AllowInsecure  bool                         `yaml:"allow-insecure"`
+	LabelOverrides map[string]map[string]string `yaml:"containers"`
+
+	client  *http.Client
+	baseURL string
+}
+
+// The sock path can either be the path to a unix socket or a tcp://, http:// or https:// URL,
+// the base URL for unix sockets uses a placeholder host since requests are sent to the socket
+func (source *dockerSource) initialize() error {
+	if source.SockPath == "" {
+		source.SockPath = ternary(source.Mode == dockerSourceModeNerdctl, defaultContainerdSockPath, detectDockerSockPath())
+	}
+
+	switch source.Mode {
+	case "":
+		// Podman's API is compatible with Docker's, but pods can only be listed through its own API
+		source.Mode = ternary(strings.HasSuffix(source.SockPath, "podman.sock"), dockerSourceModePodman, dockerSourceModeContainers)
+	case dockerSourceModeContainers, dockerSourceModeSwarm, dockerSourceModePodman, dockerSourceModeNerdctl:
+	default:
+		return fmt.Errorf("unsupported mode %s, must be one of containers, swarm, podman or nerdctl", source.Mode)
+	}
+
+	usesTLS := source.TLSCert != "" || source.TLSKey != "" || source.TLSCA != "" || source.AllowInsecure
+
+	if source.Mode == dockerSourceModeNerdctl {
+		if usesTLS {
+			return errors.New("TLS options can't be used with the nerdctl mode")
+		}
+
+		if source.Namespace == "" {
+			source.Namespace = "default"
+		}
+
+		if _, err := exec.LookPath("nerdctl"); err != nil {
+			return errors.New("the nerdctl mode requires the nerdctl binary, which could not be found in the PATH")
+		}
+
+		return nil
+	}
+
+	if !strings.HasPrefix(source.SockPath, "tcp://") && !strings.HasPrefix(source.SockPath, "http://") && !strings.HasPrefix(source.SockPath, "https://") {
+		if usesTLS {
+			return errors.New("TLS options can only be used with tcp:// and https:// sources")
+		}
+
+		socketPath := source.SockPath
+		source.baseURL = "http://docker"
+		source.client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			},
+		}
+
+		return nil
+	}
+
+	parsed, err := url.Parse(source.SockPath)
+	if err != nil {
+		return fmt.Errorf("parsing URL: %w", err)
+	}
+
+	scheme := parsed.Scheme
+	if scheme == "tcp" {
+		// same as the Docker CLI, which uses TLS for tcp:// hosts when it's been configured
+		scheme = ternary(usesTLS, "https", "http")
+	}
+
+	if scheme == "http" && usesTLS {
+		return errors.New("TLS options can't be used with http:// sources")
+	}
+
+	port := parsed.Port()
+	if port == "" {
+		if scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+
+	source.baseURL = scheme + "://" + parsed.Hostname() + ":" + port
+	source.client = &http.Client{}
+
+	if scheme == "https" {
+		tlsConfig, err := source.tlsConfig()
+		if err != nil {
+			return err
+		}
+
+		source.client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	return nil
+}
+
+// Whether the source has an API through which containers can be managed and inspected,
+// which is used for actions, stats and checking for image updates
+func (source *dockerSource) hasContainerAPI() bool {
+	return source.Mode == dockerSourceModeContainers || source.Mode == dockerSourceModePodman
+}
+
+func (source *dockerSource) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: source.AllowInsecure}
+
+	if source.TLSCert != "" || source.TLSKey != "" {
+		if source.TLSCert == "" || source.TLSKey == "" {
+			return nil, errors.New("both tls-cert and tls-key are required when using a client certificate")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(source.TLSCert, source.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if source.TLSCA != "" {
+		contents, err := os.ReadFile(source.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading tls-ca: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents) {
+			return nil, errors.New("tls-ca does not contain any valid certificates")
+		}
+
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+func performDockerContainerAction(source *dockerSource, containerID string, action string) error {
+	// stopping and restarting waits for the container to exit, which by default can take up to 10 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", source.baseURL+"/containers/"+url.PathEscape(containerID)+"/"+action, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := source.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("sending request to socket: %w", err)
+	}
+	defer response.Body.Close()
+
+	// 304 is returned when the container is already started or stopped
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusNotModified {
+		var body struct {
+			Message string `json:"message"`
+		}
+
+		if json.NewDecoder(response.Body).Decode(&body) == nil && body.Message != "" {
+			return fmt.Errorf("%s: %s", response.Status, body.Message)
+		}
+
+		return fmt.Errorf("unexpected response status: %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package glance
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Serves the given containers from the list endpoint of Docker's API and records
+// the actions performed on them as "id/action"
+func startFakeDockerAPI(t *testing.T, containers []dockerContainerJsonResponse) (string, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var actions []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/containers/"), "/")
+
+		switch {
+		case r.URL.Path == "/containers/json":
+			json.NewEncoder(w).Encode(containers)
+		case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "logs":
+			w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+			w.Write([]byte("started\n"))
+		case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "stats":
+			w.Write([]byte(`{
+				"cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 2000, "online_cpus": 2},
+				"precpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 1000},
+				"memory_stats": {"usage": 104857600, "limit": 1073741824}
+			}`))
+		case r.Method == http.MethodPost && len(parts) == 2:
+			mu.Lock()
+			actions = append(actions, parts[0]+"/"+parts[1])
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), actions...)
+	}
+}
+
+func TestDockerContainersHandleRequest(t *testing.T) {
+	url, performedActions := startFakeDockerAPI(t, []dockerContainerJsonResponse{
+		{ID: "web", Names: []string{"/web"}, State: "running", Labels: dockerContainerLabels{"glance.logs": "true"}},
+		{ID: "db", Names: []string{"/db"}, State: "running", Labels: dockerContainerLabels{"glance.actions": "restart"}},
+	})
+
+	widget := &dockerContainersWidget{SockPath: url, Actions: []string{"start", "stop", "restart"}, ShowLogs: true}
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAction string
+	}{
+		{"restart", http.MethodPost, "containers/web/restart", http.StatusOK, "web/restart"},
+		{"stop", http.MethodPost, "containers/web/stop", http.StatusOK, "web/stop"},
+		{"action narrowed down by label", http.MethodPost, "containers/db/stop", http.StatusForbidden, ""},
+		{"action allowed by label", http.MethodPost, "containers/db/restart", http.StatusOK, "db/restart"},
+		{"unsupported action", http.MethodPost, "containers/web/remove", http.StatusForbidden, ""},
+		{"unknown container", http.MethodPost, "containers/cache/restart", http.StatusNotFound, ""},
+		{"action with get", http.MethodGet, "containers/web/restart", http.StatusMethodNotAllowed, ""},
+		{"logs", http.MethodGet, "containers/web/logs", http.StatusOK, ""},
+		{"logs with post", http.MethodPost, "containers/web/logs", http.StatusMethodNotAllowed, ""},
+		{"logs not allowed by label", http.MethodGet, "containers/db/logs", http.StatusForbidden, ""},
+		{"missing action", http.MethodPost, "containers/web", http.StatusNotFound, ""},
+		{"extra segment", http.MethodPost, "containers/web/restart/now", http.StatusNotFound, ""},
+		{"other resource", http.MethodPost, "images/web/restart", http.StatusNotFound, ""},
+		{"empty path", http.MethodPost, "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(performedActions())
+
+			request := httptest.NewRequest(tt.method, "/api/widgets/1/"+tt.path, nil)
+			request.SetPathValue("path", tt.path)
+			recorder := httptest.NewRecorder()
+
+			widget.handleRequest(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+
+			actions := performedActions()[before:]
+			if tt.wantAction == "" && len(actions) > 0 {
+				t.Errorf("performed %v, want no actions", actions)
+			} else if tt.wantAction != "" && (len(actions) != 1 || actions[0] != tt.wantAction) {
+				t.Errorf("performed %v, want %s", actions, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestDockerContainersFetchHosts(t *testing.T) {
+	reachable, _ := startFakeDockerAPI(t, []dockerContainerJsonResponse{
+		{ID: "web", Names: []string{"/web"}, State: "running"},
+	})
+	unreachable := "http://127.0.0.1:1"
+
+	tests := []struct {
+		name         string
+		sockPaths    []string
+		wantHosts    int
+		wantPartial  bool
+		wantErr      string
+		wantFailures []bool
+	}{
+		{"single reachable host", []string{reachable}, 1, false, "", []bool{false}},
+		{"single unreachable host", []string{unreachable}, 0, false, "fetching containers", nil},
+		{"some hosts unreachable", []string{reachable, unreachable, reachable}, 3, true, "could not reach 1 of 3 hosts", []bool{false, true, false}},
+		{"all hosts unreachable", []string{unreachable, unreachable}, 0, false, "could not reach any of the 2 hosts", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			widget := &dockerContainersWidget{}
+			for i, sockPath := range tt.sockPaths {
+				widget.Sources = append(widget.Sources, &dockerSource{Name: "host-" + strconv.Itoa(i), SockPath: sockPath})
+			}
+
+			if err := widget.initialize(); err != nil {
+				t.Fatalf("initializing widget: %v", err)
+			}
+
+			hosts, err := widget.fetchHosts()
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("fetchHosts() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("fetchHosts() error = %v, want one containing %q", err, tt.wantErr)
+			}
+
+			if errors.Is(err, errPartialContent) != tt.wantPartial {
+				t.Errorf("fetchHosts() error = %v, want partial content %v", err, tt.wantPartial)
+			}
+
+			if len(hosts) != tt.wantHosts {
+				t.Fatalf("got %d hosts, want %d", len(hosts), tt.wantHosts)
+			}
+
+			for i, host := range hosts {
+				if host.Name != widget.Sources[i].Name {
+					t.Errorf("host %d name = %s, want %s", i, host.Name, widget.Sources[i].Name)
+				}
+
+				if (host.Error != nil) != tt.wantFailures[i] {
+					t.Errorf("host %d error = %v, want failure %v", i, host.Error, tt.wantFailures[i])
+				}
+
+				if !tt.wantFailures[i] && len(host.Containers) != 1 {
+					t.Errorf("host %d containers = %v, want the one from the fake API", i, host.Containers)
+				}
+			}
+		})
+	}
+}
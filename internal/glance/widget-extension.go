@@ -22,7 +22,7 @@ type extensionWidget struct {
 	URL                 string               `yaml:"url"`
 	FallbackContentType string               `yaml:"fallback-content-type"`
 	Parameters          queryParametersField `yaml:"parameters"`
-	Headers             map[string]string    `yaml:"headers"`
+	Headers             map[string]string    `yaml:"headers" secret:"true"`
 	AllowHtml           bool                 `yaml:"allow-potentially-dangerous-html"`
 	Extension           extension            `yaml:"-"`
 	cachedHTML          template.HTML        `yaml:"-"`
@@ -92,7 +92,7 @@ type extensionRequestOptions struct {
 	URL                 string               `yaml:"url"`
 	FallbackContentType string               `yaml:"fallback-content-type"`
 	Parameters          queryParametersField `yaml:"parameters"`
-	Headers             map[string]string    `yaml:"headers"`
+	Headers             map[string]string    `yaml:"headers" secret:"true"`
 	AllowHtml           bool                 `yaml:"allow-potentially-dangerous-html"`
 }
 
@@ -0,0 +1,418 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	homeAssistantPingInterval  = 30 * time.Second
+	homeAssistantReadTimeout   = 90 * time.Second
+	homeAssistantMinRetryDelay = 5 * time.Second
+	homeAssistantMaxRetryDelay = 2 * time.Minute
+)
+
+// Message IDs of the commands sent after authenticating, pings use IDs after these
+const (
+	homeAssistantMsgAreas = iota + 1
+	homeAssistantMsgDevices
+	homeAssistantMsgEntities
+	homeAssistantMsgStates
+	homeAssistantMsgSubscribe
+)
+
+var errHomeAssistantNotConnected = errors.New("not connected to Home Assistant yet")
+
+type homeAssistantState struct {
+	EntityID    string         `json:"entity_id"`
+	State       string         `json:"state"`
+	Attributes  map[string]any `json:"attributes"`
+	LastChanged time.Time      `json:"last_changed"`
+}
+
+type homeAssistantMessage struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Event *struct {
+		Data struct {
+			EntityID string              `json:"entity_id"`
+			NewState *homeAssistantState `json:"new_state"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+type homeAssistantRegistries struct {
+	areas []struct {
+		AreaID string `json:"area_id"`
+		Name   string `json:"name"`
+	}
+	devices []struct {
+		ID     string `json:"id"`
+		AreaID string `json:"area_id"`
+	}
+	entities []struct {
+		EntityID string `json:"entity_id"`
+		DeviceID string `json:"device_id"`
+		AreaID   string `json:"area_id"`
+	}
+	received int
+}
+
+// Keeps an up to date copy of the states of all entities by listening for changes over
+// the websocket API rather than polling for them
+type homeAssistantConnection struct {
+	url           string
+	token         string
+	allowInsecure bool
+
+	mu           sync.RWMutex
+	states       map[string]homeAssistantState
+	areaByEntity map[string]string
+	connected    bool
+	err          error
+	synced       chan struct{}
+	syncedOnce   sync.Once
+}
+
+func newHomeAssistantConnection(url, token string, allowInsecure bool) *homeAssistantConnection {
+	return &homeAssistantConnection{
+		url:           url,
+		token:         token,
+		allowInsecure: allowInsecure,
+		states:        make(map[string]homeAssistantState),
+		areaByEntity:  make(map[string]string),
+		synced:        make(chan struct{}),
+	}
+}
+
+func (c *homeAssistantConnection) run(ctx context.Context) {
+	delay := homeAssistantMinRetryDelay
+
+	for {
+		start := time.Now()
+		err := c.connectAndListen(ctx)
+
+		c.mu.Lock()
+		c.connected = false
+		c.err = err
+		c.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("Home Assistant connection lost", "url", c.url, "error", err)
+
+		// connections that lasted a while were fine, so start backing off from the beginning
+		if time.Since(start) > homeAssistantMaxRetryDelay {
+			delay = homeAssistantMinRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, homeAssistantMaxRetryDelay)
+	}
+}
+
+func (c *homeAssistantConnection) websocketURL() string {
+	if rest, ok := strings.CutPrefix(c.url, "https://"); ok {
+		return "wss://" + rest + "/api/websocket"
+	}
+
+	return "ws://" + strings.TrimPrefix(c.url, "http://") + "/api/websocket"
+}
+
+func (c *homeAssistantConnection) connectAndListen(ctx context.Context) error {
+	config, err := websocket.NewConfig(c.websocketURL(), c.url)
+	if err != nil {
+		return err
+	}
+
+	config.TlsConfig = &tls.Config{InsecureSkipVerify: c.allowInsecure}
+	config.Dialer = &net.Dialer{Timeout: defaultClientTimeout}
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return fmt.Errorf("connecting: %w", err)
+	}
+	defer conn.Close()
+
+	// closing the connection is the only way to interrupt a blocked read
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	receive := func() (homeAssistantMessage, error) {
+		var message homeAssistantMessage
+		conn.SetReadDeadline(time.Now().Add(homeAssistantReadTimeout))
+		err := websocket.JSON.Receive(conn, &message)
+		return message, err
+	}
+
+	var writeMu sync.Mutex
+	send := func(message map[string]any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(defaultClientTimeout))
+		return websocket.JSON.Send(conn, message)
+	}
+
+	if message, err := receive(); err != nil {
+		return fmt.Errorf("waiting for auth request: %w", err)
+	} else if message.Type != "auth_required" {
+		return fmt.Errorf("expected auth_required message, got %s", message.Type)
+	}
+
+	if err := send(map[string]any{"type": "auth", "access_token": c.token}); err != nil {
+		return fmt.Errorf("sending auth: %w", err)
+	}
+
+	if message, err := receive(); err != nil {
+		return fmt.Errorf("waiting for auth response: %w", err)
+	} else if message.Type == "auth_invalid" {
+		return errors.New("authentication failed, check that the token is valid")
+	} else if message.Type != "auth_ok" {
+		return fmt.Errorf("expected auth_ok message, got %s", message.Type)
+	}
+
+	commands := []map[string]any{
+		{"id": homeAssistantMsgAreas, "type": "config/area_registry/list"},
+		{"id": homeAssistantMsgDevices, "type": "config/device_registry/list"},
+		{"id": homeAssistantMsgEntities, "type": "config/entity_registry/list"},
+		{"id": homeAssistantMsgStates, "type": "get_states"},
+		{"id": homeAssistantMsgSubscribe, "type": "subscribe_events", "event_type": "state_changed"},
+	}
+
+	for _, command := range commands {
+		if err := send(command); err != nil {
+			return fmt.Errorf("sending %s: %w", command["type"], err)
+		}
+	}
+
+	c.mu.Lock()
+	c.connected = true
+	c.err = nil
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(homeAssistantPingInterval)
+		defer ticker.Stop()
+
+		for id := homeAssistantMsgSubscribe + 1; ; id++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if send(map[string]any{"id": id, "type": "ping"}) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var registries homeAssistantRegistries
+
+	for {
+		message, err := receive()
+		if err != nil {
+			return fmt.Errorf("receiving: %w", err)
+		}
+
+		if err := c.handleMessage(&message, &registries); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *homeAssistantConnection) handleMessage(message *homeAssistantMessage, registries *homeAssistantRegistries) error {
+	if message.Type == "event" {
+		if message.Event == nil {
+			return nil
+		}
+
+		c.mu.Lock()
+		if state := message.Event.Data.NewState; state != nil {
+			c.states[state.EntityID] = *state
+		} else {
+			delete(c.states, message.Event.Data.EntityID)
+		}
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	if message.Type != "result" || message.ID > homeAssistantMsgSubscribe {
+		return nil
+	}
+
+	if !message.Success {
+		errorMessage := "unknown error"
+		if message.Error != nil {
+			errorMessage = message.Error.Message
+		}
+
+		switch message.ID {
+		case homeAssistantMsgStates, homeAssistantMsgSubscribe:
+			return fmt.Errorf("command %d failed: %s", message.ID, errorMessage)
+		}
+
+		// the registries are only needed for grouping by area, so entities are still shown without them
+		slog.Warn("Could not list Home Assistant registry, areas may be missing", "error", errorMessage)
+		registries.received++
+	} else {
+		var err error
+
+		switch message.ID {
+		case homeAssistantMsgAreas:
+			err = json.Unmarshal(message.Result, &registries.areas)
+		case homeAssistantMsgDevices:
+			err = json.Unmarshal(message.Result, &registries.devices)
+		case homeAssistantMsgEntities:
+			err = json.Unmarshal(message.Result, &registries.entities)
+		case homeAssistantMsgStates:
+			var states []homeAssistantState
+			if err = json.Unmarshal(message.Result, &states); err != nil {
+				return fmt.Errorf("decoding states: %w", err)
+			}
+
+			c.mu.Lock()
+			clear(c.states)
+			for i := range states {
+				c.states[states[i].EntityID] = states[i]
+			}
+			c.mu.Unlock()
+
+			c.syncedOnce.Do(func() { close(c.synced) })
+			return nil
+		case homeAssistantMsgSubscribe:
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("decoding registry: %w", err)
+		}
+
+		registries.received++
+	}
+
+	if registries.received == 3 {
+		c.updateAreas(registries)
+	}
+
+	return nil
+}
+
+// Entities can have an area of their own, otherwise they're in the area of their device
+func (c *homeAssistantConnection) updateAreas(registries *homeAssistantRegistries) {
+	areaNames := make(map[string]string, len(registries.areas))
+	for _, area := range registries.areas {
+		areaNames[area.AreaID] = area.Name
+	}
+
+	deviceAreas := make(map[string]string, len(registries.devices))
+	for _, device := range registries.devices {
+		deviceAreas[device.ID] = device.AreaID
+	}
+
+	areaByEntity := make(map[string]string, len(registries.entities))
+	for _, entity := range registries.entities {
+		areaID := entity.AreaID
+		if areaID == "" {
+			areaID = deviceAreas[entity.DeviceID]
+		}
+
+		if name := areaNames[areaID]; name != "" {
+			areaByEntity[entity.EntityID] = name
+		}
+	}
+
+	c.mu.Lock()
+	c.areaByEntity = areaByEntity
+	c.mu.Unlock()
+}
+
+// Waits for the states to be received for the first time, returns an error wrapping
+// errPartialContent if they're available but the connection has since been lost
+func (c *homeAssistantConnection) waitForStates(timeout time.Duration) error {
+	select {
+	case <-c.synced:
+	case <-time.After(timeout):
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		if c.err != nil {
+			return c.err
+		}
+
+		return errHomeAssistantNotConnected
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.connected {
+		return fmt.Errorf("%w: connection lost, states may be outdated: %v", errPartialContent, c.err)
+	}
+
+	return nil
+}
+
+func (c *homeAssistantConnection) state(entityID string) (homeAssistantState, string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.states[entityID]
+	return state, c.areaByEntity[entityID], ok
+}
+
+// Calls the service through the REST API since, unlike the websocket API, it
+// returns the states that changed as a result of the call
+func (c *homeAssistantConnection) callService(domain, service, entityID string) ([]homeAssistantState, error) {
+	body, err := json.Marshal(map[string]string{"entity_id": entityID})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("POST", c.url+"/api/services/"+domain+"/"+service, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+c.token)
+	request.Header.Set("Content-Type", "application/json")
+
+	client := ternary(c.allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
+	states, err := decodeJsonFromRequest[[]homeAssistantState](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s.%s: %w", domain, service, err)
+	}
+
+	c.mu.Lock()
+	for i := range states {
+		c.states[states[i].EntityID] = states[i]
+	}
+	c.mu.Unlock()
+
+	return states, nil
+}
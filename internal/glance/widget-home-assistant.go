@@ -0,0 +1,323 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var homeAssistantWidgetTemplate = mustParseTemplate("home-assistant.html", "widget-base.html")
+
+type homeAssistantWidget struct {
+	widgetBase    `yaml:",inline"`
+	URL           string                       `yaml:"url"`
+	Token         string                       `yaml:"token" secret:"true"`
+	AllowInsecure bool                         `yaml:"allow-insecure"`
+	Entities      []homeAssistantEntityRequest `yaml:"entities"`
+	Areas         []homeAssistantArea          `yaml:"-"`
+	conn          *homeAssistantConnection
+}
+
+type homeAssistantEntityRequest struct {
+	EntityID string          `yaml:"entity"`
+	Name     string          `yaml:"name"`
+	Icon     customIconField `yaml:"icon"`
+	Area     string          `yaml:"area"`
+	Actions  []string        `yaml:"actions"`
+}
+
+func (r *homeAssistantEntityRequest) UnmarshalYAML(node *yaml.Node) error {
+	type homeAssistantEntityRequestAlias homeAssistantEntityRequest
+	var entityID string
+
+	if err := node.Decode(&entityID); err == nil {
+		r.EntityID = entityID
+		return nil
+	}
+
+	if err := node.Decode((*homeAssistantEntityRequestAlias)(r)); err != nil {
+		return fmt.Errorf("could not unmarshal entity into string or struct: %v", err)
+	}
+
+	return nil
+}
+
+type homeAssistantArea struct {
+	Name     string
+	Entities []homeAssistantEntity
+}
+
+type homeAssistantEntity struct {
+	EntityID    string
+	Name        string
+	Icon        customIconField
+	State       string
+	IsActive    bool
+	IsAvailable bool
+	LastChanged time.Time
+	Actions     []string
+}
+
+// Maps the actions that can be configured to the service that gets called, an empty
+// domain means the domain of the entity is used
+var homeAssistantActions = map[string]struct {
+	domain  string
+	service string
+	domains []string
+}{
+	"toggle":   {domain: "homeassistant", service: "toggle"},
+	"turn-on":  {domain: "homeassistant", service: "turn_on"},
+	"turn-off": {domain: "homeassistant", service: "turn_off"},
+	"run":      {service: "turn_on", domains: []string{"script", "scene"}},
+	"press":    {service: "press", domains: []string{"button", "input_button"}},
+	"trigger":  {domain: "automation", service: "trigger", domains: []string{"automation"}},
+}
+
+var homeAssistantDefaultIcons = map[string]string{
+	"automation":    "mdi:robot",
+	"binary_sensor": "mdi:checkbox-blank-circle-outline",
+	"button":        "mdi:button-pointer",
+	"climate":       "mdi:thermostat",
+	"cover":         "mdi:window-shutter",
+	"fan":           "mdi:fan",
+	"input_boolean": "mdi:toggle-switch-outline",
+	"input_button":  "mdi:button-pointer",
+	"light":         "mdi:lightbulb",
+	"lock":          "mdi:lock",
+	"media_player":  "mdi:cast",
+	"person":        "mdi:account",
+	"scene":         "mdi:palette",
+	"script":        "mdi:script-text",
+	"sensor":        "mdi:eye",
+	"switch":        "mdi:toggle-switch-variant",
+	"vacuum":        "mdi:robot-vacuum",
+	"weather":       "mdi:weather-partly-cloudy",
+}
+
+var homeAssistantActiveStates = []string{"on", "open", "opening", "unlocked", "home", "playing", "heat", "cool", "heat_cool", "cleaning"}
+
+func (widget *homeAssistantWidget) initialize() error {
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	widget.
+		withTitle("Home Assistant").
+		withTitleURL(widget.URL).
+		withCacheDuration(5 * time.Second)
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if !strings.HasPrefix(widget.URL, "http://") && !strings.HasPrefix(widget.URL, "https://") {
+		return errors.New("url must start with http:// or https://")
+	}
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if len(widget.Entities) == 0 {
+		return errors.New("at least one entity is required")
+	}
+
+	for i := range widget.Entities {
+		entity := &widget.Entities[i]
+		if entity.EntityID == "" {
+			return fmt.Errorf("entity #%d: entity is required", i+1)
+		}
+
+		domain, _, _ := strings.Cut(entity.EntityID, ".")
+
+		for _, action := range entity.Actions {
+			definition, ok := homeAssistantActions[action]
+			if !ok {
+				return fmt.Errorf("entity %s: unknown action %q", entity.EntityID, action)
+			}
+
+			if len(definition.domains) > 0 && !slices.Contains(definition.domains, domain) {
+				return fmt.Errorf("entity %s: action %s can only be used with %s entities", entity.EntityID, action, strings.Join(definition.domains, ", "))
+			}
+		}
+	}
+
+	widget.conn = newHomeAssistantConnection(widget.URL, widget.Token, widget.AllowInsecure)
+
+	return nil
+}
+
+func (widget *homeAssistantWidget) runInBackground(ctx context.Context, app *application) {
+	widget.conn.run(ctx)
+}
+
+func (widget *homeAssistantWidget) update(ctx context.Context) {
+	err := widget.conn.waitForStates(defaultClientTimeout)
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Areas = widget.buildAreas()
+}
+
+// Areas are shown in the order they first appear in, with entities that
+// don't have an area shown last
+func (widget *homeAssistantWidget) buildAreas() []homeAssistantArea {
+	areas := make([]homeAssistantArea, 0)
+	indexByName := make(map[string]int)
+	withoutArea := homeAssistantArea{}
+
+	for i := range widget.Entities {
+		request := &widget.Entities[i]
+		state, areaName, _ := widget.conn.state(request.EntityID)
+		entity := newHomeAssistantEntity(request, &state)
+
+		if request.Area != "" {
+			areaName = request.Area
+		}
+
+		if areaName == "" {
+			withoutArea.Entities = append(withoutArea.Entities, entity)
+			continue
+		}
+
+		index, ok := indexByName[areaName]
+		if !ok {
+			index = len(areas)
+			indexByName[areaName] = index
+			areas = append(areas, homeAssistantArea{Name: areaName})
+		}
+
+		areas[index].Entities = append(areas[index].Entities, entity)
+	}
+
+	if len(withoutArea.Entities) > 0 {
+		if len(areas) > 0 {
+			withoutArea.Name = "Other"
+		}
+
+		areas = append(areas, withoutArea)
+	}
+
+	return areas
+}
+
+func newHomeAssistantEntity(request *homeAssistantEntityRequest, state *homeAssistantState) homeAssistantEntity {
+	domain, _, _ := strings.Cut(request.EntityID, ".")
+
+	entity := homeAssistantEntity{
+		EntityID:    request.EntityID,
+		Name:        request.Name,
+		Icon:        request.Icon,
+		State:       formatHomeAssistantState(state),
+		IsActive:    slices.Contains(homeAssistantActiveStates, state.State),
+		IsAvailable: state.State != "" && state.State != "unavailable",
+		LastChanged: state.LastChanged,
+		Actions:     request.Actions,
+	}
+
+	if entity.Name == "" {
+		if name, ok := state.Attributes["friendly_name"].(string); ok && name != "" {
+			entity.Name = name
+		} else {
+			entity.Name = request.EntityID
+		}
+	}
+
+	if entity.Icon.URL == "" {
+		if icon, ok := state.Attributes["icon"].(string); ok && icon != "" {
+			entity.Icon = newCustomIconField(icon)
+		} else if icon, ok := homeAssistantDefaultIcons[domain]; ok {
+			entity.Icon = newCustomIconField(icon)
+		} else {
+			entity.Icon = newCustomIconField("mdi:home-assistant")
+		}
+	}
+
+	return entity
+}
+
+func formatHomeAssistantState(state *homeAssistantState) string {
+	if state.State == "" {
+		return "not found"
+	}
+
+	if unit, ok := state.Attributes["unit_of_measurement"].(string); ok && unit != "" {
+		return state.State + " " + unit
+	}
+
+	return strings.ReplaceAll(state.State, "_", " ")
+}
+
+func (widget *homeAssistantWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, homeAssistantWidgetTemplate)
+}
+
+func (widget *homeAssistantWidget) hasActions() bool {
+	for i := range widget.Entities {
+		if len(widget.Entities[i].Actions) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (widget *homeAssistantWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	parts := strings.Split(r.PathValue("path"), "/")
+	if len(parts) != 3 || parts[0] != "entities" {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	entityID, action := parts[1], parts[2]
+
+	index := slices.IndexFunc(widget.Entities, func(e homeAssistantEntityRequest) bool {
+		return e.EntityID == entityID
+	})
+	if index == -1 {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "entity not found"})
+		return
+	}
+
+	request := &widget.Entities[index]
+	if !slices.Contains(request.Actions, action) {
+		writeJSONResponse(w, http.StatusForbidden, map[string]string{"error": "action not allowed"})
+		return
+	}
+
+	definition := homeAssistantActions[action]
+	domain := definition.domain
+	if domain == "" {
+		domain, _, _ = strings.Cut(entityID, ".")
+	}
+
+	states, err := widget.conn.callService(domain, definition.service, entityID)
+	if err != nil {
+		slog.Error("Failed to call Home Assistant service", "entity", entityID, "action", action, "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// some services, such as running a script, don't change the state of the entity itself
+	response := map[string]any{}
+	for i := range states {
+		if states[i].EntityID == entityID {
+			response["state"] = formatHomeAssistantState(&states[i])
+			break
+		}
+	}
+
+	writeJSONResponse(w, http.StatusOK, response)
+}
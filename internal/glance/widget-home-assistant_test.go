@@ -0,0 +1,273 @@
+package glance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const homeAssistantTestStates = `[
+	{"entity_id": "light.desk", "state": "off", "attributes": {"friendly_name": "Desk Lamp"}, "last_changed": "2025-03-01T12:00:00+00:00"},
+	{"entity_id": "sensor.kitchen_temperature", "state": "21.5", "attributes": {"friendly_name": "Kitchen Temperature", "unit_of_measurement": "°C", "icon": "mdi:thermometer"}},
+	{"entity_id": "script.good_night", "state": "off", "attributes": {"friendly_name": "Good Night"}},
+	{"entity_id": "switch.fridge", "state": "unavailable", "attributes": {}}
+]`
+
+func TestHomeAssistantWidgetInitialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"entity as a string", "url: http://ha.local\ntoken: t\nentities: [light.desk]", ""},
+		{"entity with actions", "url: https://ha.local/\ntoken: t\nentities: [{entity: script.bedtime, actions: [run, toggle]}]", ""},
+		{"missing scheme", "url: ha.local\ntoken: t\nentities: [light.desk]", "url must start with"},
+		{"missing token", "url: http://ha.local\nentities: [light.desk]", "token is required"},
+		{"no entities", "url: http://ha.local\ntoken: t", "at least one entity"},
+		{"entity without id", "url: http://ha.local\ntoken: t\nentities: [{name: Desk}]", "entity #1: entity is required"},
+		{"unknown action", "url: http://ha.local\ntoken: t\nentities: [{entity: light.desk, actions: [dim]}]", `unknown action "dim"`},
+		{"action for another domain", "url: http://ha.local\ntoken: t\nentities: [{entity: light.desk, actions: [press]}]", "press can only be used with button, input_button entities"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var widget homeAssistantWidget
+			if err := yaml.Unmarshal([]byte(tt.config), &widget); err != nil {
+				t.Fatal(err)
+			}
+
+			err := widget.initialize()
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("initialize() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("initialize() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatHomeAssistantState(t *testing.T) {
+	tests := []struct {
+		name  string
+		state homeAssistantState
+		want  string
+	}{
+		{"missing", homeAssistantState{}, "not found"},
+		{"with unit", homeAssistantState{State: "21.5", Attributes: map[string]any{"unit_of_measurement": "°C"}}, "21.5 °C"},
+		{"with empty unit", homeAssistantState{State: "3", Attributes: map[string]any{"unit_of_measurement": ""}}, "3"},
+		{"underscores", homeAssistantState{State: "heat_cool"}, "heat cool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatHomeAssistantState(&tt.state); got != tt.want {
+				t.Errorf("formatHomeAssistantState() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHomeAssistantEntity(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       homeAssistantEntityRequest
+		state         homeAssistantState
+		wantName      string
+		wantIcon      string
+		wantActive    bool
+		wantAvailable bool
+	}{
+		{
+			name:          "friendly name and default icon",
+			request:       homeAssistantEntityRequest{EntityID: "light.desk"},
+			state:         homeAssistantState{State: "on", Attributes: map[string]any{"friendly_name": "Desk Lamp"}},
+			wantName:      "Desk Lamp",
+			wantIcon:      "/lightbulb.svg",
+			wantActive:    true,
+			wantAvailable: true,
+		},
+		{
+			name:          "configured name and icon",
+			request:       homeAssistantEntityRequest{EntityID: "light.desk", Name: "Desk", Icon: newCustomIconField("mdi:lamp")},
+			state:         homeAssistantState{State: "off", Attributes: map[string]any{"friendly_name": "Desk Lamp", "icon": "mdi:desk"}},
+			wantName:      "Desk",
+			wantIcon:      "/lamp.svg",
+			wantAvailable: true,
+		},
+		{
+			name:          "icon from attributes",
+			request:       homeAssistantEntityRequest{EntityID: "sensor.kitchen_temperature"},
+			state:         homeAssistantState{State: "21.5", Attributes: map[string]any{"icon": "mdi:thermometer"}},
+			wantName:      "sensor.kitchen_temperature",
+			wantIcon:      "/thermometer.svg",
+			wantAvailable: true,
+		},
+		{
+			name:     "unavailable in unknown domain",
+			request:  homeAssistantEntityRequest{EntityID: "siren.garage"},
+			state:    homeAssistantState{State: "unavailable"},
+			wantName: "siren.garage",
+			wantIcon: "/home-assistant.svg",
+		},
+		{
+			name:     "missing",
+			request:  homeAssistantEntityRequest{EntityID: "lock.door"},
+			wantName: "lock.door",
+			wantIcon: "/lock.svg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity := newHomeAssistantEntity(&tt.request, &tt.state)
+
+			if entity.Name != tt.wantName || !strings.HasSuffix(string(entity.Icon.URL), tt.wantIcon) {
+				t.Errorf("entity = %s with icon %s, want %s with icon ending in %s", entity.Name, entity.Icon.URL, tt.wantName, tt.wantIcon)
+			}
+
+			if entity.IsActive != tt.wantActive || entity.IsAvailable != tt.wantAvailable {
+				t.Errorf("entity active %v, available %v, want %v, %v", entity.IsActive, entity.IsAvailable, tt.wantActive, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestHomeAssistantConnectionHandleMessage(t *testing.T) {
+	conn := newHomeAssistantConnection("http://ha.local", "", false)
+	registries := &homeAssistantRegistries{}
+
+	result := func(id int, data string) *homeAssistantMessage {
+		return &homeAssistantMessage{ID: id, Type: "result", Success: true, Result: json.RawMessage(data)}
+	}
+
+	event := func(data string) *homeAssistantMessage {
+		var message homeAssistantMessage
+		if err := json.Unmarshal([]byte(`{"type": "event", "event": {"data": `+data+`}}`), &message); err != nil {
+			t.Fatal(err)
+		}
+		return &message
+	}
+
+	failedDevices := &homeAssistantMessage{ID: homeAssistantMsgDevices, Type: "result"}
+
+	steps := []struct {
+		name       string
+		message    *homeAssistantMessage
+		wantErr    bool
+		wantSynced bool
+		// the state and area of light.desk and sensor.kitchen_temperature
+		wantDesk    string
+		wantKitchen string
+	}{
+		{"areas", result(homeAssistantMsgAreas, `[{"area_id": "kitchen", "name": "Kitchen"}, {"area_id": "office", "name": "Office"}]`), false, false, " ", " "},
+		// the registries are only needed for grouping, so failing to list one isn't fatal
+		{"devices fail", failedDevices, false, false, " ", " "},
+		{"entities", result(homeAssistantMsgEntities, `[{"entity_id": "light.desk", "device_id": "lamp"}, {"entity_id": "sensor.kitchen_temperature", "area_id": "kitchen"}]`), false, false, " ", " Kitchen"},
+		{"states", result(homeAssistantMsgStates, homeAssistantTestStates), false, true, "off ", "21.5 Kitchen"},
+		{"state changed", event(`{"entity_id": "sensor.kitchen_temperature", "new_state": {"entity_id": "sensor.kitchen_temperature", "state": "22.0"}}`), false, true, "off ", "22.0 Kitchen"},
+		{"entity removed", event(`{"entity_id": "light.desk", "new_state": null}`), false, true, " ", "22.0 Kitchen"},
+		{"reply to a ping", &homeAssistantMessage{ID: homeAssistantMsgSubscribe + 1, Type: "result"}, false, true, " ", "22.0 Kitchen"},
+		{"subscribing fails", &homeAssistantMessage{ID: homeAssistantMsgSubscribe, Type: "result"}, true, true, " ", "22.0 Kitchen"},
+	}
+
+	for _, step := range steps {
+		err := conn.handleMessage(step.message, registries)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: handleMessage() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+
+		synced := false
+		select {
+		case <-conn.synced:
+			synced = true
+		default:
+		}
+
+		if synced != step.wantSynced {
+			t.Errorf("%s: synced = %v, want %v", step.name, synced, step.wantSynced)
+		}
+
+		desk, deskArea, _ := conn.state("light.desk")
+		kitchen, kitchenArea, _ := conn.state("sensor.kitchen_temperature")
+
+		if got := desk.State + " " + deskArea; got != step.wantDesk {
+			t.Errorf("%s: desk = %q, want %q", step.name, got, step.wantDesk)
+		}
+
+		if got := kitchen.State + " " + kitchenArea; got != step.wantKitchen {
+			t.Errorf("%s: kitchen = %q, want %q", step.name, got, step.wantKitchen)
+		}
+	}
+}
+
+func TestHomeAssistantWidgetHandleRequest(t *testing.T) {
+	url := startFakeAPIServer(t, "Authorization: Bearer test-token", map[string]string{
+		"POST /api/services/homeassistant/toggle": `[{"entity_id": "light.desk", "state": "on", "attributes": {}}]`,
+		"POST /api/services/script/turn_on":       `[]`,
+	})
+
+	var widget homeAssistantWidget
+	err := yaml.Unmarshal([]byte(`
+url: `+url+`
+token: test-token
+entities:
+  - sensor.kitchen_temperature
+  - entity: light.desk
+    actions: [toggle]
+  - entity: script.good_night
+    actions: [run]
+`), &widget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"toggle", http.MethodPost, "entities/light.desk/toggle", http.StatusOK, `{"state":"on"}`},
+		// scripts don't change state themselves, so there's nothing to respond with
+		{"run script", http.MethodPost, "entities/script.good_night/run", http.StatusOK, `{}`},
+		{"action that isn't configured", http.MethodPost, "entities/light.desk/turn-off", http.StatusForbidden, ""},
+		{"entity without actions", http.MethodPost, "entities/sensor.kitchen_temperature/toggle", http.StatusForbidden, ""},
+		{"entity that isn't configured", http.MethodPost, "entities/light.kitchen/toggle", http.StatusNotFound, ""},
+		{"action with get", http.MethodGet, "entities/light.desk/toggle", http.StatusMethodNotAllowed, ""},
+		{"other resource", http.MethodPost, "services/light.desk/toggle", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/api/widgets/1/"+tt.path, nil)
+			request.SetPathValue("path", tt.path)
+			recorder := httptest.NewRecorder()
+
+			widget.handleRequest(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+
+			if tt.wantBody != "" && strings.TrimSpace(recorder.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s, want %s", recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	if state, _, _ := widget.conn.state("light.desk"); state.State != "on" {
+		t.Errorf("state = %q, want it updated from the service response", state.State)
+	}
+}
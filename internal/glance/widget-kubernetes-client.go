@@ -0,0 +1,328 @@
+package glance
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+type kubernetesClient struct {
+	server     string
+	httpClient *http.Client
+	token      string
+	// service account tokens get rotated, so they're read again for every request
+	tokenFile string
+	username  string
+	password  string
+}
+
+// Only the parts of the kubeconfig format needed to connect using a token, client
+// certificate or basic auth are supported, which covers what k3s, kind, minikube
+// and most self hosted distributions generate
+type kubeconfigFile struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string    `yaml:"token"`
+			TokenFile             string    `yaml:"tokenFile"`
+			ClientCertificate     string    `yaml:"client-certificate"`
+			ClientCertificateData string    `yaml:"client-certificate-data"`
+			ClientKey             string    `yaml:"client-key"`
+			ClientKeyData         string    `yaml:"client-key-data"`
+			Username              string    `yaml:"username"`
+			Password              string    `yaml:"password"`
+			Exec                  yaml.Node `yaml:"exec"`
+			AuthProvider          yaml.Node `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+func defaultKubeconfigPath() string {
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		// multiple files can be specified, which get merged by kubectl,
+		// since that isn't supported the first one is used
+		return filepath.SplitList(path)[0]
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".kube", "config")
+}
+
+func isRunningInKubernetes() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+func newInClusterKubernetesClient(allowInsecure bool) (*kubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set when running in a cluster")
+	}
+
+	tokenFile := filepath.Join(kubernetesServiceAccountDir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: allowInsecure}
+	if !allowInsecure {
+		data, err := os.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+		if err != nil {
+			return nil, fmt.Errorf("reading service account CA: %w", err)
+		}
+
+		pool, err := certPoolFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("service account CA: %w", err)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return &kubernetesClient{
+		server:     "https://" + net.JoinHostPort(host, port),
+		httpClient: newKubernetesHTTPClient(tlsConfig),
+		tokenFile:  tokenFile,
+	}, nil
+}
+
+func newKubeconfigKubernetesClient(path string, contextName string, allowInsecure bool) (*kubernetesClient, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+
+	var config kubeconfigFile
+	if err := yaml.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	if contextName == "" {
+		return nil, errors.New("kubeconfig has no current-context, specify which one to use through the context property")
+	}
+
+	contextIndex := -1
+	for i := range config.Contexts {
+		if config.Contexts[i].Name == contextName {
+			contextIndex = i
+			break
+		}
+	}
+
+	if contextIndex == -1 {
+		return nil, fmt.Errorf("context %s not found in kubeconfig", contextName)
+	}
+
+	kubeContext := &config.Contexts[contextIndex].Context
+	client := &kubernetesClient{}
+	tlsConfig := &tls.Config{InsecureSkipVerify: allowInsecure}
+	// relative paths within the kubeconfig are relative to the file itself
+	baseDir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+
+		return filepath.Join(baseDir, file)
+	}
+
+	clusterFound := false
+	for i := range config.Clusters {
+		if config.Clusters[i].Name != kubeContext.Cluster {
+			continue
+		}
+
+		cluster := &config.Clusters[i].Cluster
+		clusterFound = true
+		client.server = strings.TrimRight(cluster.Server, "/")
+		tlsConfig.ServerName = cluster.TLSServerName
+
+		if cluster.InsecureSkipTLSVerify {
+			tlsConfig.InsecureSkipVerify = true
+		}
+
+		if cluster.CertificateAuthority != "" || cluster.CertificateAuthorityData != "" {
+			data, err := decodeKubeconfigData(resolve(cluster.CertificateAuthority), cluster.CertificateAuthorityData)
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s certificate authority: %w", kubeContext.Cluster, err)
+			}
+
+			if tlsConfig.RootCAs, err = certPoolFromPEM(data); err != nil {
+				return nil, fmt.Errorf("cluster %s certificate authority: %w", kubeContext.Cluster, err)
+			}
+		}
+
+		break
+	}
+
+	if !clusterFound {
+		return nil, fmt.Errorf("cluster %s not found in kubeconfig", kubeContext.Cluster)
+	}
+
+	if client.server == "" {
+		return nil, fmt.Errorf("cluster %s has no server", kubeContext.Cluster)
+	}
+
+	for i := range config.Users {
+		if config.Users[i].Name != kubeContext.User {
+			continue
+		}
+
+		user := &config.Users[i].User
+
+		if !user.Exec.IsZero() || !user.AuthProvider.IsZero() {
+			return nil, fmt.Errorf("user %s uses a credential plugin, which isn't supported, use a token or client certificate instead", kubeContext.User)
+		}
+
+		client.token = user.Token
+		client.tokenFile = resolve(user.TokenFile)
+		client.username = user.Username
+		client.password = user.Password
+
+		hasCert := user.ClientCertificate != "" || user.ClientCertificateData != ""
+		hasKey := user.ClientKey != "" || user.ClientKeyData != ""
+
+		if hasCert != hasKey {
+			return nil, fmt.Errorf("user %s must have both a client certificate and key", kubeContext.User)
+		}
+
+		if hasCert {
+			cert, err := decodeKubeconfigData(resolve(user.ClientCertificate), user.ClientCertificateData)
+			if err != nil {
+				return nil, fmt.Errorf("user %s client certificate: %w", kubeContext.User, err)
+			}
+
+			key, err := decodeKubeconfigData(resolve(user.ClientKey), user.ClientKeyData)
+			if err != nil {
+				return nil, fmt.Errorf("user %s client key: %w", kubeContext.User, err)
+			}
+
+			certificate, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("user %s client certificate: %w", kubeContext.User, err)
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{certificate}
+		}
+
+		break
+	}
+
+	client.httpClient = newKubernetesHTTPClient(tlsConfig)
+
+	return client, nil
+}
+
+func newKubernetesHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout: defaultClientTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConnsPerHost: 10,
+			Proxy:               http.ProxyFromEnvironment,
+		},
+	}
+}
+
+// Values in kubeconfigs are either a path to a file or the base64 encoded contents
+func decodeKubeconfigData(path string, data string) ([]byte, error) {
+	if data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64: %w", err)
+		}
+
+		return decoded, nil
+	}
+
+	return os.ReadFile(path)
+}
+
+func certPoolFromPEM(data []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no valid certificates found")
+	}
+
+	return pool, nil
+}
+
+func (client *kubernetesClient) newRequest(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	requestURL := client.server + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	token := client.token
+	if client.tokenFile != "" {
+		contents, err := os.ReadFile(client.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token file: %w", err)
+		}
+
+		token = strings.TrimSpace(string(contents))
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else if client.username != "" {
+		request.SetBasicAuth(client.username, client.password)
+	}
+
+	return request, nil
+}
+
+func fetchKubernetesAPI[T any](ctx context.Context, client *kubernetesClient, path string, query url.Values) (T, error) {
+	request, err := client.newRequest(ctx, path, query)
+	if err != nil {
+		var result T
+		return result, err
+	}
+
+	return decodeJsonFromRequest[T](client.httpClient, request)
+}
@@ -0,0 +1,485 @@
+package glance
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var kubernetesWidgetTemplate = mustParseTemplate("kubernetes.html", "widget-base.html", "state-icon.html")
+
+const (
+	kubernetesAnnotationName        = "glance/name"
+	kubernetesAnnotationIcon        = "glance/icon"
+	kubernetesAnnotationURL         = "glance/url"
+	kubernetesAnnotationDescription = "glance/description"
+	kubernetesAnnotationSameTab     = "glance/same-tab"
+	kubernetesAnnotationHide        = "glance/hide"
+)
+
+type kubernetesWidget struct {
+	widgetBase    `yaml:",inline"`
+	Kubeconfig    string               `yaml:"kubeconfig"`
+	Context       string               `yaml:"context"`
+	Namespaces    []string             `yaml:"namespaces"`
+	LabelSelector string               `yaml:"label-selector"`
+	HideByDefault bool                 `yaml:"hide-by-default"`
+	AllowInsecure bool                 `yaml:"allow-insecure"`
+	Workloads     []kubernetesWorkload `yaml:"-"`
+	client        *kubernetesClient
+}
+
+func (widget *kubernetesWidget) initialize() error {
+	widget.withTitle("Kubernetes").withCacheDuration(1 * time.Minute)
+
+	var err error
+
+	if widget.Kubeconfig == "" && isRunningInKubernetes() {
+		widget.client, err = newInClusterKubernetesClient(widget.AllowInsecure)
+	} else {
+		if widget.Kubeconfig == "" {
+			widget.Kubeconfig = defaultKubeconfigPath()
+		}
+
+		widget.client, err = newKubeconfigKubernetesClient(widget.Kubeconfig, widget.Context, widget.AllowInsecure)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(widget.Namespaces) == 0 {
+		// an empty namespace lists the resources of all namespaces
+		widget.Namespaces = []string{""}
+	}
+
+	return nil
+}
+
+func (widget *kubernetesWidget) update(ctx context.Context) {
+	workloads, err := fetchKubernetesWorkloads(widget.client, widget.Namespaces, widget.LabelSelector, widget.HideByDefault)
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Workloads = workloads
+}
+
+func (widget *kubernetesWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, kubernetesWidgetTemplate)
+}
+
+type kubernetesWorkload struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	Description string
+	URL         string
+	SameTab     bool
+	Icon        customIconField
+	Ready       int
+	Desired     int
+	Restarts    int
+	State       string
+	StateText   string
+	StateIcon   string
+	Pods        []kubernetesPod
+}
+
+type kubernetesPod struct {
+	Name      string
+	Restarts  int
+	State     string
+	StateText string
+	StateIcon string
+}
+
+type kubernetesObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
+	DeletionTimestamp string            `json:"deletionTimestamp"`
+	OwnerReferences   []struct {
+		Kind       string `json:"kind"`
+		Name       string `json:"name"`
+		Controller bool   `json:"controller"`
+	} `json:"ownerReferences"`
+}
+
+type kubernetesListResponse[T any] struct {
+	Items []T `json:"items"`
+}
+
+// Deployments and statefulsets have the same shape for everything that's needed
+type kubernetesWorkloadResponse struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Spec     struct {
+		Replicas *int `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ReadyReplicas int `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+type kubernetesPodResponse struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Status   struct {
+		Phase             string `json:"phase"`
+		Reason            string `json:"reason"`
+		ContainerStatuses []struct {
+			Ready        bool `json:"ready"`
+			RestartCount int  `json:"restartCount"`
+			State        struct {
+				Waiting *struct {
+					Reason string `json:"reason"`
+				} `json:"waiting"`
+				Terminated *struct {
+					Reason string `json:"reason"`
+				} `json:"terminated"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type kubernetesNamespaceResources struct {
+	deployments  []kubernetesWorkloadResponse
+	statefulsets []kubernetesWorkloadResponse
+	pods         []kubernetesPodResponse
+}
+
+func fetchKubernetesWorkloads(
+	client *kubernetesClient,
+	namespaces []string,
+	labelSelector string,
+	hideByDefault bool,
+) ([]kubernetesWorkload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job := newJob(func(namespace string) (kubernetesNamespaceResources, error) {
+		return fetchKubernetesNamespaceResources(ctx, client, namespace, labelSelector)
+	}, namespaces)
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]kubernetesNamespaceResources, 0, len(results))
+	var firstErr error
+	failed := 0
+
+	for i := range results {
+		if errs[i] != nil {
+			if failed == 0 {
+				firstErr = errs[i]
+			}
+
+			failed++
+			continue
+		}
+
+		resources = append(resources, results[i])
+	}
+
+	if failed == len(namespaces) {
+		return nil, firstErr
+	}
+
+	workloads := buildKubernetesWorkloads(resources, hideByDefault)
+
+	if failed > 0 {
+		return workloads, fmt.Errorf("%w: could not fetch %d of %d namespaces: %v", errPartialContent, failed, len(namespaces), firstErr)
+	}
+
+	return workloads, nil
+}
+
+func fetchKubernetesNamespaceResources(
+	ctx context.Context,
+	client *kubernetesClient,
+	namespace string,
+	labelSelector string,
+) (kubernetesNamespaceResources, error) {
+	var resources kubernetesNamespaceResources
+
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+
+	path := func(group string, resource string) string {
+		if namespace == "" {
+			return group + "/" + resource
+		}
+
+		return group + "/namespaces/" + url.PathEscape(namespace) + "/" + resource
+	}
+
+	deployments, err := fetchKubernetesAPI[kubernetesListResponse[kubernetesWorkloadResponse]](ctx, client, path("/apis/apps/v1", "deployments"), query)
+	if err != nil {
+		return resources, fmt.Errorf("fetching deployments: %w", err)
+	}
+
+	statefulsets, err := fetchKubernetesAPI[kubernetesListResponse[kubernetesWorkloadResponse]](ctx, client, path("/apis/apps/v1", "statefulsets"), query)
+	if err != nil {
+		return resources, fmt.Errorf("fetching statefulsets: %w", err)
+	}
+
+	pods, err := fetchKubernetesAPI[kubernetesListResponse[kubernetesPodResponse]](ctx, client, path("/api/v1", "pods"), query)
+	if err != nil {
+		return resources, fmt.Errorf("fetching pods: %w", err)
+	}
+
+	resources.deployments = deployments.Items
+	resources.statefulsets = statefulsets.Items
+	resources.pods = pods.Items
+
+	return resources, nil
+}
+
+// Pods are shown as part of the deployment or statefulset that manages them, pods that aren't
+// managed by anything are shown on their own while ones managed by other kinds of controllers,
+// such as jobs and daemonsets, aren't shown at all
+func buildKubernetesWorkloads(resources []kubernetesNamespaceResources, hideByDefault bool) []kubernetesWorkload {
+	workloads := make([]kubernetesWorkload, 0)
+	metas := make([]*kubernetesObjectMeta, 0)
+	indexByKey := make(map[string]int)
+
+	addWorkload := func(kind string, meta *kubernetesObjectMeta, desired int, ready int) {
+		indexByKey[kind+"/"+meta.Namespace+"/"+meta.Name] = len(workloads)
+		metas = append(metas, meta)
+		workloads = append(workloads, kubernetesWorkload{
+			Kind:      kind,
+			Namespace: meta.Namespace,
+			Name:      meta.Name,
+			Desired:   desired,
+			Ready:     ready,
+		})
+	}
+
+	for r := range resources {
+		for _, kind := range []string{"Deployment", "StatefulSet"} {
+			list := ternary(kind == "Deployment", resources[r].deployments, resources[r].statefulsets)
+
+			for i := range list {
+				desired := 1
+				if list[i].Spec.Replicas != nil {
+					desired = *list[i].Spec.Replicas
+				}
+
+				addWorkload(kind, &list[i].Metadata, desired, list[i].Status.ReadyReplicas)
+			}
+		}
+	}
+
+	for r := range resources {
+		pods := resources[r].pods
+
+		for i := range pods {
+			pod := &pods[i]
+			kind, name, managed := kubernetesPodController(pod)
+
+			index, ok := indexByKey[kind+"/"+pod.Metadata.Namespace+"/"+name]
+			if !ok {
+				// completed pods that aren't managed by anything are usually one-off tasks
+				if managed || pod.Status.Phase == "Succeeded" {
+					continue
+				}
+
+				ready := 0
+				if kubernetesPodIsReady(pod) {
+					ready = 1
+				}
+
+				addWorkload("Pod", &pod.Metadata, 1, ready)
+				index = len(workloads) - 1
+			}
+
+			workload := &workloads[index]
+			p := kubernetesPodToPod(pod)
+			workload.Restarts += p.Restarts
+			workload.Pods = append(workload.Pods, p)
+		}
+	}
+
+	visible := make([]kubernetesWorkload, 0, len(workloads))
+
+	for i := range workloads {
+		workload := &workloads[i]
+		annotations := metas[i].Annotations
+
+		hidden := hideByDefault
+		if v := annotations[kubernetesAnnotationHide]; v != "" {
+			hidden = stringToBool(v)
+		}
+
+		if hidden {
+			continue
+		}
+
+		if v := annotations[kubernetesAnnotationName]; v != "" {
+			workload.Name = v
+		}
+
+		workload.URL = annotations[kubernetesAnnotationURL]
+		workload.Description = annotations[kubernetesAnnotationDescription]
+		workload.SameTab = stringToBool(annotations[kubernetesAnnotationSameTab])
+		workload.Icon = newCustomIconField(ternary(annotations[kubernetesAnnotationIcon] != "", annotations[kubernetesAnnotationIcon], "si:kubernetes"))
+
+		sort.Slice(workload.Pods, func(a, b int) bool {
+			return workload.Pods[a].Name < workload.Pods[b].Name
+		})
+
+		workload.setState()
+		visible = append(visible, *workload)
+	}
+
+	p := dockerContainerStateIconPriorities
+
+	sort.SliceStable(visible, func(a, b int) bool {
+		if visible[a].StateIcon != visible[b].StateIcon {
+			return p[visible[a].StateIcon] < p[visible[b].StateIcon]
+		}
+
+		return strings.ToLower(visible[a].Name) < strings.ToLower(visible[b].Name)
+	})
+
+	return visible
+}
+
+func (workload *kubernetesWorkload) setState() {
+	if workload.Kind == "Pod" && len(workload.Pods) == 1 {
+		pod := &workload.Pods[0]
+		workload.State, workload.StateText, workload.StateIcon = pod.State, pod.StateText, pod.StateIcon
+		return
+	}
+
+	podsHaveProblems := false
+	for i := range workload.Pods {
+		if workload.Pods[i].StateIcon == dockerContainerStateIconWarn {
+			podsHaveProblems = true
+			break
+		}
+	}
+
+	switch {
+	case workload.Desired == 0:
+		workload.State = "scaled down"
+		workload.StateIcon = dockerContainerStateIconPaused
+	case workload.Ready == 0:
+		workload.State = "down"
+		workload.StateIcon = dockerContainerStateIconWarn
+	case workload.Ready < workload.Desired || podsHaveProblems:
+		workload.State = "degraded"
+		workload.StateIcon = dockerContainerStateIconWarn
+	default:
+		workload.State = "running"
+		workload.StateIcon = dockerContainerStateIconOK
+	}
+
+	workload.StateText = strconv.Itoa(workload.Ready) + "/" + strconv.Itoa(workload.Desired) + " ready"
+	if workload.Restarts > 0 {
+		workload.StateText += ", " + strconv.Itoa(workload.Restarts) + " restarts"
+	}
+}
+
+// Pods of deployments are managed through a replicaset whose name is that of the deployment followed by
+// the hash of the pod template, which the pods also have as a label, so there's no need to fetch replicasets
+func kubernetesPodController(pod *kubernetesPodResponse) (kind string, name string, managed bool) {
+	for _, owner := range pod.Metadata.OwnerReferences {
+		if !owner.Controller {
+			continue
+		}
+
+		switch owner.Kind {
+		case "ReplicaSet":
+			hash := pod.Metadata.Labels["pod-template-hash"]
+			if hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+				return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash), true
+			}
+		case "StatefulSet":
+			return "StatefulSet", owner.Name, true
+		}
+
+		return owner.Kind, owner.Name, true
+	}
+
+	return "Pod", pod.Metadata.Name, false
+}
+
+func kubernetesPodIsReady(pod *kubernetesPodResponse) bool {
+	if pod.Status.Phase != "Running" || len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+
+	for i := range pod.Status.ContainerStatuses {
+		if !pod.Status.ContainerStatuses[i].Ready {
+			return false
+		}
+	}
+
+	return true
+}
+
+func kubernetesPodToPod(pod *kubernetesPodResponse) kubernetesPod {
+	p := kubernetesPod{Name: pod.Metadata.Name}
+	ready := 0
+	// the reason a container is waiting or was terminated, such as CrashLoopBackOff
+	// or ImagePullBackOff, is more useful than the phase of the pod
+	reason := ""
+
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		p.Restarts += status.RestartCount
+
+		if status.Ready {
+			ready++
+		} else if status.State.Waiting != nil && status.State.Waiting.Reason != "" && reason == "" {
+			reason = status.State.Waiting.Reason
+		} else if status.State.Terminated != nil && status.State.Terminated.Reason != "" && reason == "" {
+			reason = status.State.Terminated.Reason
+		}
+	}
+
+	total := len(pod.Status.ContainerStatuses)
+
+	switch {
+	case pod.Metadata.DeletionTimestamp != "":
+		p.State = "terminating"
+		p.StateIcon = dockerContainerStateIconOther
+	case pod.Status.Phase == "Running" && total > 0 && ready == total:
+		p.State = "running"
+		p.StateIcon = dockerContainerStateIconOK
+	case pod.Status.Phase == "Succeeded":
+		p.State = "completed"
+		p.StateIcon = dockerContainerStateIconOther
+	case pod.Status.Phase == "Pending" && (reason == "" || reason == "ContainerCreating" || reason == "PodInitializing"):
+		p.State = "pending"
+		p.StateIcon = dockerContainerStateIconOther
+	default:
+		p.State = strings.ToLower(ternary(reason != "", reason, ternary(pod.Status.Phase == "Running", "not ready", pod.Status.Phase)))
+		p.StateIcon = dockerContainerStateIconWarn
+	}
+
+	if pod.Status.Reason != "" && reason == "" {
+		reason = pod.Status.Reason
+	}
+
+	p.StateText = strconv.Itoa(ready) + "/" + strconv.Itoa(total) + " ready"
+	if reason != "" && p.State != strings.ToLower(reason) {
+		p.StateText += ", " + reason
+	}
+
+	if p.Restarts > 0 {
+		p.StateText += ", " + strconv.Itoa(p.Restarts) + " restarts"
+	}
+
+	return p
+}
@@ -0,0 +1,398 @@
+package glance
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func decodeKubernetesTestItems[T any](t *testing.T, data string) []T {
+	t.Helper()
+
+	var list kubernetesListResponse[T]
+	if err := json.Unmarshal([]byte(`{"items": `+data+`}`), &list); err != nil {
+		t.Fatalf("decoding test items: %v", err)
+	}
+
+	return list.Items
+}
+
+func writeTestKubeconfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+const kubernetesTestKubeconfig = `
+current-context: home
+clusters:
+  - name: home
+    cluster:
+      server: https://home.local:6443/
+  - name: lab
+    cluster:
+      server: https://lab.local:6443
+users:
+  - name: token
+    user:
+      token: home-token
+  - name: token-file
+    user:
+      tokenFile: token
+  - name: basic
+    user:
+      username: admin
+      password: secret
+  - name: plugin
+    user:
+      exec:
+        command: aws
+  - name: half-certificate
+    user:
+      client-certificate-data: Y2VydA==
+contexts:
+  - name: home
+    context: {cluster: home, user: token}
+  - name: lab
+    context: {cluster: lab, user: token-file}
+  - name: basic
+    context: {cluster: lab, user: basic}
+  - name: plugin
+    context: {cluster: lab, user: plugin}
+  - name: half-certificate
+    context: {cluster: lab, user: half-certificate}
+`
+
+func TestNewKubeconfigKubernetesClient(t *testing.T) {
+	path := writeTestKubeconfig(t, kubernetesTestKubeconfig)
+	noCurrentContext := writeTestKubeconfig(t, strings.Replace(kubernetesTestKubeconfig, "current-context: home", "", 1))
+
+	tests := []struct {
+		name          string
+		path          string
+		context       string
+		wantServer    string
+		wantToken     string
+		wantTokenFile string
+		wantUsername  string
+		wantErr       string
+	}{
+		{name: "current context", path: path, wantServer: "https://home.local:6443", wantToken: "home-token"},
+		{name: "explicit context", path: path, context: "lab", wantServer: "https://lab.local:6443", wantTokenFile: filepath.Join(filepath.Dir(path), "token")},
+		{name: "basic auth", path: path, context: "basic", wantServer: "https://lab.local:6443", wantUsername: "admin"},
+		{name: "no current context", path: noCurrentContext, wantErr: "kubeconfig has no current-context"},
+		{name: "unknown context", path: path, context: "other", wantErr: "context other not found"},
+		{name: "credential plugin", path: path, context: "plugin", wantErr: "uses a credential plugin"},
+		{name: "certificate without key", path: path, context: "half-certificate", wantErr: "must have both a client certificate and key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newKubeconfigKubernetesClient(tt.path, tt.context, false)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newKubeconfigKubernetesClient() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("newKubeconfigKubernetesClient() error = %v", err)
+			}
+
+			if client.server != tt.wantServer || client.token != tt.wantToken || client.tokenFile != tt.wantTokenFile || client.username != tt.wantUsername {
+				t.Errorf("client = %+v, want server %s, token %q, token file %q, username %q", client, tt.wantServer, tt.wantToken, tt.wantTokenFile, tt.wantUsername)
+			}
+		})
+	}
+}
+
+func TestKubernetesPodController(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    string
+		wantKind    string
+		wantName    string
+		wantManaged bool
+	}{
+		{
+			name:        "deployment through replicaset",
+			metadata:    `{"name": "web-7d4b9c-abcde", "labels": {"pod-template-hash": "7d4b9c"}, "ownerReferences": [{"kind": "ReplicaSet", "name": "web-7d4b9c", "controller": true}]}`,
+			wantKind:    "Deployment",
+			wantName:    "web",
+			wantManaged: true,
+		},
+		{
+			name:        "replicaset without matching hash",
+			metadata:    `{"name": "web-abcde", "labels": {"pod-template-hash": "other"}, "ownerReferences": [{"kind": "ReplicaSet", "name": "web", "controller": true}]}`,
+			wantKind:    "ReplicaSet",
+			wantName:    "web",
+			wantManaged: true,
+		},
+		{
+			name:        "other controller",
+			metadata:    `{"name": "backup-28391", "ownerReferences": [{"kind": "Job", "name": "backup", "controller": true}]}`,
+			wantKind:    "Job",
+			wantName:    "backup",
+			wantManaged: true,
+		},
+		{
+			name:     "owner that isn't a controller",
+			metadata: `{"name": "debug", "ownerReferences": [{"kind": "Job", "name": "backup"}]}`,
+			wantKind: "Pod",
+			wantName: "debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := decodeKubernetesTestItems[kubernetesPodResponse](t, `[{"metadata": `+tt.metadata+`}]`)[0]
+			kind, name, managed := kubernetesPodController(&pod)
+
+			if kind != tt.wantKind || name != tt.wantName || managed != tt.wantManaged {
+				t.Errorf("kubernetesPodController() = %s, %s, %v, want %s, %s, %v", kind, name, managed, tt.wantKind, tt.wantName, tt.wantManaged)
+			}
+		})
+	}
+}
+
+func TestKubernetesPodToPod(t *testing.T) {
+	tests := []struct {
+		name          string
+		pod           string
+		wantState     string
+		wantStateText string
+		wantStateIcon string
+	}{
+		{
+			name:          "running",
+			pod:           `{"status": {"phase": "Running", "containerStatuses": [{"ready": true, "state": {}}]}}`,
+			wantState:     "running",
+			wantStateText: "1/1 ready",
+			wantStateIcon: dockerContainerStateIconOK,
+		},
+		{
+			name:          "crash looping",
+			pod:           `{"status": {"phase": "Running", "containerStatuses": [{"ready": true, "restartCount": 1, "state": {}}, {"ready": false, "restartCount": 4, "state": {"waiting": {"reason": "CrashLoopBackOff"}}}]}}`,
+			wantState:     "crashloopbackoff",
+			wantStateText: "1/2 ready, 5 restarts",
+			wantStateIcon: dockerContainerStateIconWarn,
+		},
+		{
+			name:          "pulling image",
+			pod:           `{"status": {"phase": "Pending", "containerStatuses": [{"ready": false, "state": {"waiting": {"reason": "ContainerCreating"}}}]}}`,
+			wantState:     "pending",
+			wantStateText: "0/1 ready, ContainerCreating",
+			wantStateIcon: dockerContainerStateIconOther,
+		},
+		{
+			name:          "image pull failure",
+			pod:           `{"status": {"phase": "Pending", "containerStatuses": [{"ready": false, "state": {"waiting": {"reason": "ImagePullBackOff"}}}]}}`,
+			wantState:     "imagepullbackoff",
+			wantStateText: "0/1 ready",
+			wantStateIcon: dockerContainerStateIconWarn,
+		},
+		{
+			name:          "evicted",
+			pod:           `{"status": {"phase": "Failed", "reason": "Evicted"}}`,
+			wantState:     "failed",
+			wantStateText: "0/0 ready, Evicted",
+			wantStateIcon: dockerContainerStateIconWarn,
+		},
+		{
+			name:          "completed",
+			pod:           `{"status": {"phase": "Succeeded", "containerStatuses": [{"ready": false, "state": {"terminated": {"reason": "Completed"}}}]}}`,
+			wantState:     "completed",
+			wantStateText: "0/1 ready",
+			wantStateIcon: dockerContainerStateIconOther,
+		},
+		{
+			name:          "terminating",
+			pod:           `{"metadata": {"deletionTimestamp": "2024-01-01T00:00:00Z"}, "status": {"phase": "Running", "containerStatuses": [{"ready": true, "state": {}}]}}`,
+			wantState:     "terminating",
+			wantStateText: "1/1 ready",
+			wantStateIcon: dockerContainerStateIconOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := decodeKubernetesTestItems[kubernetesPodResponse](t, `[`+tt.pod+`]`)[0]
+			got := kubernetesPodToPod(&pod)
+
+			if got.State != tt.wantState || got.StateText != tt.wantStateText || got.StateIcon != tt.wantStateIcon {
+				t.Errorf("kubernetesPodToPod() = %s %q %s, want %s %q %s", got.State, got.StateText, got.StateIcon, tt.wantState, tt.wantStateText, tt.wantStateIcon)
+			}
+		})
+	}
+}
+
+func TestKubernetesWorkloadSetState(t *testing.T) {
+	warnPod := kubernetesPod{State: "crashloopbackoff", StateText: "0/1 ready", StateIcon: dockerContainerStateIconWarn}
+	okPod := kubernetesPod{State: "running", StateText: "1/1 ready", StateIcon: dockerContainerStateIconOK}
+
+	tests := []struct {
+		name          string
+		workload      kubernetesWorkload
+		wantState     string
+		wantStateText string
+		wantStateIcon string
+	}{
+		{"running", kubernetesWorkload{Kind: "Deployment", Desired: 2, Ready: 2, Pods: []kubernetesPod{okPod, okPod}}, "running", "2/2 ready", dockerContainerStateIconOK},
+		{"scaled down", kubernetesWorkload{Kind: "StatefulSet"}, "scaled down", "0/0 ready", dockerContainerStateIconPaused},
+		{"down", kubernetesWorkload{Kind: "Deployment", Desired: 2}, "down", "0/2 ready", dockerContainerStateIconWarn},
+		{"missing replicas", kubernetesWorkload{Kind: "Deployment", Desired: 2, Ready: 1}, "degraded", "1/2 ready", dockerContainerStateIconWarn},
+		{"pod with problems", kubernetesWorkload{Kind: "Deployment", Desired: 1, Ready: 1, Pods: []kubernetesPod{warnPod}}, "degraded", "1/1 ready", dockerContainerStateIconWarn},
+		{"standalone pod", kubernetesWorkload{Kind: "Pod", Desired: 1, Pods: []kubernetesPod{warnPod}}, "crashloopbackoff", "0/1 ready", dockerContainerStateIconWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.workload.setState()
+
+			if tt.workload.State != tt.wantState || tt.workload.StateText != tt.wantStateText || tt.workload.StateIcon != tt.wantStateIcon {
+				t.Errorf("setState() = %s %q %s, want %s %q %s", tt.workload.State, tt.workload.StateText, tt.workload.StateIcon, tt.wantState, tt.wantStateText, tt.wantStateIcon)
+			}
+		})
+	}
+}
+
+const kubernetesTestDeployments = `[
+	{
+		"metadata": {"name": "jellyfin", "namespace": "media", "annotations": {"glance/name": "Jellyfin", "glance/url": "https://jellyfin.local"}},
+		"spec": {"replicas": 2},
+		"status": {"readyReplicas": 1}
+	},
+	{
+		"metadata": {"name": "internal", "namespace": "media", "annotations": {"glance/hide": "true"}},
+		"spec": {"replicas": 1},
+		"status": {"readyReplicas": 1}
+	},
+	{
+		"metadata": {"name": "shown", "namespace": "media", "annotations": {"glance/hide": "false"}},
+		"status": {"readyReplicas": 1}
+	}
+]`
+
+const kubernetesTestStatefulSets = `[
+	{
+		"metadata": {"name": "postgres", "namespace": "media"},
+		"spec": {"replicas": 1},
+		"status": {"readyReplicas": 1}
+	}
+]`
+
+const kubernetesTestPods = `[
+	{
+		"metadata": {
+			"name": "jellyfin-7d4b9c-fghij", "namespace": "media", "labels": {"pod-template-hash": "7d4b9c"},
+			"ownerReferences": [{"kind": "ReplicaSet", "name": "jellyfin-7d4b9c", "controller": true}]
+		},
+		"status": {"phase": "Running", "containerStatuses": [{"ready": false, "restartCount": 4, "state": {"waiting": {"reason": "CrashLoopBackOff"}}}]}
+	},
+	{
+		"metadata": {
+			"name": "jellyfin-7d4b9c-abcde", "namespace": "media", "labels": {"pod-template-hash": "7d4b9c"},
+			"ownerReferences": [{"kind": "ReplicaSet", "name": "jellyfin-7d4b9c", "controller": true}]
+		},
+		"status": {"phase": "Running", "containerStatuses": [{"ready": true, "restartCount": 1, "state": {}}]}
+	},
+	{
+		"metadata": {
+			"name": "postgres-0", "namespace": "media",
+			"ownerReferences": [{"kind": "StatefulSet", "name": "postgres", "controller": true}]
+		},
+		"status": {"phase": "Running", "containerStatuses": [{"ready": true, "restartCount": 0, "state": {}}]}
+	},
+	{
+		"metadata": {"name": "debug", "namespace": "media"},
+		"status": {"phase": "Pending", "containerStatuses": [{"ready": false, "restartCount": 0, "state": {"waiting": {"reason": "ImagePullBackOff"}}}]}
+	},
+	{
+		"metadata": {"name": "migrate", "namespace": "media"},
+		"status": {"phase": "Succeeded"}
+	},
+	{
+		"metadata": {
+			"name": "backup-28391", "namespace": "media",
+			"ownerReferences": [{"kind": "Job", "name": "backup", "controller": true}]
+		},
+		"status": {"phase": "Succeeded"}
+	}
+]`
+
+func TestBuildKubernetesWorkloads(t *testing.T) {
+	resources := []kubernetesNamespaceResources{{
+		deployments:  decodeKubernetesTestItems[kubernetesWorkloadResponse](t, kubernetesTestDeployments),
+		statefulsets: decodeKubernetesTestItems[kubernetesWorkloadResponse](t, kubernetesTestStatefulSets),
+		pods:         decodeKubernetesTestItems[kubernetesPodResponse](t, kubernetesTestPods),
+	}}
+
+	tests := []struct {
+		name          string
+		hideByDefault bool
+		// problems are sorted first, then by name
+		want []string
+	}{
+		{"shown by default", false, []string{"Pod/debug", "Deployment/Jellyfin", "StatefulSet/postgres", "Deployment/shown"}},
+		{"hidden by default", true, []string{"Deployment/shown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workloads := buildKubernetesWorkloads(resources, tt.hideByDefault)
+
+			got := make([]string, len(workloads))
+			for i := range workloads {
+				got[i] = workloads[i].Kind + "/" + workloads[i].Name
+			}
+
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("buildKubernetesWorkloads() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	workloads := buildKubernetesWorkloads(resources, false)
+	jellyfin := workloads[1]
+
+	if jellyfin.URL != "https://jellyfin.local" || jellyfin.Restarts != 5 || jellyfin.StateText != "1/2 ready, 5 restarts" {
+		t.Errorf("deployment = %+v, want annotations applied and restarts of its pods summed", jellyfin)
+	}
+
+	if len(jellyfin.Pods) != 2 || jellyfin.Pods[0].Name != "jellyfin-7d4b9c-abcde" {
+		t.Errorf("deployment pods = %+v, want both pods sorted by name", jellyfin.Pods)
+	}
+
+	if shown := workloads[3]; shown.Desired != 1 {
+		t.Errorf("deployment without replicas desired = %d, want 1", shown.Desired)
+	}
+}
+
+func TestFetchKubernetesWorkloads(t *testing.T) {
+	url := startFakeAPIServer(t, "Authorization: Bearer test-token", map[string]string{
+		"GET /apis/apps/v1/namespaces/media/deployments":  `{"items": ` + kubernetesTestDeployments + `}`,
+		"GET /apis/apps/v1/namespaces/media/statefulsets": `{"items": ` + kubernetesTestStatefulSets + `}`,
+		"GET /api/v1/namespaces/media/pods":               `{"items": ` + kubernetesTestPods + `}`,
+	})
+
+	client := &kubernetesClient{server: url, token: "test-token", httpClient: http.DefaultClient}
+	workloads, err := fetchKubernetesWorkloads(client, []string{"media"}, "", false)
+	if err != nil || len(workloads) != 4 {
+		t.Errorf("fetchKubernetesWorkloads() = %d workloads, error %v, want 4 workloads", len(workloads), err)
+	}
+
+	client.token = "wrong"
+	if _, err := fetchKubernetesWorkloads(client, []string{"media"}, "", false); err == nil {
+		t.Error("fetchKubernetesWorkloads() error = nil, want an error with an invalid token")
+	}
+}
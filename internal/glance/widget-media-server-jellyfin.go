@@ -0,0 +1,209 @@
+package glance
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Emby and Jellyfin, which was forked from it, still share the parts of the API that are needed
+
+type jellyfinItem struct {
+	ID                    string            `json:"Id"`
+	Name                  string            `json:"Name"`
+	Type                  string            `json:"Type"`
+	SeriesName            string            `json:"SeriesName"`
+	SeriesID              string            `json:"SeriesId"`
+	SeriesPrimaryImageTag string            `json:"SeriesPrimaryImageTag"`
+	Album                 string            `json:"Album"`
+	AlbumArtist           string            `json:"AlbumArtist"`
+	AlbumID               string            `json:"AlbumId"`
+	AlbumPrimaryImageTag  string            `json:"AlbumPrimaryImageTag"`
+	ParentIndexNumber     int               `json:"ParentIndexNumber"`
+	IndexNumber           int               `json:"IndexNumber"`
+	ProductionYear        int               `json:"ProductionYear"`
+	RunTimeTicks          int64             `json:"RunTimeTicks"`
+	DateCreated           time.Time         `json:"DateCreated"`
+	ImageTags             map[string]string `json:"ImageTags"`
+}
+
+type jellyfinSession struct {
+	UserName       string        `json:"UserName"`
+	Client         string        `json:"Client"`
+	DeviceName     string        `json:"DeviceName"`
+	NowPlayingItem *jellyfinItem `json:"NowPlayingItem"`
+	PlayState      struct {
+		PositionTicks int64  `json:"PositionTicks"`
+		IsPaused      bool   `json:"IsPaused"`
+		PlayMethod    string `json:"PlayMethod"`
+	} `json:"PlayState"`
+	TranscodingInfo *struct {
+		IsVideoDirect bool `json:"IsVideoDirect"`
+		IsAudioDirect bool `json:"IsAudioDirect"`
+	} `json:"TranscodingInfo"`
+}
+
+type jellyfinItemsResponse struct {
+	Items []jellyfinItem `json:"Items"`
+}
+
+type jellyfinCountsResponse struct {
+	MovieCount   int `json:"MovieCount"`
+	SeriesCount  int `json:"SeriesCount"`
+	EpisodeCount int `json:"EpisodeCount"`
+	AlbumCount   int `json:"AlbumCount"`
+	SongCount    int `json:"SongCount"`
+	BookCount    int `json:"BookCount"`
+}
+
+func fetchJellyfinData(widget *mediaServerWidget) (*mediaServerData, error) {
+	data := &mediaServerData{}
+	parts := make(map[string]func() error)
+
+	if !widget.HideSessions {
+		parts["sessions"] = func() error {
+			sessions, err := fetchMediaServerAPI[[]jellyfinSession](widget, "/Sessions?activeWithinSeconds=960")
+			if err != nil {
+				return err
+			}
+
+			data.sessions = jellyfinSessionsToMediaServerSessions(sessions)
+			return nil
+		}
+	}
+
+	if !widget.HideRecentlyAdded {
+		parts["recently added"] = func() error {
+			query := url.Values{}
+			query.Set("SortBy", "DateCreated")
+			query.Set("SortOrder", "Descending")
+			query.Set("IncludeItemTypes", "Movie,Episode,MusicAlbum")
+			query.Set("Recursive", "true")
+			query.Set("Fields", "DateCreated")
+			query.Set("Limit", strconv.Itoa(widget.RecentlyAddedCount))
+
+			response, err := fetchMediaServerAPI[jellyfinItemsResponse](widget, "/Items?"+query.Encode())
+			if err != nil {
+				return err
+			}
+
+			data.recentlyAdded = make([]mediaServerItem, 0, len(response.Items))
+			for i := range response.Items {
+				item := &response.Items[i]
+				title, subtitle := jellyfinItemTitles(item)
+				data.recentlyAdded = append(data.recentlyAdded, mediaServerItem{
+					Title:      title,
+					Subtitle:   subtitle,
+					AddedAt:    item.DateCreated,
+					posterPath: jellyfinPosterPath(item),
+				})
+			}
+
+			return nil
+		}
+	}
+
+	if !widget.HideLibraries {
+		parts["libraries"] = func() error {
+			counts, err := fetchMediaServerAPI[jellyfinCountsResponse](widget, "/Items/Counts")
+			if err != nil {
+				return err
+			}
+
+			data.libraries = mediaServerLibrariesFromCounts([]mediaServerLibrary{
+				{"Movies", counts.MovieCount},
+				{"Shows", counts.SeriesCount},
+				{"Episodes", counts.EpisodeCount},
+				{"Albums", counts.AlbumCount},
+				{"Songs", counts.SongCount},
+				{"Books", counts.BookCount},
+			})
+
+			return nil
+		}
+	}
+
+	return data, fetchWidgetParts(parts)
+}
+
+func jellyfinSessionsToMediaServerSessions(sessions []jellyfinSession) []mediaServerSession {
+	result := make([]mediaServerSession, 0)
+
+	for i := range sessions {
+		session := &sessions[i]
+		item := session.NowPlayingItem
+		if item == nil {
+			continue
+		}
+
+		title, subtitle := jellyfinItemTitles(item)
+		converted := mediaServerSession{
+			User:            session.UserName,
+			Title:           title,
+			Subtitle:        subtitle,
+			Device:          session.Client,
+			IsPaused:        session.PlayState.IsPaused,
+			ProgressPercent: mediaServerProgressPercent(session.PlayState.PositionTicks, item.RunTimeTicks),
+			posterPath:      jellyfinPosterPath(item),
+		}
+
+		if session.DeviceName != "" {
+			converted.Device += " on " + session.DeviceName
+		}
+
+		switch session.PlayState.PlayMethod {
+		case "Transcode":
+			converted.IsTranscoding = true
+			converted.PlaybackText = "Transcoding"
+
+			if info := session.TranscodingInfo; info != nil {
+				if !info.IsVideoDirect {
+					converted.PlaybackText = "Transcoding video"
+				} else if !info.IsAudioDirect {
+					converted.PlaybackText = "Transcoding audio"
+				}
+			}
+		case "DirectStream":
+			converted.PlaybackText = "Direct stream"
+		default:
+			converted.PlaybackText = "Direct play"
+		}
+
+		result = append(result, converted)
+	}
+
+	return result
+}
+
+func jellyfinItemTitles(item *jellyfinItem) (string, string) {
+	switch item.Type {
+	case "Episode":
+		return item.SeriesName, mediaServerEpisodeSubtitle(item.ParentIndexNumber, item.IndexNumber, item.Name)
+	case "Audio", "MusicAlbum":
+		return item.Name, item.AlbumArtist
+	}
+
+	if item.ProductionYear > 0 {
+		return item.Name, strconv.Itoa(item.ProductionYear)
+	}
+
+	return item.Name, ""
+}
+
+// Episodes and songs use the poster of their series and album
+func jellyfinPosterPath(item *jellyfinItem) string {
+	id, tag := item.ID, item.ImageTags["Primary"]
+
+	if item.SeriesID != "" && item.SeriesPrimaryImageTag != "" {
+		id, tag = item.SeriesID, item.SeriesPrimaryImageTag
+	} else if item.AlbumID != "" && item.AlbumPrimaryImageTag != "" {
+		id, tag = item.AlbumID, item.AlbumPrimaryImageTag
+	}
+
+	if tag == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("/Items/%s/Images/Primary?maxHeight=%d&quality=90&tag=%s", url.PathEscape(id), mediaServerPosterHeight, url.QueryEscape(tag))
+}
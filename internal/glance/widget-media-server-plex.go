@@ -0,0 +1,253 @@
+package glance
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type plexMetadata struct {
+	Type             string `json:"type"`
+	Title            string `json:"title"`
+	ParentTitle      string `json:"parentTitle"`
+	GrandparentTitle string `json:"grandparentTitle"`
+	ParentIndex      int    `json:"parentIndex"`
+	Index            int    `json:"index"`
+	Year             int    `json:"year"`
+	Duration         int64  `json:"duration"`
+	ViewOffset       int64  `json:"viewOffset"`
+	AddedAt          int64  `json:"addedAt"`
+	Thumb            string `json:"thumb"`
+	ParentThumb      string `json:"parentThumb"`
+	GrandparentThumb string `json:"grandparentThumb"`
+	User             *struct {
+		Title string `json:"title"`
+	} `json:"User"`
+	Player *struct {
+		Title   string `json:"title"`
+		Product string `json:"product"`
+		State   string `json:"state"`
+	} `json:"Player"`
+	TranscodeSession *struct {
+		VideoDecision string `json:"videoDecision"`
+		AudioDecision string `json:"audioDecision"`
+	} `json:"TranscodeSession"`
+}
+
+type plexResponse struct {
+	MediaContainer struct {
+		TotalSize int            `json:"totalSize"`
+		Metadata  []plexMetadata `json:"Metadata"`
+		Directory []struct {
+			Key  string `json:"key"`
+			Type string `json:"type"`
+		} `json:"Directory"`
+	} `json:"MediaContainer"`
+}
+
+// The metadata types to count for each type of library section
+var plexSectionCountTypes = map[string][]struct {
+	label    string
+	typeCode int
+}{
+	"movie":  {{"Movies", 1}},
+	"show":   {{"Shows", 2}, {"Episodes", 4}},
+	"artist": {{"Albums", 9}, {"Songs", 10}},
+}
+
+var plexLibraryLabels = []string{"Movies", "Shows", "Episodes", "Albums", "Songs"}
+
+func fetchPlexData(widget *mediaServerWidget) (*mediaServerData, error) {
+	data := &mediaServerData{}
+	parts := make(map[string]func() error)
+
+	if !widget.HideSessions {
+		parts["sessions"] = func() error {
+			response, err := fetchMediaServerAPI[plexResponse](widget, "/status/sessions")
+			if err != nil {
+				return err
+			}
+
+			data.sessions = plexMetadataToMediaServerSessions(response.MediaContainer.Metadata)
+			return nil
+		}
+	}
+
+	if !widget.HideRecentlyAdded {
+		parts["recently added"] = func() error {
+			response, err := fetchMediaServerAPI[plexResponse](widget,
+				"/library/recentlyAdded?X-Plex-Container-Start=0&X-Plex-Container-Size="+strconv.Itoa(widget.RecentlyAddedCount),
+			)
+			if err != nil {
+				return err
+			}
+
+			metadata := response.MediaContainer.Metadata
+			data.recentlyAdded = make([]mediaServerItem, 0, len(metadata))
+			for i := range metadata {
+				title, subtitle := plexItemTitles(&metadata[i])
+				data.recentlyAdded = append(data.recentlyAdded, mediaServerItem{
+					Title:      title,
+					Subtitle:   subtitle,
+					AddedAt:    time.Unix(metadata[i].AddedAt, 0),
+					posterPath: plexPosterPath(&metadata[i]),
+				})
+			}
+
+			return nil
+		}
+	}
+
+	if !widget.HideLibraries {
+		parts["libraries"] = func() error {
+			libraries, err := fetchPlexLibraryCounts(widget)
+			if err != nil {
+				return err
+			}
+
+			data.libraries = libraries
+			return nil
+		}
+	}
+
+	return data, fetchWidgetParts(parts)
+}
+
+func fetchPlexLibraryCounts(widget *mediaServerWidget) ([]mediaServerLibrary, error) {
+	sections, err := fetchMediaServerAPI[plexResponse](widget, "/library/sections")
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	counts := make(map[string]int)
+
+	for _, section := range sections.MediaContainer.Directory {
+		for _, countType := range plexSectionCountTypes[section.Type] {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				response, err := fetchMediaServerAPI[plexResponse](widget, fmt.Sprintf(
+					"/library/sections/%s/all?type=%d&X-Plex-Container-Start=0&X-Plex-Container-Size=0",
+					url.PathEscape(section.Key), countType.typeCode,
+				))
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+
+				counts[countType.label] += response.MediaContainer.TotalSize
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	libraries := make([]mediaServerLibrary, 0, len(plexLibraryLabels))
+	for _, label := range plexLibraryLabels {
+		libraries = append(libraries, mediaServerLibrary{label, counts[label]})
+	}
+
+	return mediaServerLibrariesFromCounts(libraries), nil
+}
+
+func plexMetadataToMediaServerSessions(metadata []plexMetadata) []mediaServerSession {
+	sessions := make([]mediaServerSession, 0, len(metadata))
+
+	for i := range metadata {
+		item := &metadata[i]
+		title, subtitle := plexItemTitles(item)
+
+		session := mediaServerSession{
+			Title:           title,
+			Subtitle:        subtitle,
+			ProgressPercent: mediaServerProgressPercent(item.ViewOffset, item.Duration),
+			posterPath:      plexPosterPath(item),
+		}
+
+		if item.User != nil {
+			session.User = item.User.Title
+		}
+
+		if item.Player != nil {
+			session.Device = item.Player.Product
+			if item.Player.Title != "" {
+				session.Device += " on " + item.Player.Title
+			}
+
+			session.IsPaused = item.Player.State == "paused"
+		}
+
+		switch transcode := item.TranscodeSession; {
+		case transcode == nil:
+			session.PlaybackText = "Direct play"
+		case transcode.VideoDecision == "transcode":
+			session.IsTranscoding = true
+			session.PlaybackText = "Transcoding video"
+		case transcode.AudioDecision == "transcode":
+			session.IsTranscoding = true
+			session.PlaybackText = "Transcoding audio"
+		default:
+			session.PlaybackText = "Direct stream"
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+func plexItemTitles(item *plexMetadata) (string, string) {
+	switch item.Type {
+	case "episode":
+		return item.GrandparentTitle, mediaServerEpisodeSubtitle(item.ParentIndex, item.Index, item.Title)
+	case "season":
+		return item.ParentTitle, item.Title
+	case "track":
+		return item.Title, item.GrandparentTitle
+	case "album":
+		return item.Title, item.ParentTitle
+	}
+
+	if item.Year > 0 {
+		return item.Title, strconv.Itoa(item.Year)
+	}
+
+	return item.Title, ""
+}
+
+// Episodes use the poster of their show and songs the cover of their album, both are
+// resized by the server since the originals can be several megabytes in size
+func plexPosterPath(item *plexMetadata) string {
+	thumb := item.Thumb
+
+	switch item.Type {
+	case "episode":
+		thumb = item.GrandparentThumb
+	case "track":
+		thumb = item.ParentThumb
+	}
+
+	if thumb == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("/photo/:/transcode?width=%d&height=%d&minSize=1&upscale=1&url=%s",
+		mediaServerPosterWidth, mediaServerPosterHeight, url.QueryEscape(thumb),
+	)
+}
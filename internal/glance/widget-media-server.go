@@ -0,0 +1,292 @@
+package glance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var mediaServerWidgetTemplate = mustParseTemplate("media-server.html", "widget-base.html")
+
+const (
+	mediaServerProviderJellyfin = "jellyfin"
+	mediaServerProviderEmby     = "emby"
+	mediaServerProviderPlex     = "plex"
+)
+
+const (
+	mediaServerPosterWidth  = 200
+	mediaServerPosterHeight = 300
+	mediaServerMaxPosters   = 500
+	mediaServerMaxPosterMB  = 10
+)
+
+type mediaServerWidget struct {
+	widgetBase         `yaml:",inline"`
+	Provider           string `yaml:"provider"`
+	URL                string `yaml:"url"`
+	Token              string `yaml:"token" secret:"true"`
+	AllowInsecure      bool   `yaml:"allow-insecure"`
+	RecentlyAddedCount int    `yaml:"recently-added-count"`
+	HideSessions       bool   `yaml:"hide-sessions"`
+	HideRecentlyAdded  bool   `yaml:"hide-recently-added"`
+	HideLibraries      bool   `yaml:"hide-libraries"`
+
+	Sessions      []mediaServerSession `yaml:"-"`
+	RecentlyAdded []mediaServerItem    `yaml:"-"`
+	Libraries     []mediaServerLibrary `yaml:"-"`
+
+	// Maps the keys used in the URLs of the posters to their path on the media server, so
+	// that only the images of items that were shown can be requested through the widget
+	postersMu sync.Mutex
+	posters   map[string]string
+}
+
+type mediaServerSession struct {
+	User            string
+	Title           string
+	Subtitle        string
+	Device          string
+	PosterURL       string
+	ProgressPercent int
+	IsPaused        bool
+	IsTranscoding   bool
+	PlaybackText    string
+	posterPath      string
+}
+
+type mediaServerItem struct {
+	Title      string
+	Subtitle   string
+	PosterURL  string
+	AddedAt    time.Time
+	posterPath string
+}
+
+type mediaServerLibrary struct {
+	Label string
+	Count int
+}
+
+type mediaServerData struct {
+	sessions      []mediaServerSession
+	recentlyAdded []mediaServerItem
+	libraries     []mediaServerLibrary
+}
+
+func (widget *mediaServerWidget) initialize() error {
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	widget.
+		withTitleURL(widget.URL).
+		withCacheDuration(1 * time.Minute)
+
+	switch widget.Provider {
+	case mediaServerProviderJellyfin:
+		widget.withTitle("Jellyfin")
+	case mediaServerProviderEmby:
+		widget.withTitle("Emby")
+	case mediaServerProviderPlex:
+		widget.withTitle("Plex")
+	default:
+		return fmt.Errorf("provider must be one of: %s, %s, %s", mediaServerProviderJellyfin, mediaServerProviderEmby, mediaServerProviderPlex)
+	}
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if widget.RecentlyAddedCount <= 0 {
+		widget.RecentlyAddedCount = 10
+	}
+
+	widget.posters = make(map[string]string)
+
+	return nil
+}
+
+func (widget *mediaServerWidget) update(ctx context.Context) {
+	var data *mediaServerData
+	var err error
+
+	switch widget.Provider {
+	case mediaServerProviderJellyfin, mediaServerProviderEmby:
+		data, err = fetchJellyfinData(widget)
+	case mediaServerProviderPlex:
+		data, err = fetchPlexData(widget)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.postersMu.Lock()
+	if len(widget.posters) > mediaServerMaxPosters {
+		clear(widget.posters)
+	}
+
+	for i := range data.sessions {
+		data.sessions[i].PosterURL = widget.posterURL(data.sessions[i].posterPath)
+	}
+
+	for i := range data.recentlyAdded {
+		data.recentlyAdded[i].PosterURL = widget.posterURL(data.recentlyAdded[i].posterPath)
+	}
+	widget.postersMu.Unlock()
+
+	widget.Sessions = data.sessions
+	widget.RecentlyAdded = data.recentlyAdded
+	widget.Libraries = data.libraries
+}
+
+// Must be called with postersMu held
+func (widget *mediaServerWidget) posterURL(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(path))
+	key := hex.EncodeToString(hash[:12])
+	widget.posters[key] = path
+
+	baseURL := ""
+	if widget.Providers != nil {
+		baseURL = widget.Providers.baseURL
+	}
+
+	return baseURL + "/api/widgets/" + strconv.FormatUint(widget.GetID(), 10) + "/posters/" + key
+}
+
+func (widget *mediaServerWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, mediaServerWidgetTemplate)
+}
+
+func (widget *mediaServerWidget) newRequest(method, path string) (*http.Request, error) {
+	request, err := http.NewRequest(method, widget.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	switch widget.Provider {
+	case mediaServerProviderJellyfin:
+		request.Header.Set("Authorization", `MediaBrowser Client="Glance", Token="`+widget.Token+`"`)
+	case mediaServerProviderEmby:
+		request.Header.Set("X-Emby-Token", widget.Token)
+	case mediaServerProviderPlex:
+		request.Header.Set("X-Plex-Token", widget.Token)
+		request.Header.Set("X-Plex-Product", "Glance")
+		request.Header.Set("X-Plex-Client-Identifier", "glance")
+	}
+
+	return request, nil
+}
+
+func (widget *mediaServerWidget) client() *http.Client {
+	return ternary(widget.AllowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
+}
+
+func fetchMediaServerAPI[T any](widget *mediaServerWidget, path string) (T, error) {
+	request, err := widget.newRequest("GET", path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return decodeJsonFromRequest[T](widget.client(), request)
+}
+
+func (widget *mediaServerWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.PathValue("path"), "posters/")
+	if !ok {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	widget.postersMu.Lock()
+	path, exists := widget.posters[key]
+	widget.postersMu.Unlock()
+
+	if !exists {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "poster not found"})
+		return
+	}
+
+	request, err := widget.newRequest("GET", path)
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	request = request.WithContext(r.Context())
+	request.Header.Set("Accept", "image/*")
+
+	response, err := widget.client().Do(request)
+	if err != nil {
+		slog.Error("Failed to fetch media server poster", "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": "could not fetch poster"})
+		return
+	}
+	defer response.Body.Close()
+
+	contentType := response.Header.Get("Content-Type")
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{
+			"error": fmt.Sprintf("unexpected response from media server: %d %s", response.StatusCode, contentType),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, io.LimitReader(response.Body, mediaServerMaxPosterMB<<20))
+}
+
+func mediaServerLibrariesFromCounts(counts []mediaServerLibrary) []mediaServerLibrary {
+	libraries := make([]mediaServerLibrary, 0, len(counts))
+
+	for i := range counts {
+		if counts[i].Count > 0 {
+			libraries = append(libraries, counts[i])
+		}
+	}
+
+	return libraries
+}
+
+func mediaServerEpisodeSubtitle(season, episode int, name string) string {
+	if season == 0 && episode == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("S%02dE%02d · %s", season, episode, name)
+}
+
+func mediaServerProgressPercent(position, duration int64) int {
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(min(100, max(0, position*100/duration)))
+}
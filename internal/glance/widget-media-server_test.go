@@ -0,0 +1,340 @@
+package glance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJellyfinItemTitlesAndPosterPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		item         string
+		wantTitle    string
+		wantSubtitle string
+		wantPoster   string
+	}{
+		{
+			name:         "movie",
+			item:         `{"Id": "movie1", "Name": "A Movie", "Type": "Movie", "ProductionYear": 2024, "ImageTags": {"Primary": "def"}}`,
+			wantTitle:    "A Movie",
+			wantSubtitle: "2024",
+			wantPoster:   "/Items/movie1/Images/Primary?maxHeight=300&quality=90&tag=def",
+		},
+		{
+			name:      "movie without year or poster",
+			item:      `{"Id": "movie2", "Name": "No Poster", "Type": "Movie"}`,
+			wantTitle: "No Poster",
+		},
+		{
+			name:         "episode with the poster of its series",
+			item:         `{"Id": "ep1", "Name": "Pilot", "Type": "Episode", "SeriesName": "The Show", "SeriesId": "show1", "SeriesPrimaryImageTag": "abc", "ParentIndexNumber": 1, "IndexNumber": 2, "ImageTags": {"Primary": "ep"}}`,
+			wantTitle:    "The Show",
+			wantSubtitle: "S01E02 · Pilot",
+			wantPoster:   "/Items/show1/Images/Primary?maxHeight=300&quality=90&tag=abc",
+		},
+		{
+			name:         "song with the cover of its album",
+			item:         `{"Id": "song1", "Name": "A Song", "Type": "Audio", "AlbumArtist": "The Band", "AlbumId": "album1", "AlbumPrimaryImageTag": "a b"}`,
+			wantTitle:    "A Song",
+			wantSubtitle: "The Band",
+			wantPoster:   "/Items/album1/Images/Primary?maxHeight=300&quality=90&tag=a+b",
+		},
+		{
+			name:         "album",
+			item:         `{"Id": "album1", "Name": "An Album", "Type": "MusicAlbum", "AlbumArtist": "The Band", "ProductionYear": 1999, "ImageTags": {"Primary": "cover"}}`,
+			wantTitle:    "An Album",
+			wantSubtitle: "The Band",
+			wantPoster:   "/Items/album1/Images/Primary?maxHeight=300&quality=90&tag=cover",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item jellyfinItem
+			if err := json.Unmarshal([]byte(tt.item), &item); err != nil {
+				t.Fatal(err)
+			}
+
+			title, subtitle := jellyfinItemTitles(&item)
+			if title != tt.wantTitle || subtitle != tt.wantSubtitle {
+				t.Errorf("jellyfinItemTitles() = %q, %q, want %q, %q", title, subtitle, tt.wantTitle, tt.wantSubtitle)
+			}
+
+			if poster := jellyfinPosterPath(&item); poster != tt.wantPoster {
+				t.Errorf("jellyfinPosterPath() = %q, want %q", poster, tt.wantPoster)
+			}
+		})
+	}
+}
+
+func TestJellyfinSessionsToMediaServerSessions(t *testing.T) {
+	const item = `"NowPlayingItem": {"Name": "A Movie", "Type": "Movie", "RunTimeTicks": 1000}`
+
+	tests := []struct {
+		name         string
+		session      string
+		wantDevice   string
+		wantPlayback string
+		wantProgress int
+		wantPaused   bool
+	}{
+		{
+			name:         "direct play",
+			session:      `{"Client": "Jellyfin Web", "DeviceName": "Firefox", ` + item + `, "PlayState": {"PositionTicks": 500, "PlayMethod": "DirectPlay"}}`,
+			wantDevice:   "Jellyfin Web on Firefox",
+			wantPlayback: "Direct play",
+			wantProgress: 50,
+		},
+		{
+			name:         "direct stream without a device name",
+			session:      `{"Client": "Infuse", ` + item + `, "PlayState": {"PlayMethod": "DirectStream"}}`,
+			wantDevice:   "Infuse",
+			wantPlayback: "Direct stream",
+		},
+		{
+			name:         "transcoding video while paused",
+			session:      `{"Client": "Jellyfin Web", ` + item + `, "PlayState": {"PositionTicks": 250, "IsPaused": true, "PlayMethod": "Transcode"}, "TranscodingInfo": {"IsVideoDirect": false, "IsAudioDirect": true}}`,
+			wantDevice:   "Jellyfin Web",
+			wantPlayback: "Transcoding video",
+			wantProgress: 25,
+			wantPaused:   true,
+		},
+		{
+			name:         "transcoding audio",
+			session:      `{"Client": "Jellyfin Web", ` + item + `, "PlayState": {"PlayMethod": "Transcode"}, "TranscodingInfo": {"IsVideoDirect": true, "IsAudioDirect": false}}`,
+			wantDevice:   "Jellyfin Web",
+			wantPlayback: "Transcoding audio",
+		},
+		{
+			name:         "transcoding without details",
+			session:      `{"Client": "Jellyfin Web", ` + item + `, "PlayState": {"PlayMethod": "Transcode"}}`,
+			wantDevice:   "Jellyfin Web",
+			wantPlayback: "Transcoding",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sessions []jellyfinSession
+			if err := json.Unmarshal([]byte(`[{"UserName": "idle"}, `+tt.session+`]`), &sessions); err != nil {
+				t.Fatal(err)
+			}
+
+			converted := jellyfinSessionsToMediaServerSessions(sessions)
+			if len(converted) != 1 {
+				t.Fatalf("got %d sessions, want idle sessions to be skipped", len(converted))
+			}
+
+			session := converted[0]
+			if session.Device != tt.wantDevice || session.PlaybackText != tt.wantPlayback {
+				t.Errorf("session = %q, %q, want %q, %q", session.Device, session.PlaybackText, tt.wantDevice, tt.wantPlayback)
+			}
+
+			if session.ProgressPercent != tt.wantProgress || session.IsPaused != tt.wantPaused || session.IsTranscoding != strings.HasPrefix(tt.wantPlayback, "Transcoding") {
+				t.Errorf("session = %+v, want progress %d and paused %v", session, tt.wantProgress, tt.wantPaused)
+			}
+		})
+	}
+}
+
+func TestPlexItemTitlesAndPosterPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		item         plexMetadata
+		wantTitle    string
+		wantSubtitle string
+		wantThumb    string
+	}{
+		{
+			name:         "movie",
+			item:         plexMetadata{Type: "movie", Title: "A Movie", Year: 2024, Thumb: "/library/metadata/1/thumb/1"},
+			wantTitle:    "A Movie",
+			wantSubtitle: "2024",
+			wantThumb:    "/library/metadata/1/thumb/1",
+		},
+		{
+			name:         "episode with the poster of its show",
+			item:         plexMetadata{Type: "episode", Title: "Pilot", GrandparentTitle: "The Show", ParentIndex: 1, Index: 2, Thumb: "/ep", GrandparentThumb: "/show"},
+			wantTitle:    "The Show",
+			wantSubtitle: "S01E02 · Pilot",
+			wantThumb:    "/show",
+		},
+		{
+			name:         "season",
+			item:         plexMetadata{Type: "season", Title: "Season 2", ParentTitle: "The Show", Thumb: "/season"},
+			wantTitle:    "The Show",
+			wantSubtitle: "Season 2",
+			wantThumb:    "/season",
+		},
+		{
+			name:         "track with the cover of its album",
+			item:         plexMetadata{Type: "track", Title: "A Song", GrandparentTitle: "The Band", Thumb: "/track", ParentThumb: "/album"},
+			wantTitle:    "A Song",
+			wantSubtitle: "The Band",
+			wantThumb:    "/album",
+		},
+		{
+			name:         "album without a cover",
+			item:         plexMetadata{Type: "album", Title: "An Album", ParentTitle: "The Band"},
+			wantTitle:    "An Album",
+			wantSubtitle: "The Band",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, subtitle := plexItemTitles(&tt.item)
+			if title != tt.wantTitle || subtitle != tt.wantSubtitle {
+				t.Errorf("plexItemTitles() = %q, %q, want %q, %q", title, subtitle, tt.wantTitle, tt.wantSubtitle)
+			}
+
+			poster := plexPosterPath(&tt.item)
+			if tt.wantThumb == "" && poster != "" {
+				t.Errorf("plexPosterPath() = %q, want none", poster)
+			} else if tt.wantThumb != "" && poster != "/photo/:/transcode?width=200&height=300&minSize=1&upscale=1&url="+strings.ReplaceAll(tt.wantThumb, "/", "%2F") {
+				t.Errorf("plexPosterPath() = %q, want a resized %s", poster, tt.wantThumb)
+			}
+		})
+	}
+}
+
+func TestPlexMetadataToMediaServerSessions(t *testing.T) {
+	tests := []struct {
+		name          string
+		metadata      string
+		wantUser      string
+		wantDevice    string
+		wantPlayback  string
+		wantPaused    bool
+		wantProgress  int
+		wantTranscode bool
+	}{
+		{
+			name:         "direct play",
+			metadata:     `{"duration": 1000, "viewOffset": 500, "User": {"title": "bob"}, "Player": {"product": "Plex Web", "title": "Chrome", "state": "playing"}}`,
+			wantUser:     "bob",
+			wantDevice:   "Plex Web on Chrome",
+			wantPlayback: "Direct play",
+			wantProgress: 50,
+		},
+		{
+			name:         "direct stream while paused",
+			metadata:     `{"Player": {"product": "Plex for Android", "state": "paused"}, "TranscodeSession": {"videoDecision": "copy", "audioDecision": "copy"}}`,
+			wantDevice:   "Plex for Android",
+			wantPlayback: "Direct stream",
+			wantPaused:   true,
+		},
+		{
+			name:          "transcoding video",
+			metadata:      `{"TranscodeSession": {"videoDecision": "transcode", "audioDecision": "transcode"}}`,
+			wantPlayback:  "Transcoding video",
+			wantTranscode: true,
+		},
+		{
+			name:          "transcoding audio",
+			metadata:      `{"TranscodeSession": {"videoDecision": "copy", "audioDecision": "transcode"}}`,
+			wantPlayback:  "Transcoding audio",
+			wantTranscode: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata []plexMetadata
+			if err := json.Unmarshal([]byte(`[`+tt.metadata+`]`), &metadata); err != nil {
+				t.Fatal(err)
+			}
+
+			session := plexMetadataToMediaServerSessions(metadata)[0]
+
+			if session.User != tt.wantUser || session.Device != tt.wantDevice || session.PlaybackText != tt.wantPlayback {
+				t.Errorf("session = %q, %q, %q, want %q, %q, %q", session.User, session.Device, session.PlaybackText, tt.wantUser, tt.wantDevice, tt.wantPlayback)
+			}
+
+			if session.IsPaused != tt.wantPaused || session.ProgressPercent != tt.wantProgress || session.IsTranscoding != tt.wantTranscode {
+				t.Errorf("session = %+v, want paused %v, progress %d, transcoding %v", session, tt.wantPaused, tt.wantProgress, tt.wantTranscode)
+			}
+		})
+	}
+}
+
+func TestFetchPlexLibraryCounts(t *testing.T) {
+	url := startFakeAPIServer(t, "X-Plex-Token: secret", map[string]string{
+		"GET /library/sections":       `{"MediaContainer": {"Directory": [{"key": "1", "type": "movie"}, {"key": "2", "type": "show"}, {"key": "3", "type": "photo"}, {"key": "4", "type": "movie"}]}}`,
+		"GET /library/sections/1/all": `{"MediaContainer": {"totalSize": 12}}`,
+		"GET /library/sections/2/all": `{"MediaContainer": {"totalSize": 30}}`,
+		"GET /library/sections/4/all": `{"MediaContainer": {"totalSize": 8}}`,
+	})
+
+	libraries, err := fetchPlexLibraryCounts(&mediaServerWidget{Provider: mediaServerProviderPlex, URL: url, Token: "secret"})
+	if err != nil {
+		t.Fatalf("fetchPlexLibraryCounts() error = %v", err)
+	}
+
+	// the counts of both movie libraries are added up and photo libraries are skipped
+	want := []mediaServerLibrary{{"Movies", 20}, {"Shows", 30}, {"Episodes", 30}}
+	if !reflect.DeepEqual(libraries, want) {
+		t.Errorf("fetchPlexLibraryCounts() = %v, want %v", libraries, want)
+	}
+}
+
+// Posters are proxied so that neither the URL nor the token of the server reach the browser
+func TestMediaServerWidgetHandleRequest(t *testing.T) {
+	url := startFakeAPIServer(t, `Authorization: MediaBrowser Client="Glance", Token="secret"`, map[string]string{
+		"GET /Sessions":                    `[]`,
+		"GET /Items":                       `{"Items": [{"Id": "movie1", "Name": "A Movie", "Type": "Movie", "ImageTags": {"Primary": "def"}}]}`,
+		"GET /Items/Counts":                `{"MovieCount": 1200}`,
+		"GET /Items/movie1/Images/Primary": "GIF89a",
+	})
+
+	widget := &mediaServerWidget{Provider: mediaServerProviderJellyfin, URL: url, Token: "secret"}
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	widget.update(t.Context())
+	if widget.Error != nil || len(widget.RecentlyAdded) != 1 {
+		t.Fatalf("update() = %d items, error %v, want 1 item", len(widget.RecentlyAdded), widget.Error)
+	}
+
+	posterURL := widget.RecentlyAdded[0].PosterURL
+	if strings.Contains(posterURL, url) || strings.Contains(posterURL, "secret") {
+		t.Fatalf("poster URL = %s, want it proxied through the widget", posterURL)
+	}
+
+	key := posterURL[strings.LastIndex(posterURL, "/")+1:]
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"poster", http.MethodGet, "posters/" + key, http.StatusOK},
+		{"poster that wasn't shown", http.MethodGet, "posters/unknown", http.StatusNotFound},
+		{"poster with post", http.MethodPost, "posters/" + key, http.StatusMethodNotAllowed},
+		{"other resource", http.MethodGet, "Items/movie1/Images/Primary", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/api/widgets/1/"+tt.path, nil)
+			request.SetPathValue("path", tt.path)
+			recorder := httptest.NewRecorder()
+
+			widget.handleRequest(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+
+			if tt.wantStatus == http.StatusOK && (recorder.Body.String() != "GIF89a" || recorder.Header().Get("Content-Type") != "image/gif") {
+				t.Errorf("poster = %s %s, want it proxied", recorder.Header().Get("Content-Type"), recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,475 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	monitorCheckHTTP = "http"
+	monitorCheckTCP  = "tcp"
+	monitorCheckPing = "ping"
+	monitorCheckDNS  = "dns"
+)
+
+const monitorPingFallbackPort = "80"
+
+var monitorDNSRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT"}
+
+func (r *SiteStatusRequest) initializeCheck() error {
+	if err := r.Expect.initialize(); err != nil {
+		return err
+	}
+
+	r.Check = strings.ToLower(r.Check)
+
+	switch r.Check {
+	case "":
+		r.Check = monitorCheckHTTP
+	case monitorCheckHTTP:
+	case monitorCheckTCP:
+		if r.Host == "" {
+			return errors.New("host is required for tcp checks")
+		}
+
+		if _, _, err := net.SplitHostPort(r.Host); err != nil {
+			return fmt.Errorf("host for tcp checks must include a port: %v", err)
+		}
+	case monitorCheckPing:
+		if r.Host == "" {
+			return errors.New("host is required for ping checks")
+		}
+	case monitorCheckDNS:
+		if r.Host == "" {
+			return errors.New("host is required for dns checks")
+		}
+
+		r.RecordType = strings.ToUpper(r.RecordType)
+		if r.RecordType == "" {
+			r.RecordType = "A"
+		} else if !slices.Contains(monitorDNSRecordTypes, r.RecordType) {
+			return fmt.Errorf("unsupported record type %s, must be one of %s", r.RecordType, strings.Join(monitorDNSRecordTypes, ", "))
+		}
+
+		if r.Server != "" {
+			if _, _, err := net.SplitHostPort(r.Server); err != nil {
+				r.Server = net.JoinHostPort(r.Server, "53")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown check type %s", r.Check)
+	}
+
+	return nil
+}
+
+func (r *SiteStatusRequest) isHTTPCheck() bool {
+	return r.Check == "" || r.Check == monitorCheckHTTP
+}
+
+func fetchTCPStatus(ctx context.Context, address string) siteStatus {
+	dialer := net.Dialer{}
+	start := time.Now()
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	status := siteStatus{ResponseTime: time.Since(start)}
+
+	if err != nil {
+		status.Error = err
+		status.TimedOut = isTimeoutError(err)
+		return status
+	}
+
+	conn.Close()
+	return status
+}
+
+var monitorPingSequence atomic.Uint32
+
+// Uses unprivileged ICMP sockets, which on Linux requires the group of the process to be
+// within net.ipv4.ping_group_range. When they're not available, such as when running as
+// a user outside of that range or on Windows, reachability is tested through TCP instead.
+func fetchPingStatus(ctx context.Context, host string) siteStatus {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, monitorPingFallbackPort
+	}
+
+	start := time.Now()
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return siteStatus{Error: err, TimedOut: isTimeoutError(err), ResponseTime: time.Since(start)}
+	}
+
+	if len(ips) == 0 {
+		return siteStatus{Error: fmt.Errorf("no addresses found for %s", hostname)}
+	}
+
+	ip := ips[0].IP
+	for i := range ips {
+		if ips[i].IP.To4() != nil {
+			ip = ips[i].IP
+			break
+		}
+	}
+
+	rtt, err := pingIP(ctx, ip)
+	if errors.Is(err, errICMPUnavailable) {
+		return fetchTCPReachabilityStatus(ctx, net.JoinHostPort(ip.String(), port))
+	}
+
+	if err != nil {
+		return siteStatus{Error: err, TimedOut: isTimeoutError(err), ResponseTime: time.Since(start)}
+	}
+
+	return siteStatus{ResponseTime: rtt}
+}
+
+var errICMPUnavailable = errors.New("unprivileged ICMP is not available")
+
+func pingIP(ctx context.Context, ip net.IP) (time.Duration, error) {
+	network, listenAddress, protocol := "udp4", "0.0.0.0", 1
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+
+	if ip.To4() == nil {
+		network, listenAddress, protocol = "udp6", "::", 58
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := icmp.ListenPacket(network, listenAddress)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errICMPUnavailable, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// the kernel replaces the ID with its own for unprivileged sockets, so replies are matched by their sequence
+	sequence := int(monitorPingSequence.Add(1) & 0xffff)
+	message := icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{Seq: sequence, Data: []byte("glance")},
+	}
+
+	encoded, err := message.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(encoded, &net.UDPAddr{IP: ip}); err != nil {
+		return 0, err
+	}
+
+	buffer := make([]byte, 1500)
+
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return 0, err
+		}
+
+		reply, err := icmp.ParseMessage(protocol, buffer[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == sequence {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// A refused connection still means that the host is up, it's just not listening on the port
+func fetchTCPReachabilityStatus(ctx context.Context, address string) siteStatus {
+	status := fetchTCPStatus(ctx, address)
+	if errors.Is(status.Error, syscall.ECONNREFUSED) {
+		status.Error = nil
+	}
+
+	return status
+}
+
+func fetchDNSStatus(ctx context.Context, request *SiteStatusRequest) siteStatus {
+	resolver := net.DefaultResolver
+
+	if request.Server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, network, request.Server)
+			},
+		}
+	}
+
+	start := time.Now()
+	answers, err := lookupDNSRecord(ctx, resolver, request.Host, request.RecordType)
+	status := siteStatus{ResponseTime: time.Since(start)}
+
+	if err != nil {
+		status.Error = err
+		status.TimedOut = isTimeoutError(err)
+		return status
+	}
+
+	if len(answers) == 0 {
+		status.Error = fmt.Errorf("no %s records found for %s", request.RecordType, request.Host)
+		return status
+	}
+
+	for _, expected := range request.ExpectedAnswers {
+		if !slices.Contains(answers, normalizeDNSAnswer(expected, request.RecordType)) {
+			status.Error = fmt.Errorf("expected answer %s not found, got %s", expected, strings.Join(answers, ", "))
+			return status
+		}
+	}
+
+	return status
+}
+
+func lookupDNSRecord(ctx context.Context, resolver *net.Resolver, host, recordType string) ([]string, error) {
+	var answers []string
+
+	switch recordType {
+	case "A", "AAAA":
+		ips, err := resolver.LookupNetIP(ctx, ternary(recordType == "A", "ip4", "ip6"), host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ips {
+			answers = append(answers, ip.Unmap().String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		answers = append(answers, cname)
+	case "MX":
+		records, err := resolver.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			answers = append(answers, record.Host)
+		}
+	case "NS":
+		records, err := resolver.LookupNS(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			answers = append(answers, record.Host)
+		}
+	case "TXT":
+		records, err := resolver.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		answers = records
+	}
+
+	for i := range answers {
+		answers[i] = normalizeDNSAnswer(answers[i], recordType)
+	}
+
+	return answers, nil
+}
+
+// TXT records are compared as is, everything else is an IP or a hostname
+func normalizeDNSAnswer(answer, recordType string) string {
+	if recordType == "TXT" {
+		return answer
+	}
+
+	return strings.ToLower(strings.TrimSuffix(answer, "."))
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+const siteStatusExpectationsMaxBodySize = 1 << 20 // 1MB
+
+type siteStatusExpectations struct {
+	StatusCodes  []statusCodeRangeField `yaml:"status-codes"`
+	BodyContains string                 `yaml:"body-contains"`
+	BodyRegex    string                 `yaml:"body-regex"`
+	JSON         []struct {
+		Path   string `yaml:"path"`
+		Equals string `yaml:"equals"`
+	} `yaml:"json"`
+	// A header with an empty value only has to be present
+	Headers         map[string]string `yaml:"headers"`
+	MaxResponseTime durationField     `yaml:"max-response-time"`
+	bodyRegex       *regexp.Regexp
+}
+
+func (e *siteStatusExpectations) initialize() error {
+	if e.BodyRegex != "" {
+		compiled, err := regexp.Compile(e.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("compiling body-regex: %v", err)
+		}
+
+		e.bodyRegex = compiled
+	}
+
+	for i := range e.JSON {
+		if e.JSON[i].Path == "" {
+			return errors.New("json expectations must have a path")
+		}
+	}
+
+	return nil
+}
+
+func (e *siteStatusExpectations) needsBody() bool {
+	return e.BodyContains != "" || e.bodyRegex != nil || len(e.JSON) > 0
+}
+
+// Returns a short description of the first expectation that the response doesn't meet,
+// or an empty string if it meets all of them
+func (e *siteStatusExpectations) checkResponse(response *http.Response) string {
+	for name, expected := range e.Headers {
+		values, exists := response.Header[http.CanonicalHeaderKey(name)]
+		if !exists {
+			return "Missing header " + name
+		}
+
+		if expected != "" && !slices.Contains(values, expected) {
+			return "Unexpected " + name + " header"
+		}
+	}
+
+	if !e.needsBody() {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, siteStatusExpectationsMaxBodySize))
+	if err != nil {
+		return "Could not read body"
+	}
+
+	if e.BodyContains != "" && !bytes.Contains(body, []byte(e.BodyContains)) {
+		return "Body missing expected text"
+	}
+
+	if e.bodyRegex != nil && !e.bodyRegex.Match(body) {
+		return "Body does not match regex"
+	}
+
+	if len(e.JSON) > 0 {
+		if !gjson.ValidBytes(body) {
+			return "Invalid JSON"
+		}
+
+		for i := range e.JSON {
+			result := gjson.GetBytes(body, e.JSON[i].Path)
+			if !result.Exists() {
+				return "Missing " + e.JSON[i].Path
+			}
+
+			if result.String() != e.JSON[i].Equals {
+				return fmt.Sprintf("%s is %s", e.JSON[i].Path, result.String())
+			}
+		}
+	}
+
+	return ""
+}
+
+func (r *SiteStatusRequest) isAcceptedStatusCode(code int, altStatusCodes []int) bool {
+	if slices.Contains(altStatusCodes, code) {
+		return true
+	}
+
+	if len(r.Expect.StatusCodes) == 0 {
+		return code == 200
+	}
+
+	for i := range r.Expect.StatusCodes {
+		if r.Expect.StatusCodes[i].contains(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Either a single status code, a range such as 200-299 or a class such as 2xx
+type statusCodeRangeField struct {
+	min int
+	max int
+}
+
+func (f *statusCodeRangeField) contains(code int) bool {
+	return code >= f.min && code <= f.max
+}
+
+func (f *statusCodeRangeField) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if len(value) == 3 && strings.HasSuffix(value, "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil || class < 1 || class > 5 {
+			return fmt.Errorf("invalid status code class %s", value)
+		}
+
+		f.min, f.max = class*100, class*100+99
+		return nil
+	}
+
+	minValue, maxValue, isRange := strings.Cut(value, "-")
+
+	var err error
+	if f.min, err = strconv.Atoi(strings.TrimSpace(minValue)); err != nil {
+		return fmt.Errorf("invalid status code %s", value)
+	}
+
+	f.max = f.min
+	if isRange {
+		if f.max, err = strconv.Atoi(strings.TrimSpace(maxValue)); err != nil || f.max < f.min {
+			return fmt.Errorf("invalid status code range %s", value)
+		}
+	}
+
+	return nil
+}
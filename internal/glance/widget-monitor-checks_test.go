@@ -0,0 +1,365 @@
+package glance
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSiteStatusRequestInitializeCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        SiteStatusRequest
+		wantErr        bool
+		wantCheck      string
+		wantRecordType string
+		wantServer     string
+	}{
+		{name: "defaults to http", request: SiteStatusRequest{}, wantCheck: monitorCheckHTTP},
+		{name: "check is case insensitive", request: SiteStatusRequest{Check: "TCP", Host: "db:5432"}, wantCheck: monitorCheckTCP},
+		{name: "tcp without host", request: SiteStatusRequest{Check: "tcp"}, wantErr: true},
+		{name: "tcp without port", request: SiteStatusRequest{Check: "tcp", Host: "db"}, wantErr: true},
+		{name: "ping", request: SiteStatusRequest{Check: "ping", Host: "router"}, wantCheck: monitorCheckPing},
+		{name: "ping without host", request: SiteStatusRequest{Check: "ping"}, wantErr: true},
+		{name: "dns defaults to A records", request: SiteStatusRequest{Check: "dns", Host: "example.com"}, wantCheck: monitorCheckDNS, wantRecordType: "A"},
+		{name: "dns record type is uppercased", request: SiteStatusRequest{Check: "dns", Host: "example.com", RecordType: "mx"}, wantCheck: monitorCheckDNS, wantRecordType: "MX"},
+		{name: "dns unsupported record type", request: SiteStatusRequest{Check: "dns", Host: "example.com", RecordType: "SRV"}, wantErr: true},
+		{
+			name:           "dns server gets the default port",
+			request:        SiteStatusRequest{Check: "dns", Host: "example.com", Server: "1.1.1.1"},
+			wantCheck:      monitorCheckDNS,
+			wantRecordType: "A",
+			wantServer:     "1.1.1.1:53",
+		},
+		{
+			name:           "dns server keeps its port",
+			request:        SiteStatusRequest{Check: "dns", Host: "example.com", Server: "10.0.0.1:5353"},
+			wantCheck:      monitorCheckDNS,
+			wantRecordType: "A",
+			wantServer:     "10.0.0.1:5353",
+		},
+		{name: "unknown check", request: SiteStatusRequest{Check: "smtp"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.initializeCheck()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("initializeCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if tt.request.Check != tt.wantCheck || tt.request.RecordType != tt.wantRecordType || tt.request.Server != tt.wantServer {
+				t.Errorf("initializeCheck() = check %q, record type %q, server %q, want %q, %q, %q",
+					tt.request.Check, tt.request.RecordType, tt.request.Server, tt.wantCheck, tt.wantRecordType, tt.wantServer)
+			}
+		})
+	}
+}
+
+func TestNormalizeDNSAnswer(t *testing.T) {
+	tests := []struct {
+		answer     string
+		recordType string
+		want       string
+	}{
+		{"Mail.Example.com.", "MX", "mail.example.com"},
+		{"example.com", "CNAME", "example.com"},
+		{"2001:DB8::1", "AAAA", "2001:db8::1"},
+		{"v=spf1 Include:example.com.", "TXT", "v=spf1 Include:example.com."},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeDNSAnswer(tt.answer, tt.recordType); got != tt.want {
+			t.Errorf("normalizeDNSAnswer(%q, %q) = %q, want %q", tt.answer, tt.recordType, got, tt.want)
+		}
+	}
+}
+
+func TestFetchTCPStatus(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		fetch   func(context.Context, string) siteStatus
+		address string
+		wantErr bool
+	}{
+		{"open port", fetchTCPStatus, listener.Addr().String(), false},
+		{"closed port", fetchTCPStatus, closedAddress, true},
+		// a refused connection means the host is reachable, which is all that ping falls back to checking
+		{"closed port is reachable", fetchTCPReachabilityStatus, closedAddress, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			status := tt.fetch(ctx, tt.address)
+			if (status.Error != nil) != tt.wantErr {
+				t.Errorf("status error = %v, wantErr %v", status.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Answers every A query with 10.0.0.1 and 10.0.0.2 and every other query with no records
+func startFakeDNSServer(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) == 0 {
+				continue
+			}
+
+			question := query.Questions[0]
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RecursionAvailable: true},
+				Questions: query.Questions,
+			}
+
+			if question.Type == dnsmessage.TypeA {
+				for _, ip := range [][4]byte{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+					response.Answers = append(response.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+						Body:   &dnsmessage.AResource{A: ip},
+					})
+				}
+			}
+
+			packed, err := response.Pack()
+			if err == nil {
+				conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestFetchDNSStatus(t *testing.T) {
+	server := startFakeDNSServer(t)
+
+	tests := []struct {
+		name            string
+		recordType      string
+		expectedAnswers []string
+		wantErr         bool
+	}{
+		{"any answer", "A", nil, false},
+		{"expected answers present", "A", []string{"10.0.0.2", "10.0.0.1"}, false},
+		{"expected answer missing", "A", []string{"10.0.0.3"}, true},
+		{"no records", "AAAA", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &SiteStatusRequest{
+				Check:           monitorCheckDNS,
+				Host:            "service.test",
+				Server:          server,
+				RecordType:      tt.recordType,
+				ExpectedAnswers: tt.expectedAnswers,
+			}
+
+			if err := request.initializeCheck(); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			status := fetchDNSStatus(ctx, request)
+			if (status.Error != nil) != tt.wantErr {
+				t.Errorf("status error = %v, wantErr %v", status.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatusCodeRangeField(t *testing.T) {
+	tests := []struct {
+		value    string
+		accepted []int
+		rejected []int
+		wantErr  bool
+	}{
+		{value: "204", accepted: []int{204}, rejected: []int{200, 205}},
+		{value: "200-299", accepted: []int{200, 250, 299}, rejected: []int{199, 300}},
+		{value: " 3XX ", accepted: []int{300, 399}, rejected: []int{299, 400}},
+		{value: "6xx", wantErr: true},
+		{value: "300-200", wantErr: true},
+		{value: "ok", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var field statusCodeRangeField
+			err := yaml.Unmarshal([]byte(`"`+tt.value+`"`), &field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, code := range tt.accepted {
+				if !field.contains(code) {
+					t.Errorf("%q does not contain %d", tt.value, code)
+				}
+			}
+
+			for _, code := range tt.rejected {
+				if field.contains(code) {
+					t.Errorf("%q contains %d", tt.value, code)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteStatusExpectationsCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expect  string
+		headers http.Header
+		body    string
+		want    string
+	}{
+		{name: "no expectations", expect: `{}`, body: "anything", want: ""},
+		{name: "body contains", expect: `body-contains: healthy`, body: "status: healthy", want: ""},
+		{name: "body missing text", expect: `body-contains: healthy`, body: "status: sick", want: "Body missing expected text"},
+		{name: "body regex", expect: `body-regex: "^v[0-9]+"`, body: "v12", want: ""},
+		{name: "body not matching regex", expect: `body-regex: "^v[0-9]+"`, body: "beta", want: "Body does not match regex"},
+		{name: "json equals", expect: `json: [{path: status.db, equals: ok}]`, body: `{"status": {"db": "ok"}}`, want: ""},
+		{name: "json differs", expect: `json: [{path: status.db, equals: ok}]`, body: `{"status": {"db": "down"}}`, want: "status.db is down"},
+		{name: "json path missing", expect: `json: [{path: status.db, equals: ok}]`, body: `{}`, want: "Missing status.db"},
+		{name: "invalid json", expect: `json: [{path: status, equals: ok}]`, body: `<html>`, want: "Invalid JSON"},
+		{name: "header present", expect: `headers: {X-Version: ""}`, headers: http.Header{"X-Version": {"3"}}, want: ""},
+		{name: "header missing", expect: `headers: {X-Version: ""}`, want: "Missing header X-Version"},
+		{name: "header value", expect: `headers: {x-env: prod}`, headers: http.Header{"X-Env": {"staging"}}, want: "Unexpected x-env header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expect siteStatusExpectations
+			if err := yaml.Unmarshal([]byte(tt.expect), &expect); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := expect.initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			response := &http.Response{
+				Header: tt.headers,
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if response.Header == nil {
+				response.Header = http.Header{}
+			}
+
+			if got := expect.checkResponse(response); got != tt.want {
+				t.Errorf("checkResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteStatusExpectationsInitializeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect siteStatusExpectations
+	}{
+		{"invalid regex", siteStatusExpectations{BodyRegex: "("}},
+		{"json without path", siteStatusExpectations{JSON: []struct {
+			Path   string `yaml:"path"`
+			Equals string `yaml:"equals"`
+		}{{Equals: "ok"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.expect.initialize(); err == nil {
+				t.Error("initialize() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestMonitorSiteEvaluateStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		expect        string
+		altCodes      []int
+		status        siteStatus
+		inMaintenance bool
+		wantText      string
+		wantStyle     string
+		wantFailing   bool
+	}{
+		{name: "ok", status: siteStatus{Code: 200}, wantText: "OK", wantStyle: "ok"},
+		{name: "server error", status: siteStatus{Code: 503}, wantText: "Server Error", wantStyle: "error", wantFailing: true},
+		{name: "redirect without expected codes isn't failing", status: siteStatus{Code: 302}, wantText: "302", wantStyle: "error"},
+		{name: "redirect with expected codes is failing", expect: `status-codes: [2xx]`, status: siteStatus{Code: 302}, wantText: "302", wantStyle: "error", wantFailing: true},
+		{name: "expected status code", expect: `status-codes: [200-299]`, status: siteStatus{Code: 204}, wantText: "OK", wantStyle: "ok"},
+		{name: "alt status code", altCodes: []int{401}, status: siteStatus{Code: 401}, wantText: "OK", wantStyle: "ok"},
+		{name: "unmet expectation", status: siteStatus{Code: 200, FailureReason: "Invalid JSON"}, wantText: "Invalid JSON", wantStyle: "error", wantFailing: true},
+		{name: "slow response is degraded", expect: `max-response-time: 1s`, status: siteStatus{Code: 200, ResponseTime: 2 * time.Second}, wantText: "Degraded", wantStyle: "degraded"},
+		{name: "fast response", expect: `max-response-time: 1s`, status: siteStatus{Code: 200, ResponseTime: time.Millisecond}, wantText: "OK", wantStyle: "ok"},
+		{name: "maintenance is never failing", status: siteStatus{Code: 500}, inMaintenance: true, wantText: "Maintenance", wantStyle: "maintenance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := monitorSite{SiteStatusRequest: &SiteStatusRequest{}, AltStatusCodes: tt.altCodes}
+			if tt.expect != "" {
+				if err := yaml.Unmarshal([]byte(tt.expect), &site.Expect); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := site.initializeCheck(); err != nil {
+				t.Fatal(err)
+			}
+
+			text, style, failing := site.evaluateStatus(&tt.status, tt.inMaintenance)
+			if text != tt.wantText || style != tt.wantStyle || failing != tt.wantFailing {
+				t.Errorf("evaluateStatus() = %q, %q, %v, want %q, %q, %v", text, style, failing, tt.wantText, tt.wantStyle, tt.wantFailing)
+			}
+		})
+	}
+}
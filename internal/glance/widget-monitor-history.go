@@ -0,0 +1,373 @@
+package glance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	monitorHistoryFileName = "monitor-history.json"
+	// How many of the most recent results are kept for the sparkline and the results strip
+	monitorHistoryRecentResults = 40
+	monitorHistoryMaxHours      = 30 * 24
+	monitorHistorySaveInterval  = time.Minute
+)
+
+const (
+	monitorResultUp       = "up"
+	monitorResultDegraded = "degraded"
+	monitorResultDown     = "down"
+	// Results from during maintenance windows, which aren't counted towards uptime
+	monitorResultMaintenance = "maintenance"
+)
+
+type monitorCheckResult struct {
+	Time         int64  `json:"t"`
+	ResponseTime int64  `json:"r"` // milliseconds
+	State        string `json:"s"`
+}
+
+// The number of checks made within an hour along with how many of them weren't down,
+// keeping these rather than every result is enough to calculate uptime for long periods
+type monitorHourlyUptime struct {
+	Hour   int64 `json:"h"` // hours since the unix epoch
+	Checks int   `json:"c"`
+	Up     int   `json:"u"`
+}
+
+type monitorSiteHistory struct {
+	Recent []monitorCheckResult  `json:"recent"`
+	Hourly []monitorHourlyUptime `json:"hourly"`
+}
+
+type monitorLatestStatus struct {
+	status    siteStatus
+	checkedAt time.Time
+}
+
+// Shared between all monitor widgets and kept across config reloads. Sites are identified by what
+// gets checked rather than by the widget they're in, so that their history survives changes to
+// anything else, such as their title or the page they're on.
+var monitorHistory = &monitorHistoryStore{
+	sites:  make(map[string]*monitorSiteHistory),
+	latest: make(map[string]*monitorLatestStatus),
+}
+
+type monitorHistoryStore struct {
+	mu       sync.Mutex
+	filePath string
+	sites    map[string]*monitorSiteHistory
+	latest   map[string]*monitorLatestStatus
+	dirty    bool
+	lastSave time.Time
+	// Avoids logging the same error every time the history gets saved
+	lastSaveFailed bool
+}
+
+// Includes everything that affects the result of checking the site, so that sites which have the
+// same URL but different options don't get each other's results
+func monitorSiteHistoryKey(site *monitorSite) string {
+	request := site.SiteStatusRequest
+	var key strings.Builder
+
+	write := func(values ...any) {
+		for _, value := range values {
+			fmt.Fprint(&key, value, "\x00")
+		}
+	}
+
+	if request.isHTTPCheck() {
+		write(
+			monitorCheckHTTP,
+			ternary(request.CheckURL != "", request.CheckURL, request.DefaultURL),
+			request.AllowInsecure,
+			request.BasicAuth.Username,
+			request.BasicAuth.Password,
+		)
+
+		expect := &request.Expect
+		write(len(expect.StatusCodes))
+		for i := range expect.StatusCodes {
+			write(expect.StatusCodes[i].min, expect.StatusCodes[i].max)
+		}
+
+		write(expect.BodyContains, expect.BodyRegex, len(expect.JSON))
+		for i := range expect.JSON {
+			write(expect.JSON[i].Path, expect.JSON[i].Equals)
+		}
+
+		headers := slices.Sorted(maps.Keys(expect.Headers))
+		write(len(headers))
+		for _, name := range headers {
+			write(strings.ToLower(name), expect.Headers[name])
+		}
+
+		write(len(site.AltStatusCodes))
+		for _, code := range slices.Sorted(slices.Values(site.AltStatusCodes)) {
+			write(code)
+		}
+	} else {
+		write(request.Check, request.Host, request.Server, request.RecordType, len(request.ExpectedAnswers))
+		for _, answer := range slices.Sorted(slices.Values(request.ExpectedAnswers)) {
+			write(answer)
+		}
+	}
+
+	write(time.Duration(request.Timeout), time.Duration(request.Expect.MaxResponseTime))
+
+	hash := sha256.Sum256([]byte(key.String()))
+	return hex.EncodeToString(hash[:16])
+}
+
+// Loads the history from the given file unless it has already been loaded from it, histories
+// that were recorded before a file was opened are replaced by the ones in the file
+func (s *monitorHistoryStore) open(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.filePath == filePath {
+		return
+	}
+
+	if s.filePath != "" && s.dirty {
+		s.saveLocked()
+	}
+
+	s.filePath = filePath
+	s.lastSaveFailed = false
+
+	contents, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to read monitor history: %v", err)
+		return
+	}
+
+	var sites map[string]*monitorSiteHistory
+	if err := json.Unmarshal(contents, &sites); err != nil {
+		log.Printf("Failed to parse monitor history, starting with an empty one: %v", err)
+		return
+	}
+
+	for key, history := range sites {
+		if history != nil {
+			s.sites[key] = history
+		}
+	}
+}
+
+// Kept for every site, including ones whose history isn't recorded, so that sites which are
+// in multiple widgets or on status pages don't get checked more often than needed
+func (s *monitorHistoryStore) setLatestStatus(key string, status siteStatus, checkedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest[key] = &monitorLatestStatus{status: status, checkedAt: checkedAt}
+}
+
+func (s *monitorHistoryStore) record(key string, status siteStatus, state string, checkedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, exists := s.sites[key]
+	if !exists {
+		history = &monitorSiteHistory{}
+		s.sites[key] = history
+	}
+
+	history.Recent = append(history.Recent, monitorCheckResult{
+		Time:         checkedAt.Unix(),
+		ResponseTime: status.ResponseTime.Milliseconds(),
+		State:        state,
+	})
+
+	if len(history.Recent) > monitorHistoryRecentResults {
+		history.Recent = history.Recent[len(history.Recent)-monitorHistoryRecentResults:]
+	}
+
+	s.dirty = true
+
+	if state == monitorResultMaintenance {
+		return
+	}
+
+	hour := checkedAt.Unix() / 3600
+	if len(history.Hourly) == 0 || history.Hourly[len(history.Hourly)-1].Hour != hour {
+		history.Hourly = append(history.Hourly, monitorHourlyUptime{Hour: hour})
+	}
+
+	current := &history.Hourly[len(history.Hourly)-1]
+	current.Checks++
+	if state != monitorResultDown {
+		current.Up++
+	}
+
+	oldestHour := hour - monitorHistoryMaxHours
+	for len(history.Hourly) > 0 && history.Hourly[0].Hour <= oldestHour {
+		history.Hourly = history.Hourly[1:]
+	}
+}
+
+// Returns the latest status of the site if it was checked more recently than maxAge
+func (s *monitorHistoryStore) latestStatus(key string, maxAge time.Duration) (siteStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest, exists := s.latest[key]
+	if !exists || time.Since(latest.checkedAt) >= maxAge {
+		return siteStatus{}, false
+	}
+
+	return latest.status, true
+}
+
+func (s *monitorHistoryStore) history(key string) monitorSiteHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, exists := s.sites[key]
+	if !exists {
+		return monitorSiteHistory{}
+	}
+
+	return monitorSiteHistory{
+		Recent: append([]monitorCheckResult(nil), history.Recent...),
+		Hourly: append([]monitorHourlyUptime(nil), history.Hourly...),
+	}
+}
+
+func (s *monitorHistoryStore) saveIfDirty(force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty || s.filePath == "" {
+		return
+	}
+
+	if !force && time.Since(s.lastSave) < monitorHistorySaveInterval {
+		return
+	}
+
+	s.saveLocked()
+}
+
+func (s *monitorHistoryStore) saveLocked() {
+	s.lastSave = time.Now()
+
+	// sites that haven't been checked for longer than the history is kept for
+	// have most likely been removed from the config, so there's no point in keeping them
+	oldestHour := time.Now().Unix()/3600 - monitorHistoryMaxHours
+	for key, history := range s.sites {
+		if len(history.Hourly) == 0 || history.Hourly[len(history.Hourly)-1].Hour <= oldestHour {
+			delete(s.sites, key)
+		}
+	}
+
+	contents, err := json.Marshal(s.sites)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.filePath), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(s.filePath, contents, 0o644)
+	}
+
+	if err != nil {
+		if !s.lastSaveFailed {
+			log.Printf("Failed to save monitor history, it will be lost on restart: %v", err)
+		}
+
+		s.lastSaveFailed = true
+		return
+	}
+
+	s.lastSaveFailed = false
+	s.dirty = false
+}
+
+type monitorUptime struct {
+	Label   string
+	Percent string
+}
+
+type monitorSiteHistoryData struct {
+	Uptime          []monitorUptime
+	Recent          []monitorCheckResult
+	SparklinePoints string
+}
+
+var monitorUptimePeriods = []struct {
+	label string
+	hours int64
+}{
+	{"24h", 24},
+	{"7d", 7 * 24},
+	{"30d", 30 * 24},
+}
+
+func (h *monitorSiteHistory) templateData(now time.Time) *monitorSiteHistoryData {
+	data := &monitorSiteHistoryData{Recent: h.Recent}
+	currentHour := now.Unix() / 3600
+
+	for _, period := range monitorUptimePeriods {
+		checks, up := 0, 0
+
+		for i := range h.Hourly {
+			if h.Hourly[i].Hour > currentHour-period.hours {
+				checks += h.Hourly[i].Checks
+				up += h.Hourly[i].Up
+			}
+		}
+
+		percent := "-"
+		if checks > 0 {
+			percent = formatUptimePercent(float64(up) / float64(checks) * 100)
+		}
+
+		data.Uptime = append(data.Uptime, monitorUptime{Label: period.label, Percent: percent})
+	}
+
+	responseTimes := make([]float64, 0, len(h.Recent))
+	for i := range h.Recent {
+		if h.Recent[i].State == monitorResultUp || h.Recent[i].State == monitorResultDegraded {
+			responseTimes = append(responseTimes, float64(h.Recent[i].ResponseTime))
+		}
+	}
+
+	data.SparklinePoints = svgPolylineCoordsFromYValues(100, 20, responseTimes)
+
+	return data
+}
+
+// Never rounds up to 100% so that any amount of downtime is visible
+func formatUptimePercent(percent float64) string {
+	if percent >= 100 {
+		return "100%"
+	}
+
+	truncated := float64(int(percent*100)) / 100
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", truncated), "0"), ".") + "%"
+}
+
+func (r monitorCheckResult) Title() string {
+	checkedAt := time.Unix(r.Time, 0).Format("Jan 2 15:04")
+
+	if r.State == monitorResultDown || r.State == monitorResultMaintenance {
+		return checkedAt + " - " + r.State
+	}
+
+	return fmt.Sprintf("%s - %s, %dms", checkedAt, r.State, r.ResponseTime)
+}
@@ -0,0 +1,67 @@
+package glance
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMonitorSiteHistoryKey(t *testing.T) {
+	base := `
+url: https://example.com
+title: Example
+`
+
+	tests := []struct {
+		name     string
+		site     string
+		wantSame bool
+	}{
+		{"identical", base, true},
+		{"different title and icon", "url: https://example.com\ntitle: Other\nicon: si:github", true},
+		{"header expectation", "url: https://example.com\nexpect: {headers: {a: '1', b: '2'}}", false},
+		{"different url", "url: https://example.org", false},
+		{"check-url takes precedence", "url: https://other.com\ncheck-url: https://example.com", true},
+		{"basic auth", "url: https://example.com\nbasic-auth: {username: admin, password: secret}", false},
+		{"allow insecure", "url: https://example.com\nallow-insecure: true", false},
+		{"timeout", "url: https://example.com\ntimeout: 10s", false},
+		{"status codes", "url: https://example.com\nexpect: {status-codes: [2xx]}", false},
+		{"alt status codes", "url: https://example.com\nalt-status-codes: [401]", false},
+		{"body expectation", "url: https://example.com\nexpect: {body-contains: ok}", false},
+		{"json expectation", "url: https://example.com\nexpect: {json: [{path: status, equals: ok}]}", false},
+		{"max response time", "url: https://example.com\nexpect: {max-response-time: 1s}", false},
+		{"tcp check", "check: tcp\nhost: example.com:443", false},
+	}
+
+	parse := func(t *testing.T, contents string) *monitorSite {
+		t.Helper()
+
+		var site monitorSite
+		if err := yaml.Unmarshal([]byte(contents), &site); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := site.initializeCheck(); err != nil {
+			t.Fatal(err)
+		}
+
+		return &site
+	}
+
+	baseKey := monitorSiteHistoryKey(parse(t, base))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := monitorSiteHistoryKey(parse(t, tt.site)) == baseKey; same != tt.wantSame {
+				t.Errorf("same key = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	// the order of headers and alt status codes in the config doesn't matter
+	a := parse(t, "url: https://example.com\nalt-status-codes: [401, 403]\nexpect: {headers: {a: '1', b: '2'}}")
+	b := parse(t, "url: https://example.com\nalt-status-codes: [403, 401]\nexpect: {headers: {b: '2', a: '1'}}")
+	if monitorSiteHistoryKey(a) != monitorSiteHistoryKey(b) {
+		t.Error("expected the order of headers and alt status codes to not change the key")
+	}
+}
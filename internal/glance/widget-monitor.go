@@ -3,10 +3,12 @@ package glance
 import (
 	"context"
 	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
-	"slices"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -16,63 +18,262 @@ var (
 )
 
 type monitorWidget struct {
-	widgetBase `yaml:",inline"`
-	Sites      []struct {
-		*SiteStatusRequest `yaml:",inline"`
-		Status             *siteStatus     `yaml:"-"`
-		URL                string          `yaml:"-"`
-		ErrorURL           string          `yaml:"error-url"`
-		Title              string          `yaml:"title"`
-		Icon               customIconField `yaml:"icon"`
-		SameTab            bool            `yaml:"same-tab"`
-		StatusText         string          `yaml:"-"`
-		StatusStyle        string          `yaml:"-"`
-		AltStatusCodes     []int           `yaml:"alt-status-codes"`
-	} `yaml:"sites"`
-	Style           string `yaml:"style"`
-	ShowFailingOnly bool   `yaml:"show-failing-only"`
-	HasFailing      bool   `yaml:"-"`
+	widgetBase      `yaml:",inline"`
+	Sites           []monitorSite      `yaml:"sites"`
+	Style           string             `yaml:"style"`
+	ShowFailingOnly bool               `yaml:"show-failing-only"`
+	ShowHistory     bool               `yaml:"show-history"`
+	Notify          *notificationRule  `yaml:"notify"`
+	Maintenance     maintenanceWindows `yaml:"maintenance"`
+	HasFailing      bool               `yaml:"-"`
+	// Set when any of the sites are shown on a status page, which needs their history regardless of show-history
+	onStatusPage bool
+	// Prevents the same sites from being checked by a page view and in the background at the same time
+	checkMu sync.Mutex
+}
+
+type monitorSite struct {
+	*SiteStatusRequest `yaml:",inline"`
+	Status             *siteStatus             `yaml:"-"`
+	URL                string                  `yaml:"-"`
+	ErrorURL           string                  `yaml:"error-url"`
+	Title              string                  `yaml:"title"`
+	Icon               customIconField         `yaml:"icon"`
+	SameTab            bool                    `yaml:"same-tab"`
+	StatusText         string                  `yaml:"-"`
+	StatusStyle        string                  `yaml:"-"`
+	AltStatusCodes     []int                   `yaml:"alt-status-codes"`
+	History            *monitorSiteHistoryData `yaml:"-"`
+	historyKey         string
 }
 
 func (widget *monitorWidget) initialize() error {
 	widget.withTitle("Monitor").withCacheDuration(5 * time.Minute)
 
-	return nil
+	for i := range widget.Sites {
+		site := &widget.Sites[i]
+		if site.SiteStatusRequest == nil {
+			site.SiteStatusRequest = &SiteStatusRequest{}
+		}
+
+		if err := site.initializeCheck(); err != nil {
+			return fmt.Errorf("site %s: %v", ternary(site.Title != "", site.Title, strconv.Itoa(i+1)), err)
+		}
+
+		site.historyKey = monitorSiteHistoryKey(site)
+	}
+
+	if err := widget.Maintenance.initialize(); err != nil {
+		return err
+	}
+
+	return widget.Notify.initialize(2, monitorResultUp, monitorResultDegraded, monitorResultDown)
 }
 
-func (widget *monitorWidget) update(ctx context.Context) {
-	requests := make([]*SiteStatusRequest, len(widget.Sites))
+func (widget *monitorWidget) recordsHistory() bool {
+	return widget.ShowHistory || widget.onStatusPage
+}
+
+func (widget *monitorWidget) notificationRule() *notificationRule {
+	return widget.Notify
+}
+
+// Checks the sites on the same interval as the widget's cache duration so that their history gets
+// recorded and notifications get sent even when no one is viewing the page that the widget is on
+func (widget *monitorWidget) runInBackground(ctx context.Context, app *application) {
+	if !widget.recordsHistory() && !widget.Notify.isActive() {
+		return
+	}
+
+	if widget.recordsHistory() {
+		monitorHistory.open(filepath.Join(app.dataPath(), monitorHistoryFileName))
+		defer monitorHistory.saveIfDirty(true)
+	}
+
+	if widget.Notify.isActive() {
+		notificationStates.open(filepath.Join(app.dataPath(), notificationStatesFileName))
+		defer notificationStates.saveIfDirty(true)
+	}
+
+	interval := widget.cacheDuration
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// leave some leeway so that sites which were checked by a page view
+		// shortly after the previous tick don't get skipped until the next one
+		if _, err := widget.checkSites(interval * 9 / 10); err == nil {
+			monitorHistory.saveIfDirty(false)
+			notificationStates.saveIfDirty(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns the status of every site, only checking the ones that haven't been checked within maxAge
+func (widget *monitorWidget) checkSites(maxAge time.Duration) ([]siteStatus, error) {
+	widget.checkMu.Lock()
+	defer widget.checkMu.Unlock()
+
+	statuses := make([]siteStatus, len(widget.Sites))
+	var outdated []int
+	var requests []*SiteStatusRequest
 
 	for i := range widget.Sites {
-		requests[i] = widget.Sites[i].SiteStatusRequest
+		if status, ok := monitorHistory.latestStatus(widget.Sites[i].historyKey, maxAge); ok {
+			statuses[i] = status
+			continue
+		}
+
+		outdated = append(outdated, i)
+		requests = append(requests, widget.Sites[i].SiteStatusRequest)
 	}
 
-	statuses, err := fetchStatusForSites(requests)
+	if len(requests) == 0 {
+		return statuses, nil
+	}
+
+	results, err := fetchStatusForSites(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	checkedAt := time.Now()
+
+	for r, i := range outdated {
+		site := &widget.Sites[i]
+		statuses[i] = results[r]
+
+		inMaintenance := widget.Maintenance.covers(site.Title, checkedAt)
+		text, style, _ := site.evaluateStatus(&results[r], inMaintenance)
+		state := monitorStyleToResultState(style)
+		monitorHistory.setLatestStatus(site.historyKey, results[r], checkedAt)
+		if widget.recordsHistory() {
+			monitorHistory.record(site.historyKey, results[r], state, checkedAt)
+		}
+		widget.notifyOnStateChange(site, &results[r], text, state)
+	}
+
+	return statuses, nil
+}
+
+func (widget *monitorWidget) update(ctx context.Context) {
+	statuses, err := widget.checkSites(widget.cacheDuration)
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
 	}
 
 	widget.HasFailing = false
+	now := time.Now()
 
 	for i := range widget.Sites {
 		site := &widget.Sites[i]
 		status := &statuses[i]
 		site.Status = status
 
-		if !slices.Contains(site.AltStatusCodes, status.Code) && (status.Code >= 400 || status.Error != nil) {
-			widget.HasFailing = true
+		site.URL = ternary(status.Error != nil && site.ErrorURL != "", site.ErrorURL, site.DefaultURL)
+
+		var failing bool
+		inMaintenance := widget.Maintenance.covers(site.Title, now)
+		site.StatusText, site.StatusStyle, failing = site.evaluateStatus(status, inMaintenance)
+		// degraded sites get shown along with failing ones, so they can't be
+		// covered by the message saying that all sites are online either
+		widget.HasFailing = widget.HasFailing || failing || site.StatusStyle == "degraded"
+
+		if widget.ShowHistory {
+			history := monitorHistory.history(site.historyKey)
+			site.History = history.templateData(now)
 		}
+	}
+}
+
+// Returns the text and style to show for the status along with whether it should be considered failing
+func (site *monitorSite) evaluateStatus(status *siteStatus, inMaintenance bool) (string, string, bool) {
+	isAccepted := !site.isHTTPCheck() || site.isAcceptedStatusCode(status.Code, site.AltStatusCodes)
+	maxResponseTime := time.Duration(site.Expect.MaxResponseTime)
+
+	switch {
+	case inMaintenance:
+		// whatever state the site is in is expected, so it's never considered failing
+		return "Maintenance", statusCodeToStyle(status.Code, isAccepted, true), false
+	case status.Error != nil:
+		return "", "error", true
+	case !isAccepted:
+		// without explicitly specified status codes, redirects and such aren't considered failing
+		failing := status.Code >= 400 || len(site.Expect.StatusCodes) > 0
+		return statusCodeToText(status.Code, false), statusCodeToStyle(status.Code, false, false), failing
+	case status.FailureReason != "":
+		return status.FailureReason, "error", true
+	case maxResponseTime > 0 && status.ResponseTime > maxResponseTime:
+		return "Degraded", "degraded", false
+	}
+
+	return statusCodeToText(status.Code, true), statusCodeToStyle(status.Code, true, false), false
+}
+
+func (widget *monitorWidget) notifyOnStateChange(site *monitorSite, status *siteStatus, text string, state string) {
+	// the state during maintenance is expected, so it's neither notified about nor compared against
+	if !widget.Notify.isActive() || state == monitorResultMaintenance {
+		return
+	}
+
+	key := "monitor:" + site.historyKey
+	previous, changed := notificationStates.observe(key, state, widget.Notify.After)
+	if !changed {
+		return
+	}
 
-		if status.Error != nil && site.ErrorURL != "" {
-			site.URL = site.ErrorURL
-		} else {
-			site.URL = site.DefaultURL
+	name := site.Title
+	if name == "" {
+		name = ternary(site.DefaultURL != "", site.DefaultURL, site.Host)
+	}
+
+	n := notification{key: key, Event: state, Widget: widget.Title, URL: site.DefaultURL}
+	responseTime := fmt.Sprintf("%dms", status.ResponseTime.Milliseconds())
+
+	switch state {
+	case monitorResultDown:
+		n.Title = name + " is down"
+		switch {
+		case status.Error != nil:
+			n.Message = status.Error.Error()
+		case status.Code > 0 && text != "":
+			n.Message = fmt.Sprintf("%s (%d)", text, status.Code)
+		default:
+			n.Message = text
 		}
+	case monitorResultDegraded:
+		n.Title = name + " is degraded"
+		n.Message = "Responded in " + responseTime
+	default:
+		n.Title = name + " is up"
+		n.Message = "Was " + previous + ", responded in " + responseTime
+	}
 
-		site.StatusText = statusCodeToText(status.Code, site.AltStatusCodes)
-		site.StatusStyle = statusCodeToStyle(status.Code, site.AltStatusCodes)
+	widget.Notify.send(n)
+}
+
+func monitorStyleToResultState(style string) string {
+	switch style {
+	case "ok":
+		return monitorResultUp
+	case "degraded":
+		return monitorResultDegraded
+	case "maintenance":
+		return monitorResultMaintenance
 	}
+
+	return monitorResultDown
 }
 
 func (widget *monitorWidget) Render() template.HTML {
@@ -83,8 +284,8 @@ func (widget *monitorWidget) Render() template.HTML {
 	return widget.renderTemplate(widget, monitorWidgetTemplate)
 }
 
-func statusCodeToText(status int, altStatusCodes []int) string {
-	if status == 200 || slices.Contains(altStatusCodes, status) {
+func statusCodeToText(status int, isAccepted bool) string {
+	if isAccepted {
 		return "OK"
 	}
 	if status == 404 {
@@ -106,8 +307,12 @@ func statusCodeToText(status int, altStatusCodes []int) string {
 	return strconv.Itoa(status)
 }
 
-func statusCodeToStyle(status int, altStatusCodes []int) string {
-	if status == 200 || slices.Contains(altStatusCodes, status) {
+func statusCodeToStyle(status int, isAccepted bool, inMaintenance bool) string {
+	if inMaintenance {
+		return "maintenance"
+	}
+
+	if isAccepted {
 		return "ok"
 	}
 
@@ -115,13 +320,24 @@ func statusCodeToStyle(status int, altStatusCodes []int) string {
 }
 
 type SiteStatusRequest struct {
+	// One of http, tcp, ping or dns, defaults to http
+	Check string `yaml:"check"`
+	// The host:port to connect to for tcp checks, the host to ping
+	// for ping checks and the name to resolve for dns checks
+	Host string `yaml:"host"`
+	// The DNS server to query for dns checks, uses the system resolver when empty
+	Server          string                 `yaml:"server"`
+	RecordType      string                 `yaml:"record-type"`
+	ExpectedAnswers []string               `yaml:"expected-answers"`
+	Expect          siteStatusExpectations `yaml:"expect"`
+
 	DefaultURL    string        `yaml:"url"`
 	CheckURL      string        `yaml:"check-url"`
 	AllowInsecure bool          `yaml:"allow-insecure"`
 	Timeout       durationField `yaml:"timeout"`
 	BasicAuth     struct {
 		Username string `yaml:"username"`
-		Password string `yaml:"password"`
+		Password string `yaml:"password" secret:"true"`
 	} `yaml:"basic-auth"`
 }
 
@@ -130,9 +346,26 @@ type siteStatus struct {
 	TimedOut     bool
 	ResponseTime time.Duration
 	Error        error
+	// Set when the site responded but the response didn't meet the expectations
+	FailureReason string
 }
 
 func fetchSiteStatusTask(statusRequest *SiteStatusRequest) (siteStatus, error) {
+	if !statusRequest.isHTTPCheck() {
+		timeout := ternary(statusRequest.Timeout > 0, time.Duration(statusRequest.Timeout), 3*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		switch statusRequest.Check {
+		case monitorCheckTCP:
+			return fetchTCPStatus(ctx, statusRequest.Host), nil
+		case monitorCheckPing:
+			return fetchPingStatus(ctx, statusRequest.Host), nil
+		case monitorCheckDNS:
+			return fetchDNSStatus(ctx, statusRequest), nil
+		}
+	}
+
 	var url string
 	if statusRequest.CheckURL != "" {
 		url = statusRequest.CheckURL
@@ -178,6 +411,7 @@ func fetchSiteStatusTask(statusRequest *SiteStatusRequest) (siteStatus, error) {
 	defer response.Body.Close()
 
 	status.Code = response.StatusCode
+	status.FailureReason = statusRequest.Expect.checkResponse(response)
 
 	return status, nil
 }
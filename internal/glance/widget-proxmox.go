@@ -0,0 +1,314 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var proxmoxWidgetTemplate = mustParseTemplate("proxmox.html", "widget-base.html", "state-icon.html")
+
+type proxmoxWidget struct {
+	widgetBase    `yaml:",inline"`
+	URL           string         `yaml:"url"`
+	TokenID       string         `yaml:"token-id"`
+	TokenSecret   string         `yaml:"token-secret" secret:"true"`
+	AllowInsecure bool           `yaml:"allow-insecure"`
+	Tags          []string       `yaml:"tags"`
+	Pools         []string       `yaml:"pools"`
+	HideNodes     bool           `yaml:"hide-nodes"`
+	HideStopped   bool           `yaml:"hide-stopped"`
+	Nodes         []proxmoxNode  `yaml:"-"`
+	Guests        []proxmoxGuest `yaml:"-"`
+}
+
+func (widget *proxmoxWidget) initialize() error {
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	widget.
+		withTitle("Proxmox").
+		withTitleURL(widget.URL).
+		withCacheDuration(30 * time.Second)
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if widget.TokenID == "" || widget.TokenSecret == "" {
+		return errors.New("token-id and token-secret are required")
+	}
+
+	if !strings.Contains(widget.TokenID, "!") {
+		return errors.New("token-id must be in the format user@realm!tokenname")
+	}
+
+	for i := range widget.Tags {
+		widget.Tags[i] = strings.ToLower(widget.Tags[i])
+	}
+
+	return nil
+}
+
+func (widget *proxmoxWidget) update(ctx context.Context) {
+	resources, err := fetchProxmoxClusterResources(widget.URL, widget.TokenID, widget.TokenSecret, widget.AllowInsecure)
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Nodes, widget.Guests = buildProxmoxNodesAndGuests(resources, widget.Tags, widget.Pools, widget.HideStopped)
+}
+
+func (widget *proxmoxWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, proxmoxWidgetTemplate)
+}
+
+type proxmoxNode struct {
+	Name          string
+	IsOnline      bool
+	BootTime      time.Time
+	CPUPercent    int
+	CPUCount      int
+	MemoryPercent int
+	MemoryUsedMB  uint64
+	MemoryTotalMB uint64
+	GuestsRunning int
+	GuestsTotal   int
+
+	GuestsRunningPercent int
+}
+
+type proxmoxGuest struct {
+	ID            int
+	Type          string
+	Name          string
+	Node          string
+	Pool          string
+	Tags          []string
+	Status        string
+	StateIcon     string
+	IsRunning     bool
+	StartedAt     time.Time
+	CPUPercent    int
+	CPUCount      int
+	MemoryPercent int
+	MemoryUsedMB  uint64
+	MemoryTotalMB uint64
+}
+
+type proxmoxClusterResource struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	Node     string  `json:"node"`
+	VMID     int     `json:"vmid"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	HAState  string  `json:"hastate"`
+	Lock     string  `json:"lock"`
+	Pool     string  `json:"pool"`
+	Tags     string  `json:"tags"`
+	Template int     `json:"template"`
+	Uptime   int64   `json:"uptime"`
+	CPU      float64 `json:"cpu"`
+	MaxCPU   int     `json:"maxcpu"`
+	Mem      uint64  `json:"mem"`
+	MaxMem   uint64  `json:"maxmem"`
+}
+
+type proxmoxClusterResourcesResponse struct {
+	Data []proxmoxClusterResource `json:"data"`
+}
+
+func fetchProxmoxClusterResources(url, tokenID, tokenSecret string, allowInsecure bool) ([]proxmoxClusterResource, error) {
+	request, err := http.NewRequest("GET", url+"/api2/json/cluster/resources", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "PVEAPIToken="+tokenID+"="+tokenSecret)
+
+	client := ternary(allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
+	response, err := decodeJsonFromRequest[proxmoxClusterResourcesResponse](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching cluster resources: %w", err)
+	}
+
+	return response.Data, nil
+}
+
+// Node stats are shown regardless of the tag and pool filters, guest counts on each node however
+// only include the guests that pass them
+func buildProxmoxNodesAndGuests(
+	resources []proxmoxClusterResource,
+	tags []string,
+	pools []string,
+	hideStopped bool,
+) ([]proxmoxNode, []proxmoxGuest) {
+	now := time.Now()
+	nodes := make([]proxmoxNode, 0)
+	guests := make([]proxmoxGuest, 0)
+	nodeIndexByName := make(map[string]int)
+
+	for i := range resources {
+		resource := &resources[i]
+		if resource.Type != "node" {
+			continue
+		}
+
+		node := proxmoxNode{
+			Name:     resource.Node,
+			IsOnline: resource.Status == "online",
+		}
+
+		if node.IsOnline {
+			node.CPUCount = resource.MaxCPU
+			node.CPUPercent = proxmoxPercent(resource.CPU)
+			node.MemoryUsedMB = resource.Mem / 1024 / 1024
+			node.MemoryTotalMB = resource.MaxMem / 1024 / 1024
+			node.MemoryPercent = proxmoxUsagePercent(resource.Mem, resource.MaxMem)
+
+			if resource.Uptime > 0 {
+				node.BootTime = now.Add(-time.Duration(resource.Uptime) * time.Second)
+			}
+		}
+
+		nodeIndexByName[node.Name] = len(nodes)
+		nodes = append(nodes, node)
+	}
+
+	for i := range resources {
+		resource := &resources[i]
+		if (resource.Type != "qemu" && resource.Type != "lxc") || resource.Template == 1 {
+			continue
+		}
+
+		guest := proxmoxGuest{
+			ID:     resource.VMID,
+			Type:   ternary(resource.Type == "qemu", "VM", "LXC"),
+			Name:   resource.Name,
+			Node:   resource.Node,
+			Pool:   resource.Pool,
+			Tags:   splitProxmoxTags(resource.Tags),
+			Status: resource.Status,
+		}
+
+		if guest.Name == "" {
+			guest.Name = fmt.Sprintf("%s %d", guest.Type, guest.ID)
+		}
+
+		if !proxmoxGuestMatchesFilters(&guest, tags, pools) {
+			continue
+		}
+
+		nodeIndex, nodeIsKnown := nodeIndexByName[guest.Node]
+		if nodeIsKnown {
+			nodes[nodeIndex].GuestsTotal++
+		}
+
+		guest.IsRunning = resource.Status == "running"
+
+		if guest.IsRunning {
+			guest.CPUCount = resource.MaxCPU
+			guest.CPUPercent = proxmoxPercent(resource.CPU)
+			guest.MemoryUsedMB = resource.Mem / 1024 / 1024
+			guest.MemoryTotalMB = resource.MaxMem / 1024 / 1024
+			guest.MemoryPercent = proxmoxUsagePercent(resource.Mem, resource.MaxMem)
+
+			if resource.Uptime > 0 {
+				guest.StartedAt = now.Add(-time.Duration(resource.Uptime) * time.Second)
+			}
+
+			if nodeIsKnown {
+				nodes[nodeIndex].GuestsRunning++
+			}
+		} else if hideStopped {
+			continue
+		}
+
+		switch {
+		case resource.Lock != "":
+			guest.Status = resource.Lock
+			guest.StateIcon = dockerContainerStateIconMaintenance
+		case guest.IsRunning:
+			guest.StateIcon = dockerContainerStateIconOK
+		case resource.Status == "stopped" && resource.HAState == "started":
+			// HA is trying to keep the guest running but it isn't
+			guest.StateIcon = dockerContainerStateIconWarn
+		case resource.Status == "stopped" || resource.Status == "paused":
+			guest.StateIcon = dockerContainerStateIconPaused
+		case resource.Status == "unknown":
+			guest.StateIcon = dockerContainerStateIconWarn
+		default:
+			guest.StateIcon = dockerContainerStateIconOther
+		}
+
+		guests = append(guests, guest)
+	}
+
+	for i := range nodes {
+		if nodes[i].GuestsTotal > 0 {
+			nodes[i].GuestsRunningPercent = nodes[i].GuestsRunning * 100 / nodes[i].GuestsTotal
+		}
+	}
+
+	sort.SliceStable(nodes, func(a, b int) bool {
+		if nodes[a].IsOnline != nodes[b].IsOnline {
+			return !nodes[a].IsOnline
+		}
+
+		return nodes[a].Name < nodes[b].Name
+	})
+
+	p := dockerContainerStateIconPriorities
+	sort.SliceStable(guests, func(a, b int) bool {
+		if guests[a].StateIcon != guests[b].StateIcon {
+			return p[guests[a].StateIcon] < p[guests[b].StateIcon]
+		}
+
+		return guests[a].ID < guests[b].ID
+	})
+
+	return nodes, guests
+}
+
+func proxmoxGuestMatchesFilters(guest *proxmoxGuest, tags []string, pools []string) bool {
+	if len(pools) > 0 && !slices.Contains(pools, guest.Pool) {
+		return false
+	}
+
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range guest.Tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tags are separated by semicolons, though older versions and manual edits can also use commas or spaces
+func splitProxmoxTags(tags string) []string {
+	return strings.FieldsFunc(strings.ToLower(tags), func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
+
+func proxmoxPercent(fraction float64) int {
+	return min(100, max(0, int(fraction*100+0.5)))
+}
+
+func proxmoxUsagePercent(used, total uint64) int {
+	if total == 0 {
+		return 0
+	}
+
+	return proxmoxPercent(float64(used) / float64(total))
+}
@@ -0,0 +1,174 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const proxmoxTestClusterResources = `{"data": [
+	{"id": "node/pve2", "type": "node", "node": "pve2", "status": "offline"},
+	{"id": "node/pve1", "type": "node", "node": "pve1", "status": "online", "cpu": 0.256, "maxcpu": 8, "mem": 8589934592, "maxmem": 34359738368, "uptime": 3600},
+	{"id": "storage/pve1/local", "type": "storage", "node": "pve1", "status": "available"},
+	{"id": "qemu/100", "type": "qemu", "vmid": 100, "name": "jellyfin", "node": "pve1", "status": "running", "tags": "media;prod", "cpu": 0.5, "maxcpu": 4, "mem": 1073741824, "maxmem": 4294967296, "uptime": 60},
+	{"id": "lxc/101", "type": "lxc", "vmid": 101, "name": "sonarr", "node": "pve1", "status": "stopped", "tags": "Media", "pool": "arr"},
+	{"id": "lxc/102", "type": "lxc", "vmid": 102, "name": "radarr", "node": "pve1", "status": "stopped", "hastate": "started", "tags": "media"},
+	{"id": "qemu/103", "type": "qemu", "vmid": 103, "name": "backup", "node": "pve1", "status": "running", "lock": "backup", "tags": "media"},
+	{"id": "qemu/104", "type": "qemu", "vmid": 104, "name": "router", "node": "pve1", "status": "running"},
+	{"id": "qemu/9000", "type": "qemu", "vmid": 9000, "name": "template", "node": "pve1", "status": "stopped", "template": 1, "tags": "media"}
+]}`
+
+func decodeProxmoxTestResources(t *testing.T, data string) []proxmoxClusterResource {
+	t.Helper()
+
+	var response proxmoxClusterResourcesResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		t.Fatalf("decoding test resources: %v", err)
+	}
+
+	return response.Data
+}
+
+func TestBuildProxmoxNodesAndGuests(t *testing.T) {
+	resources := decodeProxmoxTestResources(t, proxmoxTestClusterResources)
+
+	tests := []struct {
+		name        string
+		tags        []string
+		pools       []string
+		hideStopped bool
+		// problems first, then stopped and locked, then running
+		wantGuests []int
+		// the total and running guests on pve1
+		wantTotal   int
+		wantRunning int
+	}{
+		{"no filters", nil, nil, false, []int{102, 101, 103, 100, 104}, 5, 3},
+		// tags are matched case insensitively, the widget lowercases the configured ones
+		{"tags", []string{"media"}, nil, false, []int{102, 101, 103, 100}, 4, 2},
+		{"pools", nil, []string{"arr"}, false, []int{101}, 1, 0},
+		{"tags and pools", []string{"prod"}, []string{"arr"}, false, []int{}, 0, 0},
+		// stopped guests still count towards the total of their node
+		{"hide stopped", []string{"media"}, nil, true, []int{103, 100}, 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, guests := buildProxmoxNodesAndGuests(resources, tt.tags, tt.pools, tt.hideStopped)
+
+			ids := make([]int, len(guests))
+			for i := range guests {
+				ids[i] = guests[i].ID
+			}
+
+			if !reflect.DeepEqual(ids, tt.wantGuests) {
+				t.Errorf("guests = %v, want %v", ids, tt.wantGuests)
+			}
+
+			pve1 := nodes[1]
+			if pve1.GuestsTotal != tt.wantTotal || pve1.GuestsRunning != tt.wantRunning {
+				t.Errorf("pve1 guests = %d/%d running, want %d/%d", pve1.GuestsRunning, pve1.GuestsTotal, tt.wantRunning, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestBuildProxmoxNodes(t *testing.T) {
+	nodes, _ := buildProxmoxNodesAndGuests(decodeProxmoxTestResources(t, proxmoxTestClusterResources), []string{"media"}, nil, false)
+
+	// offline nodes are sorted first
+	if len(nodes) != 2 || nodes[0].Name != "pve2" || nodes[1].Name != "pve1" {
+		t.Fatalf("nodes = %+v, want pve2 then pve1", nodes)
+	}
+
+	if pve2 := nodes[0]; pve2.IsOnline || pve2.CPUCount != 0 || !pve2.BootTime.IsZero() {
+		t.Errorf("offline node = %+v, want no stats", pve2)
+	}
+
+	pve1 := nodes[1]
+	if pve1.CPUPercent != 26 || pve1.CPUCount != 8 || pve1.MemoryPercent != 25 || pve1.MemoryUsedMB != 8192 || pve1.MemoryTotalMB != 32768 {
+		t.Errorf("node stats = %+v, want 26%% of 8 CPUs and 8192 of 32768 MB", pve1)
+	}
+
+	if pve1.BootTime.IsZero() || pve1.GuestsRunningPercent != 50 {
+		t.Errorf("node = %+v, want a boot time and half of its guests running", pve1)
+	}
+}
+
+func TestProxmoxGuestState(t *testing.T) {
+	tests := []struct {
+		name        string
+		resource    string
+		wantName    string
+		wantType    string
+		wantStatus  string
+		wantIcon    string
+		wantRunning bool
+	}{
+		{"running vm", `{"type": "qemu", "vmid": 100, "name": "jellyfin", "status": "running", "cpu": 0.5, "maxcpu": 4}`, "jellyfin", "VM", "running", dockerContainerStateIconOK, true},
+		{"stopped container", `{"type": "lxc", "vmid": 101, "name": "sonarr", "status": "stopped"}`, "sonarr", "LXC", "stopped", dockerContainerStateIconPaused, false},
+		{"paused vm", `{"type": "qemu", "vmid": 102, "name": "vm", "status": "paused"}`, "vm", "VM", "paused", dockerContainerStateIconPaused, false},
+		{"stopped with ha started", `{"type": "lxc", "vmid": 103, "name": "radarr", "status": "stopped", "hastate": "started"}`, "radarr", "LXC", "stopped", dockerContainerStateIconWarn, false},
+		{"locked", `{"type": "qemu", "vmid": 104, "name": "backup", "status": "running", "lock": "backup"}`, "backup", "VM", "backup", dockerContainerStateIconMaintenance, true},
+		{"unknown", `{"type": "qemu", "vmid": 105, "name": "lost", "status": "unknown"}`, "lost", "VM", "unknown", dockerContainerStateIconWarn, false},
+		{"without a name", `{"type": "lxc", "vmid": 106, "status": "running"}`, "LXC 106", "LXC", "running", dockerContainerStateIconOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, guests := buildProxmoxNodesAndGuests(decodeProxmoxTestResources(t, `{"data": [`+tt.resource+`]}`), nil, nil, false)
+			if len(guests) != 1 {
+				t.Fatalf("got %d guests, want 1", len(guests))
+			}
+
+			guest := guests[0]
+			if guest.Name != tt.wantName || guest.Type != tt.wantType || guest.Status != tt.wantStatus || guest.StateIcon != tt.wantIcon || guest.IsRunning != tt.wantRunning {
+				t.Errorf("guest = %+v, want %s %s %s %s running %v", guest, tt.wantName, tt.wantType, tt.wantStatus, tt.wantIcon, tt.wantRunning)
+			}
+		})
+	}
+}
+
+func TestSplitProxmoxTags(t *testing.T) {
+	tests := []struct {
+		tags string
+		want []string
+	}{
+		{"", []string{}},
+		{"media", []string{"media"}},
+		{"Media;Prod", []string{"media", "prod"}},
+		{"media,prod  backup", []string{"media", "prod", "backup"}},
+		{";media;", []string{"media"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tags, func(t *testing.T) {
+			if got := splitProxmoxTags(tt.tags); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("splitProxmoxTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxmoxWidgetUpdate(t *testing.T) {
+	url := startFakeAPIServer(t, "Authorization: PVEAPIToken=root@pam!glance=secret", map[string]string{
+		"GET /api2/json/cluster/resources": proxmoxTestClusterResources,
+	})
+
+	widget := &proxmoxWidget{URL: url + "/", TokenID: "root@pam!glance", TokenSecret: "secret", Tags: []string{"MEDIA"}}
+	if err := widget.initialize(); err != nil {
+		t.Fatalf("initializing widget: %v", err)
+	}
+
+	widget.update(context.Background())
+
+	if widget.Error != nil || len(widget.Nodes) != 2 || len(widget.Guests) != 4 {
+		t.Errorf("widget = %d nodes, %d guests, error %v, want 2 nodes and the 4 tagged guests", len(widget.Nodes), len(widget.Guests), widget.Error)
+	}
+
+	if _, err := fetchProxmoxClusterResources(widget.URL, widget.TokenID, "wrong", false); err == nil {
+		t.Error("fetchProxmoxClusterResources() error = nil, want an error with an invalid token")
+	}
+}
@@ -45,7 +45,7 @@ type redditWidget struct {
 	AppAuth struct {
 		Name   string `yaml:"name"`
 		ID     string `yaml:"id"`
-		Secret string `yaml:"secret"`
+		Secret string `yaml:"secret" secret:"true"`
 
 		enabled        bool
 		accessToken    string
@@ -21,11 +21,14 @@ type releasesWidget struct {
 	widgetBase     `yaml:",inline"`
 	Releases       appReleaseList    `yaml:"-"`
 	Repositories   []*releaseRequest `yaml:"repositories"`
-	Token          string            `yaml:"token"`
-	GitLabToken    string            `yaml:"gitlab-token"`
+	Token          string            `yaml:"token" secret:"true"`
+	GitLabToken    string            `yaml:"gitlab-token" secret:"true"`
 	Limit          int               `yaml:"limit"`
 	CollapseAfter  int               `yaml:"collapse-after"`
 	ShowSourceIcon bool              `yaml:"show-source-icon"`
+	Notify         *notificationRule `yaml:"notify"`
+
+	notificationTimer notificationCheckTimer
 }
 
 func (widget *releasesWidget) initialize() error {
@@ -49,7 +52,7 @@ func (widget *releasesWidget) initialize() error {
 		}
 	}
 
-	return nil
+	return widget.Notify.initialize(1, "release")
 }
 
 func (widget *releasesWidget) update(ctx context.Context) {
@@ -59,6 +62,8 @@ func (widget *releasesWidget) update(ctx context.Context) {
 		return
 	}
 
+	widget.notifyOnNewReleases(releases)
+
 	if len(releases) > widget.Limit {
 		releases = releases[:widget.Limit]
 	}
@@ -70,6 +75,54 @@ func (widget *releasesWidget) update(ctx context.Context) {
 	widget.Releases = releases
 }
 
+func (widget *releasesWidget) notificationRule() *notificationRule {
+	return widget.Notify
+}
+
+func (widget *releasesWidget) runInBackground(ctx context.Context, app *application) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	runNotificationChecks(ctx, app, widget.cacheDuration, &widget.notificationTimer, func() {
+		releases, err := fetchLatestReleases(widget.Repositories)
+		if err != nil && !errors.Is(err, errPartialContent) {
+			slog.Error("Failed to check releases for notifications", "error", err)
+			return
+		}
+
+		widget.notifyOnNewReleases(releases)
+	})
+}
+
+// The first version seen for a repository is only recorded, any different one after it is considered new
+func (widget *releasesWidget) notifyOnNewReleases(releases appReleaseList) {
+	if !widget.Notify.isActive() {
+		return
+	}
+
+	widget.notificationTimer.markChecked()
+
+	for i := range releases {
+		release := &releases[i]
+		key := "release:" + string(release.Source) + ":" + release.Name
+
+		previous, changed := notificationStates.observe(key, release.Version, 1)
+		if !changed {
+			continue
+		}
+
+		widget.Notify.send(notification{
+			key:     key,
+			Event:   "release",
+			Widget:  widget.Title,
+			Title:   release.Name + " " + release.Version + " released",
+			Message: fmt.Sprintf("%s has been updated from %s to %s", release.Name, previous, release.Version),
+			URL:     release.NotesUrl,
+		})
+	}
+}
+
 func (widget *releasesWidget) Render() template.HTML {
 	return widget.renderTemplate(widget, releasesWidgetTemplate)
 }
@@ -273,21 +326,18 @@ const dockerHubTagsURLFormat = "https://hub.docker.com/v2/namespaces/%s/reposito
 const dockerHubSpecificTagURLFormat = "https://hub.docker.com/v2/namespaces/%s/repositories/%s/tags/%s"
 
 func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
-	nameParts := strings.Split(request.Repository, "/")
-
-	if len(nameParts) > 2 {
+	ref, err := parseDockerImageReference(request.Repository)
+	if err != nil || ref.Registry != dockerHubRegistry || strings.Count(ref.Repository, "/") != 1 {
 		return nil, fmt.Errorf("invalid repository name: %s", request.Repository)
-	} else if len(nameParts) == 1 {
-		nameParts = []string{"library", nameParts[0]}
 	}
 
-	tagParts := strings.SplitN(nameParts[1], ":", 2)
+	namespace, repo, _ := strings.Cut(ref.Repository, "/")
 	var requestURL string
 
-	if len(tagParts) == 2 {
-		requestURL = fmt.Sprintf(dockerHubSpecificTagURLFormat, nameParts[0], tagParts[0], tagParts[1])
+	if ref.Tag != "" {
+		requestURL = fmt.Sprintf(dockerHubSpecificTagURLFormat, namespace, repo, ref.Tag)
 	} else {
-		requestURL = fmt.Sprintf(dockerHubTagsURLFormat, nameParts[0], nameParts[1])
+		requestURL = fmt.Sprintf(dockerHubTagsURLFormat, namespace, repo)
 	}
 
 	httpRequest, err := http.NewRequest("GET", requestURL, nil)
@@ -301,7 +351,7 @@ func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
 
 	var tag *dockerHubRepositoryTagResponse
 
-	if len(tagParts) == 1 {
+	if ref.Tag == "" {
 		response, err := decodeJsonFromRequest[dockerHubRepositoryTagsResponse](defaultHTTPClient, httpRequest)
 		if err != nil {
 			return nil, err
@@ -321,21 +371,14 @@ func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
 		tag = &response
 	}
 
-	var repo string
 	var displayName string
 	var notesURL string
 
-	if len(tagParts) == 1 {
-		repo = nameParts[1]
-	} else {
-		repo = tagParts[0]
-	}
-
-	if nameParts[0] == "library" {
+	if namespace == "library" {
 		displayName = repo
 		notesURL = fmt.Sprintf(dockerHubOfficialRepoTagURLFormat, repo, tag.Name)
 	} else {
-		displayName = nameParts[0] + "/" + repo
+		displayName = namespace + "/" + repo
 		notesURL = fmt.Sprintf(dockerHubRepoTagURLFormat, displayName, tag.Name)
 	}
 
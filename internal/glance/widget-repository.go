@@ -15,7 +15,7 @@ var repositoryWidgetTemplate = mustParseTemplate("repository.html", "widget-base
 type repositoryWidget struct {
 	widgetBase          `yaml:",inline"`
 	RequestedRepository string     `yaml:"repository"`
-	Token               string     `yaml:"token"`
+	Token               string     `yaml:"token" secret:"true"`
 	PullRequestsLimit   int        `yaml:"pull-requests-limit"`
 	IssuesLimit         int        `yaml:"issues-limit"`
 	CommitsLimit        int        `yaml:"commits-limit"`
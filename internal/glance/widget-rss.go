@@ -135,7 +135,7 @@ type rssFeedRequest struct {
 	HideDescription bool              `yaml:"hide-description"`
 	Limit           int               `yaml:"limit"`
 	ItemLinkPrefix  string            `yaml:"item-link-prefix"`
-	Headers         map[string]string `yaml:"headers"`
+	Headers         map[string]string `yaml:"headers" secret:"true"`
 	IsDetailed      bool              `yaml:"-"`
 }
 
@@ -93,7 +93,7 @@ type serverStatsRequest struct {
 	HideSwap                   bool                `yaml:"hide-swap"`
 	Type                       string              `yaml:"type"`
 	URL                        string              `yaml:"url"`
-	Token                      string              `yaml:"token"`
+	Token                      string              `yaml:"token" secret:"true"`
 	Timeout                    durationField       `yaml:"timeout"`
 	// Support for other agents
 	// Provider                   string              `yaml:"provider"`
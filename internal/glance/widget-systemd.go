@@ -0,0 +1,394 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var systemdWidgetTemplate = mustParseTemplate("systemd.html", "widget-base.html", "state-icon.html")
+
+const (
+	systemdDestination    = "org.freedesktop.systemd1"
+	systemdObjectPath     = "/org/freedesktop/systemd1"
+	systemdManagerIface   = "org.freedesktop.systemd1.Manager"
+	systemdUnitIface      = "org.freedesktop.systemd1.Unit"
+	dbusPropertiesIface   = "org.freedesktop.DBus.Properties"
+	systemdRequestTimeout = 10 * time.Second
+)
+
+var systemdUnitActions = []string{"start", "stop", "restart"}
+
+type systemdWidget struct {
+	widgetBase    `yaml:",inline"`
+	Bus           string                       `yaml:"bus"`
+	Units         []string                     `yaml:"units"`
+	UnitOverrides map[string]map[string]string `yaml:"overrides"`
+	Actions       []string                     `yaml:"actions"`
+	UnitStatuses  []systemdUnit                `yaml:"-"`
+	busAddress    string
+}
+
+func (widget *systemdWidget) initialize() error {
+	widget.withTitle("Services").withCacheDuration(1 * time.Minute)
+
+	if len(widget.Units) == 0 {
+		return errors.New("at least one unit is required")
+	}
+
+	switch widget.Bus {
+	case "", "system":
+		widget.Bus = "system"
+		widget.busAddress = systemDBusAddress()
+	case "user":
+		widget.busAddress = sessionDBusAddress()
+	default:
+		return fmt.Errorf("unsupported bus %s, must be either system or user", widget.Bus)
+	}
+
+	for i := range widget.Actions {
+		widget.Actions[i] = strings.ToLower(widget.Actions[i])
+		if !slices.Contains(systemdUnitActions, widget.Actions[i]) {
+			return fmt.Errorf("unsupported action %s, must be one of %s", widget.Actions[i], strings.Join(systemdUnitActions, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (widget *systemdWidget) update(ctx context.Context) {
+	units, err := widget.fetchUnits()
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.UnitStatuses = units
+}
+
+func (widget *systemdWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, systemdWidgetTemplate)
+}
+
+func (widget *systemdWidget) hasActions() bool {
+	return len(widget.Actions) > 0
+}
+
+// Handles POST requests to units/{name}/{action}
+func (widget *systemdWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.PathValue("path"), "/")
+	if len(parts) != 3 || parts[0] != "units" {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	unitName, action := parts[1], parts[2]
+
+	if !slices.Contains(widget.Actions, action) {
+		writeJSONResponse(w, http.StatusForbidden, map[string]string{"error": "action is not allowed"})
+		return
+	}
+
+	// Only units matching the configured patterns can be acted upon, so they're fetched again
+	// rather than trusting the name, which also avoids touching the units used for rendering
+	units, err := widget.fetchUnits()
+	if err != nil {
+		slog.Error("Failed to fetch systemd units for action", "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": "could not fetch units"})
+		return
+	}
+
+	if !slices.ContainsFunc(units, func(u systemdUnit) bool { return u.UnitName == unitName }) {
+		writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "unit not found"})
+		return
+	}
+
+	method := map[string]string{"start": "StartUnit", "stop": "StopUnit", "restart": "RestartUnit"}[action]
+
+	err = withDBusConn(widget.busAddress, systemdRequestTimeout, func(conn *dbusConn) error {
+		_, err := conn.call(systemdDestination, systemdObjectPath, systemdManagerIface, method, "ss", unitName, "replace")
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to perform systemd unit action", "unit", unitName, "action", action, "error", err)
+		writeJSONResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Performed systemd unit action", "unit", unitName, "action", action)
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type systemdUnit struct {
+	UnitName    string
+	Name        string
+	Description string
+	URL         string
+	SameTab     bool
+	Icon        customIconField
+	State       string
+	StateText   string
+	StateIcon   string
+	ActiveSince time.Time
+	// The actions which can be performed given the unit's current state
+	Actions []string
+}
+
+type systemdUnitStatus struct {
+	name        string
+	description string
+	loadState   string
+	activeState string
+	subState    string
+	result      string
+	activeSince time.Time
+}
+
+func (widget *systemdWidget) fetchUnits() ([]systemdUnit, error) {
+	var statuses []systemdUnitStatus
+
+	err := withDBusConn(widget.busAddress, systemdRequestTimeout, func(conn *dbusConn) error {
+		var err error
+		statuses, err = fetchSystemdUnitStatuses(conn, widget.Units)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	units := make([]systemdUnit, 0, len(statuses))
+
+	for i := range statuses {
+		status := &statuses[i]
+		overrides := widget.UnitOverrides[status.name]
+
+		if stringToBool(overrides["hide"]) {
+			continue
+		}
+
+		unit := systemdUnit{
+			UnitName:    status.name,
+			Name:        ternary(overrides["name"] != "", overrides["name"], strings.TrimSuffix(status.name, ".service")),
+			Description: ternary(overrides["description"] != "", overrides["description"], status.description),
+			URL:         overrides["url"],
+			SameTab:     stringToBool(overrides["same-tab"]),
+			Icon:        newCustomIconField(ternary(overrides["icon"] != "", overrides["icon"], "si:linux")),
+			ActiveSince: status.activeSince,
+		}
+
+		unit.State, unit.StateText, unit.StateIcon = status.state()
+
+		isActive := status.activeState == "active" || status.activeState == "reloading"
+		for _, action := range widget.Actions {
+			if status.loadState != "loaded" || (action == "start" && isActive) || (action == "stop" && !isActive) {
+				continue
+			}
+
+			unit.Actions = append(unit.Actions, action)
+		}
+
+		units = append(units, unit)
+	}
+
+	p := dockerContainerStateIconPriorities
+
+	sort.SliceStable(units, func(a, b int) bool {
+		if units[a].StateIcon != units[b].StateIcon {
+			return p[units[a].StateIcon] < p[units[b].StateIcon]
+		}
+
+		return strings.ToLower(units[a].Name) < strings.ToLower(units[b].Name)
+	})
+
+	return units, nil
+}
+
+func (status *systemdUnitStatus) state() (state string, text string, icon string) {
+	state = status.activeState
+	text = status.activeState + " (" + status.subState + ")"
+	failedResult := status.result != "" && status.result != "success"
+
+	if failedResult {
+		text += ", " + status.result
+	}
+
+	switch {
+	case status.loadState == "not-found" || status.loadState == "error" || status.loadState == "bad-setting":
+		state = status.loadState
+		text = status.loadState
+		icon = dockerContainerStateIconWarn
+	case status.loadState == "masked":
+		state = "masked"
+		text = "masked"
+		icon = dockerContainerStateIconPaused
+	case status.activeState == "active":
+		icon = dockerContainerStateIconOK
+	case status.activeState == "failed":
+		icon = dockerContainerStateIconWarn
+	case status.activeState == "inactive":
+		icon = ternary(failedResult, dockerContainerStateIconWarn, dockerContainerStateIconPaused)
+	default:
+		icon = dockerContainerStateIconOther
+	}
+
+	return state, text, icon
+}
+
+// Units matching the patterns which are loaded are listed in one call, units which aren't loaded,
+// such as ones which aren't running and aren't depended on by anything, are loaded by name
+func fetchSystemdUnitStatuses(conn *dbusConn, patterns []string) ([]systemdUnitStatus, error) {
+	reply, err := conn.call(systemdDestination, systemdObjectPath, systemdManagerIface, "ListUnitsByPatterns", "asas", []string{}, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("listing units: %w", err)
+	}
+
+	if len(reply) == 0 {
+		return nil, errors.New("empty reply when listing units")
+	}
+
+	listed, _ := reply[0].([]any)
+	statuses := make([]systemdUnitStatus, 0, len(listed))
+	paths := make([]string, 0, len(listed))
+	seen := make(map[string]bool, len(listed))
+
+	for _, entry := range listed {
+		// (name, description, load state, active state, sub state, followed, path, job id, job type, job path)
+		fields, ok := entry.([]any)
+		if !ok || len(fields) < 7 {
+			return nil, errors.New("unexpected format of listed units")
+		}
+
+		status := systemdUnitStatus{}
+		status.name, _ = fields[0].(string)
+		status.description, _ = fields[1].(string)
+		status.loadState, _ = fields[2].(string)
+		status.activeState, _ = fields[3].(string)
+		status.subState, _ = fields[4].(string)
+		path, _ := fields[6].(string)
+
+		seen[status.name] = true
+		statuses = append(statuses, status)
+		paths = append(paths, path)
+	}
+
+	for _, pattern := range patterns {
+		if seen[pattern] || strings.ContainsAny(pattern, "*?[") {
+			continue
+		}
+
+		reply, err := conn.call(systemdDestination, systemdObjectPath, systemdManagerIface, "LoadUnit", "s", pattern)
+		if err != nil {
+			return nil, fmt.Errorf("loading unit %s: %w", pattern, err)
+		}
+
+		if len(reply) == 0 {
+			return nil, fmt.Errorf("empty reply when loading unit %s", pattern)
+		}
+
+		path, _ := reply[0].(string)
+		properties, err := fetchSystemdUnitProperties(conn, path, systemdUnitIface)
+		if err != nil {
+			return nil, fmt.Errorf("fetching properties of %s: %w", pattern, err)
+		}
+
+		status := systemdUnitStatus{name: pattern}
+		status.description, _ = properties["Description"].(string)
+		status.loadState, _ = properties["LoadState"].(string)
+		status.activeState, _ = properties["ActiveState"].(string)
+		status.subState, _ = properties["SubState"].(string)
+
+		seen[pattern] = true
+		statuses = append(statuses, status)
+		paths = append(paths, path)
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+		if status.loadState != "loaded" {
+			continue
+		}
+
+		timestamp, err := fetchSystemdUnitProperty(conn, paths[i], systemdUnitIface, "ActiveEnterTimestamp")
+		if err != nil {
+			return nil, fmt.Errorf("fetching activation time of %s: %w", status.name, err)
+		}
+
+		// in microseconds, 0 when the unit has never been active
+		if usec, ok := timestamp.(uint64); ok && usec > 0 && status.activeState == "active" {
+			status.activeSince = time.UnixMicro(int64(usec))
+		}
+
+		if iface := systemdUnitTypeIface(status.name); iface != "" {
+			result, err := fetchSystemdUnitProperty(conn, paths[i], iface, "Result")
+			if err != nil {
+				return nil, fmt.Errorf("fetching result of %s: %w", status.name, err)
+			}
+
+			status.result, _ = result.(string)
+		}
+	}
+
+	return statuses, nil
+}
+
+// The result of the last run is specific to each type of unit, and not all types have one
+func systemdUnitTypeIface(name string) string {
+	switch name[strings.LastIndexByte(name, '.')+1:] {
+	case "service":
+		return "org.freedesktop.systemd1.Service"
+	case "socket":
+		return "org.freedesktop.systemd1.Socket"
+	case "timer":
+		return "org.freedesktop.systemd1.Timer"
+	case "mount":
+		return "org.freedesktop.systemd1.Mount"
+	case "path":
+		return "org.freedesktop.systemd1.Path"
+	case "swap":
+		return "org.freedesktop.systemd1.Swap"
+	}
+
+	return ""
+}
+
+func fetchSystemdUnitProperty(conn *dbusConn, path string, iface string, property string) (any, error) {
+	reply, err := conn.call(systemdDestination, path, dbusPropertiesIface, "Get", "ss", iface, property)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reply) == 0 {
+		return nil, errors.New("empty reply")
+	}
+
+	return reply[0], nil
+}
+
+func fetchSystemdUnitProperties(conn *dbusConn, path string, iface string) (map[string]any, error) {
+	reply, err := conn.call(systemdDestination, path, dbusPropertiesIface, "GetAll", "s", iface)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reply) == 0 {
+		return nil, errors.New("empty reply")
+	}
+
+	properties, ok := reply[0].(map[string]any)
+	if !ok {
+		return nil, errors.New("unexpected format of properties")
+	}
+
+	return properties, nil
+}
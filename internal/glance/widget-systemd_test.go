@@ -0,0 +1,107 @@
+package glance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSystemdWidgetInitialize(t *testing.T) {
+	tests := []struct {
+		name        string
+		widget      systemdWidget
+		wantActions string
+		wantErr     bool
+	}{
+		{"defaults to the system bus", systemdWidget{Units: []string{"nginx.service"}}, "", false},
+		{"actions are case insensitive", systemdWidget{Units: []string{"nginx.service"}, Actions: []string{"Restart"}}, "restart", false},
+		{"no units", systemdWidget{}, "", true},
+		{"unsupported bus", systemdWidget{Units: []string{"nginx.service"}, Bus: "session"}, "", true},
+		{"unsupported action", systemdWidget{Units: []string{"nginx.service"}, Actions: []string{"enable"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.widget.initialize()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("initialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && (tt.widget.Bus == "" || tt.widget.busAddress == "") {
+				t.Errorf("initialize() bus = %q at %q, want one to be set", tt.widget.Bus, tt.widget.busAddress)
+			}
+
+			if !tt.wantErr && len(tt.widget.Actions) > 0 && tt.widget.Actions[0] != tt.wantActions {
+				t.Errorf("initialize() actions = %v, want %s", tt.widget.Actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestSystemdUnitStatusState(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    systemdUnitStatus
+		wantState string
+		wantText  string
+		wantIcon  string
+	}{
+		{"running", systemdUnitStatus{loadState: "loaded", activeState: "active", subState: "running", result: "success"}, "active", "active (running)", dockerContainerStateIconOK},
+		{"failed", systemdUnitStatus{loadState: "loaded", activeState: "failed", subState: "failed", result: "exit-code"}, "failed", "failed (failed), exit-code", dockerContainerStateIconWarn},
+		{"stopped", systemdUnitStatus{loadState: "loaded", activeState: "inactive", subState: "dead", result: "success"}, "inactive", "inactive (dead)", dockerContainerStateIconPaused},
+		{"stopped after failing", systemdUnitStatus{loadState: "loaded", activeState: "inactive", subState: "dead", result: "timeout"}, "inactive", "inactive (dead), timeout", dockerContainerStateIconWarn},
+		{"starting", systemdUnitStatus{loadState: "loaded", activeState: "activating", subState: "start"}, "activating", "activating (start)", dockerContainerStateIconOther},
+		{"not found", systemdUnitStatus{loadState: "not-found", activeState: "inactive", subState: "dead"}, "not-found", "not-found", dockerContainerStateIconWarn},
+		{"masked", systemdUnitStatus{loadState: "masked", activeState: "inactive", subState: "dead"}, "masked", "masked", dockerContainerStateIconPaused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, text, icon := tt.status.state()
+
+			if state != tt.wantState || text != tt.wantText || icon != tt.wantIcon {
+				t.Errorf("state() = %s, %q, %s, want %s, %q, %s", state, text, icon, tt.wantState, tt.wantText, tt.wantIcon)
+			}
+		})
+	}
+}
+
+// Units are only acted upon after checking that they match the configured patterns, which
+// requires the bus, so an allowed action on an unreachable bus must not get any further
+func TestSystemdWidgetHandleRequest(t *testing.T) {
+	widget := &systemdWidget{
+		Units:      []string{"nginx.service"},
+		Actions:    []string{"restart"},
+		busAddress: "unix:path=" + filepath.Join(t.TempDir(), "missing.sock"),
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"allowed action", http.MethodPost, "units/nginx.service/restart", http.StatusBadGateway},
+		{"action that isn't allowed", http.MethodPost, "units/nginx.service/stop", http.StatusForbidden},
+		{"unsupported action", http.MethodPost, "units/nginx.service/enable", http.StatusForbidden},
+		{"action with get", http.MethodGet, "units/nginx.service/restart", http.StatusMethodNotAllowed},
+		{"missing action", http.MethodPost, "units/nginx.service", http.StatusNotFound},
+		{"other resource", http.MethodPost, "jobs/nginx.service/restart", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/api/widgets/1/"+tt.path, nil)
+			request.SetPathValue("path", tt.path)
+			recorder := httptest.NewRecorder()
+
+			widget.handleRequest(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -239,3 +239,39 @@ func workerPoolDo[I any, O any](job *workerPoolJob[I, O]) ([]O, []error, error)
 
 	return results, errs, err
 }
+
+// Runs each of the named parts concurrently, if only some of them fail the
+// returned error wraps errPartialContent so that whatever succeeded can be shown
+func fetchWidgetParts(parts map[string]func() error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	failed := 0
+
+	for name, fetch := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fetch(); err != nil {
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("fetching %s: %w", name, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failed == 0 {
+		return nil
+	}
+
+	if failed == len(parts) {
+		return firstErr
+	}
+
+	return fmt.Errorf("%w: %v", errPartialContent, firstErr)
+}
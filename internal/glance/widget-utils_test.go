@@ -0,0 +1,36 @@
+package glance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serves the given bodies for the http.ServeMux patterns they're keyed by, responding with
+// 401 to requests that are missing the header in auth, such as "X-Api-Key: secret", if it's set
+func startFakeAPIServer(t *testing.T, auth string, responses map[string]string) string {
+	t.Helper()
+
+	authHeader, authValue, _ := strings.Cut(auth, ": ")
+	mux := http.NewServeMux()
+
+	for pattern, body := range responses {
+		// the content type is left for net/http to detect, so that images can be served too
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authHeader != "" && r.Header.Get(authHeader) != authValue {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
@@ -53,6 +53,8 @@ func newWidget(widgetType string) (widget, error) {
 		w = &rssWidget{}
 	case "monitor":
 		w = &monitorWidget{}
+	case "certificates":
+		w = &certificatesWidget{}
 	case "twitch-top-games":
 		w = &twitchGamesWidget{}
 	case "twitch-channels":
@@ -77,6 +79,18 @@ func newWidget(widgetType string) (widget, error) {
 		w = &customAPIWidget{}
 	case "docker-containers":
 		w = &dockerContainersWidget{}
+	case "kubernetes":
+		w = &kubernetesWidget{}
+	case "proxmox":
+		w = &proxmoxWidget{}
+	case "systemd":
+		w = &systemdWidget{}
+	case "home-assistant":
+		w = &homeAssistantWidget{}
+	case "media-server":
+		w = &mediaServerWidget{}
+	case "arr":
+		w = &arrWidget{}
 	case "server-stats":
 		w = &serverStatsWidget{}
 	case "to-do":
@@ -138,6 +152,19 @@ type widget interface {
 	setHideHeader(bool)
 }
 
+// Implemented by widgets that need to keep doing work for as long as the
+// application is running, regardless of whether their page is being viewed
+type backgroundWidget interface {
+	runInBackground(ctx context.Context, app *application)
+}
+
+// Implemented by widgets that can change things or expose sensitive information through
+// their handleRequest, such as restarting containers or viewing their logs, which is
+// only allowed when auth is enabled
+type actionableWidget interface {
+	hasActions() bool
+}
+
 type cacheType int
 
 const (
@@ -168,6 +195,7 @@ type widgetBase struct {
 
 type widgetProviders struct {
 	assetResolver func(string) string
+	baseURL       string
 }
 
 func (w *widgetBase) requiresUpdate(now *time.Time) bool {